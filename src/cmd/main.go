@@ -1,28 +1,76 @@
 package main
 
 import (
+	"context"
+	"eidolonVPN/internal/api"
+	"eidolonVPN/internal/backup"
 	"eidolonVPN/internal/config"
 	"eidolonVPN/internal/config/structures"
 	"eidolonVPN/internal/errors/handlers"
+	"eidolonVPN/internal/events"
+	"eidolonVPN/internal/groups"
 	"eidolonVPN/internal/openconnect"
+	"eidolonVPN/internal/passwd"
+	"eidolonVPN/internal/pki"
+	"eidolonVPN/internal/routes"
+	"eidolonVPN/internal/storage"
+	"eidolonVPN/internal/telegram"
+	telegramHandlers "eidolonVPN/internal/telegram/handlers"
+	"eidolonVPN/internal/users"
 	"eidolonVPN/internal/utils"
+	"html"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
 
 	"fmt"
 	"log"
+
+	"github.com/spf13/pflag"
 )
 
-// Создаю переменнцю с путями конфигов TODO: Сделать более корректное сохранение путей
-var paths = []string{
-	"/eidolon/service/config",
-}
+// Каталог с конфигами по умолчанию
+const defaultConfigDir = "/eidolon/service/config"
+
+// Каталог сгенерированного ocserv.conf
+const ocservDir = "/eidolon/service/ocserv"
 
 func main() {
-	var mainConfig structures.MainConfig
-	err := config.LoadConfig("main", paths, &mainConfig)
+	// Флаги командной строки: --config-dir и переопределения --<конфиг>.<ключ>
+	configDir := pflag.String("config-dir", defaultConfigDir, "directory with main.yaml and openconnect.yaml")
+	restore := pflag.String("restore", "", "restore database, certs and configs from a backup archive and exit")
+	config.RegisterFlags(pflag.CommandLine, "main", structures.MainConfig{})
+	config.RegisterFlags(pflag.CommandLine, "openconnect", structures.OpenConnectConfig{})
+	config.RegisterFlags(pflag.CommandLine, "telegram", structures.TelegramConfig{})
+	pflag.Parse()
+	config.UseFlags(pflag.CommandLine)
+
+	// Конфиги загружаются один раз, подсистемы читают общий снимок
+	registry, err := config.NewRegistry([]string{*configDir})
+	if err != nil {
+		log.Fatalf("Critical: failed to load config: %v", err)
+	}
+	snapshot := registry.Current()
+	mainConfig := snapshot.Main
+
+	// Восстановление из архива выполняется до открытия базы
+	if *restore != "" {
+		err = backup.Restore(context.Background(), *restore, mainConfig.Storage.DatabasePath,
+			backup.SourcesFor(snapshot, registry.Paths(), ocservDir))
+		if err != nil {
+			log.Fatalf("Fatal: restore failed: %v", err)
+		}
+		return
+	}
+
+	// База пользователей, сертификатов и сессий; миграции применяются при открытии
+	db, err := storage.Open(mainConfig.Storage.DatabasePath)
 	if err != nil {
-		log.Fatalf("Critical: failed to load main config: %v", err)
+		log.Fatalf("Fatal: unable to open database: %v", err)
 	}
+	defer db.Close()
 
 	OCconfig, err := openconnect.SearchOCconfig("/eidolon/service/ocserv/ocserv.conf")
 	if err != nil {
@@ -30,7 +78,7 @@ func main() {
 		os.MkdirAll("/eidolon/service/ocserv", 0755)
 
 		// Генерируем конфигурацию
-		err = openconnect.GenerateOCconfig("/eidolon/service/config", "/eidolon/service/ocserv/ocserv.conf")
+		err = openconnect.GenerateOCconfig(snapshot.OpenConnect, "/eidolon/service/ocserv/ocserv.conf")
 		if err != nil {
 			handlers.OpenConnectYamlErrHandler(OCconfig, err)
 		} else {
@@ -42,37 +90,265 @@ func main() {
 		}
 	}
 
-	OCcert, OCkey, err := openconnect.GenerateSSLcert("/eidolon/service/certs/")
-	if err != nil {
-		log.Fatalf("Fatal: unable to generate\\locate ssl certs: %v", err)
+	// Собственный CA eidolon подписывает серверный и клиентские сертификаты
+	var authority *pki.Authority
+	security := snapshot.OpenConnect.Security
+	if security.ClientCA != "" && security.ClientCAKey != "" {
+		authority, err = pki.LoadOrCreateAuthority(security.ClientCA, security.ClientCAKey,
+			filepath.Join(mainConfig.Storage.DataDir, "users"), snapshot.OpenConnect.Name, security.ClientKey)
+		if err != nil {
+			log.Fatalf("Fatal: unable to load or create CA: %v", err)
+		}
+
+		// CRL строится из отзывов в базе и перевыпускается при запуске, отзыве и до истечения.
+		// Отзывы из прежнего revoked.json переносятся в базу один раз
+		if security.CRL != "" {
+			store := pki.NewDBRevocationStore(db)
+			imported, err := store.ImportFileRevocations(filepath.Join(filepath.Dir(security.ClientCA), "revoked.json"))
+			if err != nil {
+				log.Fatalf("Fatal: unable to import revoked certs: %v", err)
+			}
+			if imported > 0 {
+				utils.DebugPrint(fmt.Sprintf("Imported %d revoked certs into database", imported))
+			}
+			err = authority.EnableCRL(security.CRL, store)
+			if err != nil {
+				log.Fatalf("Fatal: unable to write CRL: %v", err)
+			}
+		}
+
+		utils.DebugPrint(fmt.Sprintf("Using CA %s", authority.CertPath()))
+	}
+
+	// Серверный сертификат: от собственного CA, иначе самоподписанный.
+	// В режиме ACME самоподписанный служит заглушкой до получения настоящего
+	if authority != nil && !security.ACME.Enabled {
+		OCcert := filepath.Join(security.CAPath, security.CACert)
+		OCkey := filepath.Join(security.CAPath, security.CAKey)
+		err = authority.EnsureServerCert(snapshot.OpenConnect, OCcert, OCkey)
+		if err != nil {
+			log.Fatalf("Fatal: unable to issue server cert: %v", err)
+		}
+		utils.DebugPrint(fmt.Sprintf("Issued server cert by CA:\nOCcert: %s\nOCkey: %s", OCcert, OCkey))
 	} else {
-		utils.DebugPrint(fmt.Sprintf("Succesfully generated SSL certs:\nOCcert: %s\nOCkey: %s", OCcert, OCkey))
+		OCcert, OCkey, err := openconnect.GenerateSSLcert(snapshot.OpenConnect, security.CAPath)
+		if err != nil {
+			log.Fatalf("Fatal: unable to generate\\locate ssl certs: %v", err)
+		} else {
+			utils.DebugPrint(fmt.Sprintf("Succesfully generated SSL certs:\nOCcert: %s\nOCkey: %s", OCcert, OCkey))
+		}
+	}
+
+	// Файл паролей ocserv строится из пользователей базы
+	var passwdSync *passwd.Syncer
+	if passwdPath, ok := passwd.PathFromAuth(security.Auth); ok {
+		passwdSync = passwd.NewSyncer(db, passwd.NewFile(passwdPath))
+		_, err = passwdSync.Sync(context.Background())
+		if err != nil {
+			utils.DebugPrint(fmt.Sprintf("Failed to sync passwd file: %v", err))
+		}
+	}
+
+	// Персональные маршруты раскладываются в config-per-user до запуска ocserv
+	userRoutes := routes.NewService(registry, db)
+	_, err = userRoutes.Sync(context.Background())
+	if err != nil {
+		utils.DebugPrint(fmt.Sprintf("Failed to sync user routes: %v", err))
 	}
 
+	// Политики групп раскладываются в config-per-group и default-group-config
+	groupPolicies := groups.NewService(registry, db, passwdSync)
+	_, err = groupPolicies.Sync(context.Background())
+	if err != nil {
+		utils.DebugPrint(fmt.Sprintf("Failed to sync group policies: %v", err))
+	}
+
+	// Блокировка и удаление пользователей сразу переносятся в файл паролей и config-per-user
+	userService := users.NewService(db, passwdSync, userRoutes)
+
 	// Правильнее обрабатывать обе ошибки
-	ocs, err := openconnect.NewManager(OCconfig)
+	ocs, err := openconnect.NewManager(registry, OCconfig)
 	if err != nil {
 		utils.DebugPrint("Failed to define OCManager")
 	}
 
-	err = ocs.Start()
+	// Завершаемся по SIGINT/SIGTERM (docker stop), останавливая ocserv
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	// Шина событий для уведомлений: падения ocserv, сертификаты, бэкапы
+	bus := events.NewBus()
+
+	// Супервизор перезапускает ocserv после падений
+	supervisor := openconnect.NewSupervisor(ocs)
+	supervisor.OnCrash(func(status openconnect.SupervisorStatus) {
+		event := events.Event{Kind: events.OcservCrash, Message: "ocserv завершился: " + status.LastReason}
+		if status.GaveUp {
+			event.Key = "gave_up"
+			event.Message += ". Перезапуски остановлены из-за частых падений"
+		}
+		bus.Publish(event)
+	})
+	err = supervisor.Run(ctx)
 	if err != nil {
 		utils.DebugPrint("Failed to start ocserv")
 	}
 
+	// ocserv читает файл паролей при каждом входе, SIGHUP обновляет группы
+	if passwdSync != nil {
+		passwdSync.OnChange(func() {
+			if ocs.IsRunning() {
+				ocs.Signal(syscall.SIGHUP)
+			}
+		})
+		go passwdSync.Run(ctx)
+	}
+	go userRoutes.Run(ctx)
+	go groupPolicies.Run(ctx)
+
+	// Резервное копирование базы, сертификатов и конфигов
+	backups := backup.NewScheduler(registry, db, ocservDir)
+	backups.OnResult(func(result backup.Result) {
+		if result.Err != nil {
+			bus.Publish(events.Event{Kind: events.BackupFailed, Message: result.Err.Error()})
+		}
+	})
+
+	// Продление серверного сертификата с перезагрузкой ocserv
+	var issuer pki.ServerCertIssuer = pki.SelfSignedIssuer{}
+	switch {
+	case security.ACME.Enabled:
+		issuer = pki.NewACMEIssuer(security.ACME)
+	case authority != nil:
+		issuer = authority
+	}
+	renewer := pki.NewRenewer(registry, issuer)
+	renewer.OnRenew(func(status pki.CertStatus) {
+		utils.DebugPrint(fmt.Sprintf("Server certificate renewed, valid until %s", status.NotAfter))
+		if ocs.IsRunning() {
+			ocs.Signal(syscall.SIGHUP)
+		}
+	})
+	renewer.OnExpiring(func(status pki.CertStatus) {
+		message := fmt.Sprintf("Серверный сертификат истекает %s, осталось дней: %d. Запущено продление",
+			status.NotAfter.Format("2006-01-02"), status.DaysLeft)
+		bus.Publish(events.Event{Kind: events.CertExpiring, Key: "server-expiry", Message: message})
+	})
+	renewer.OnFailure(func(status pki.CertStatus) {
+		message := fmt.Sprintf("Не удалось продлить серверный сертификат: %v", status.Err)
+		if !status.NotAfter.IsZero() {
+			message += fmt.Sprintf(". Текущий действует до %s", status.NotAfter.Format("2006-01-02"))
+		}
+		bus.Publish(events.Event{Kind: events.CertExpiring, Key: "server", Message: message})
+	})
+
+	// Telegram бот; настройки читаются при запуске, изменение токена требует перезапуска
+	botDone := make(chan struct{})
+	if snapshot.Telegram.Enabled {
+		router := telegram.NewRouter()
+		router.Use(telegram.Recover(), telegram.Logger())
+		telegramHandlers.NewOnboarding(registry, db, passwdSync, authority).Register(router)
+		admin := telegramHandlers.NewAdmin(registry, db, ocs, supervisor, renewer)
+		admin.Register(router)
+		go admin.EnforceBans(ctx)
+		telegramHandlers.NewRoutes(registry, userRoutes).Register(router)
+		telegramHandlers.NewGroups(registry, groupPolicies).Register(router)
+		telegramHandlers.NewUsers(registry, userService, ocs).Register(router)
+		if authority != nil {
+			telegramHandlers.NewCerts(registry, db, authority, ocs).Register(router)
+		}
+		router.Handle("help", "Список команд", func(c *telegram.Context) error {
+			text := "Команды:\n"
+			for _, command := range router.Commands() {
+				text += fmt.Sprintf("/%s - %s\n", command.Command, html.EscapeString(command.Description))
+			}
+			return c.Reply(text)
+		})
+
+		bot := telegram.NewBot(snapshot.Telegram, router)
+		notifier := telegramHandlers.NewNotifier(registry, db, bot.Client())
+		notifier.Register(router)
+		bus.Subscribe(ctx, notifier.Handle)
+		go func() {
+			defer close(botDone)
+			err := bot.Run(ctx)
+			if err != nil {
+				utils.DebugPrint(fmt.Sprintf("Telegram bot stopped: %v", err))
+			}
+		}()
+	} else {
+		close(botDone)
+	}
+
+	// HTTP API; адрес и TLS читаются при запуске, как у webhook бота
+	apiDone := make(chan struct{})
+	if snapshot.Main.API.Enabled {
+		go func() {
+			defer close(apiDone)
+			err := api.NewServer(registry, userRoutes).Run(ctx)
+			if err != nil {
+				utils.DebugPrint(fmt.Sprintf("API server stopped: %v", err))
+			}
+		}()
+	} else {
+		close(apiDone)
+	}
+
+	// Проверки, публикующие события, стартуют после подписки уведомлений
+	go backups.Run(ctx)
+	go renewer.Run(ctx)
+	go events.WatchClientCerts(ctx, db, bus)
+	go events.WatchTraffic(ctx, registry, db, ocs, bus)
+	if authority != nil && authority.CRLPath() != "" {
+		go authority.RefreshCRL(ctx, func() {
+			if ocs.IsRunning() {
+				ocs.Signal(syscall.SIGHUP)
+			}
+		})
+	}
+
 	if ocs.IsRunning() {
 		utils.DebugPrint("OpenConnect is running")
 	} else {
 		utils.DebugPrint("OpenConnect is not running")
 	}
 
+	// Следим за конфигами и применяем изменения на лету
+	ocs.OnReload(func(event openconnect.ReloadEvent) {
+		utils.DebugPrint(fmt.Sprintf("OpenConnect config reloaded: keys=%v restart=%v err=%v", event.ChangedKeys, event.Restart, event.Err))
+	})
+	err = registry.Watch(ctx)
+	if err != nil {
+		utils.DebugPrint(fmt.Sprintf("Failed to watch config: %v", err))
+	}
+
 	// Времнные дебаги для теста контейнера
 	utils.DebugPrint(fmt.Sprintf("Hello, %s!", utils.СmdExec("whoami")))
 	utils.DebugPrint(fmt.Sprintf("Debug: %s", utils.СmdExec("uname -r")))
-	utils.DebugPrint(fmt.Sprintf("Main Config: %+v", mainConfig))
+	printable := mainConfig
+	if printable.API.Token != "" {
+		printable.API.Token = "<hidden>"
+	}
+	utils.DebugPrint(fmt.Sprintf("Main Config: %+v", printable))
 
 	utils.DebugPrint(fmt.Sprintf("Service config containment: %s", mainConfig.Service.Host))
 
 	utils.DebugPrint(fmt.Sprintf("OCconfig: %s", OCconfig))
 
+	<-ctx.Done()
+	utils.DebugPrint("Shutting down")
+	<-botDone
+	<-apiDone
+
+	if ocs.IsRunning() {
+		stopCtx, stopCancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer stopCancel()
+
+		status, err := ocs.Stop(stopCtx)
+		if err != nil {
+			utils.DebugPrint(fmt.Sprintf("Failed to stop ocserv: %v", err))
+		} else {
+			utils.DebugPrint(fmt.Sprintf("ocserv stopped with code %d", status.Code))
+		}
+	}
 }
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"eidolonVPN/internal/config"
+	"eidolonVPN/internal/errors"
+	"eidolonVPN/internal/routes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Сколько ждать завершения запросов при остановке сервера
+const shutdownTimeout = 5 * time.Second
+
+// Максимальный размер тела запроса
+const maxBodySize = 1 << 20
+
+// Server - HTTP API управления eidolon. Все запросы требуют
+// заголовок Authorization: Bearer <api.token>
+type Server struct {
+	registry *config.Registry
+	routes   *routes.Service
+}
+
+// NewServer создает HTTP API
+func NewServer(registry *config.Registry, routes *routes.Service) *Server {
+	return &Server{registry: registry, routes: routes}
+}
+
+// Run обслуживает api.listen до отмены контекста.
+// Адрес и TLS читаются при запуске, токен - при каждом запросе
+func (s *Server) Run(ctx context.Context) error {
+	api := s.registry.Current().Main.API
+
+	server := &http.Server{
+		Addr:              api.Listen,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		if api.CertFile != "" {
+			serveErr <- server.ListenAndServeTLS(api.CertFile, api.KeyFile)
+		} else {
+			serveErr <- server.ListenAndServe()
+		}
+	}()
+
+	select {
+	case err := <-serveErr:
+		return errors.CallAPIError(fmt.Sprintf("API server on %s failed", api.Listen), err)
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	server.Shutdown(shutdownCtx)
+	return nil
+}
+
+// Handler возвращает HTTP обработчик API
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/routes", s.listRoutes)
+	mux.HandleFunc("GET /api/v1/users/{name}/routes", s.getRoutes)
+	mux.HandleFunc("PUT /api/v1/users/{name}/routes", s.setRoutes)
+	mux.HandleFunc("DELETE /api/v1/users/{name}/routes", s.clearRoutes)
+	return s.authorize(mux)
+}
+
+// authorize пропускает только запросы с токеном api.token
+func (s *Server) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Без настроенного токена запросы не принимаются вовсе
+		token := s.registry.Current().Main.API.Token
+		header, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" || !ok || subtle.ConstantTimeCompare([]byte(header), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// writeJSON отправляет ответ в JSON
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+// writeError отправляет ошибку в виде {"error": "..."}
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
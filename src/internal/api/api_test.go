@@ -0,0 +1,203 @@
+package api
+
+import (
+	"context"
+	"eidolonVPN/internal/config"
+	"eidolonVPN/internal/routes"
+	"eidolonVPN/internal/storage"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testToken = "0123456789abcdef0123456789abcdef"
+
+// testServer запускает API с каталогом config-per-user perUser и пользователем alice
+func testServer(t *testing.T, perUser string) (*httptest.Server, *storage.DB) {
+	t.Helper()
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"main.yaml": fmt.Sprintf(`logging: {level: info, format: text}
+storage: {database_path: /tmp/eidolon.db, data_dir: /tmp}
+api: {enabled: true, listen: "127.0.0.1:0", token: %q}
+`, testToken),
+		"openconnect.yaml": fmt.Sprintf(`server: "vpn.example.com"
+port: 443
+protocol: tcp
+interface: eidolon0
+socket: /run/ocserv.socket
+security:
+  auth: "plain[passwd=/etc/ocserv/passwd]"
+network: {mtu: 1400, lan: 10.20.30.0, lan_mask: 255.255.255.0, config_per_user: %q}
+`, perUser),
+	}
+	for name, content := range files {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	registry, err := config.NewRegistry([]string{dir})
+	if err != nil {
+		t.Fatalf("NewRegistry: %v", err)
+	}
+
+	db, err := storage.Open(filepath.Join(dir, "eidolon.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	err = db.Users().Create(context.Background(), &storage.User{Username: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(NewServer(registry, routes.NewService(registry, db)).Handler())
+	t.Cleanup(server.Close)
+	return server, db
+}
+
+// request выполняет запрос с токеном token и возвращает код и тело ответа
+func request(t *testing.T, server *httptest.Server, method, path, token, body string) (int, string) {
+	t.Helper()
+
+	req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(data)
+}
+
+func TestAuthorization(t *testing.T) {
+	server, _ := testServer(t, t.TempDir())
+
+	tests := []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{"without token", "", http.StatusUnauthorized},
+		{"wrong token", strings.Repeat("x", len(testToken)), http.StatusUnauthorized},
+		{"token prefix", testToken[:8], http.StatusUnauthorized},
+		{"valid token", testToken, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := request(t, server, http.MethodGet, "/api/v1/routes", tt.token, "")
+			if status != tt.status {
+				t.Errorf("status %d, want %d: %s", status, tt.status, body)
+			}
+		})
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	perUser := t.TempDir()
+	server, db := testServer(t, perUser)
+	path := "/api/v1/users/alice/routes"
+
+	status, body := request(t, server, http.MethodGet, path, testToken, "")
+	if status != http.StatusOK || !strings.Contains(body, `"routes":[]`) {
+		t.Fatalf("GET empty routes: %d %s", status, body)
+	}
+
+	status, body = request(t, server, http.MethodPut, path, testToken,
+		`{"routes": ["10.0.0.0/8"], "dns": ["1.1.1.1"], "ipv4_network": "192.168.50.0/24"}`)
+	if status != http.StatusOK {
+		t.Fatalf("PUT: %d %s", status, body)
+	}
+	var set userRoutes
+	if err := json.Unmarshal([]byte(body), &set); err != nil {
+		t.Fatal(err)
+	}
+	if set.Username != "alice" || len(set.Routes) != 1 || set.UpdatedAt == nil {
+		t.Errorf("PUT response: %s", body)
+	}
+
+	data, err := os.ReadFile(filepath.Join(perUser, "alice"))
+	if err != nil {
+		t.Fatalf("config-per-user file: %v", err)
+	}
+	if !strings.Contains(string(data), "route = 10.0.0.0/8\n") {
+		t.Errorf("config-per-user file:\n%s", data)
+	}
+
+	status, body = request(t, server, http.MethodGet, "/api/v1/routes", testToken, "")
+	if status != http.StatusOK || !strings.Contains(body, `"username":"alice"`) {
+		t.Errorf("GET list: %d %s", status, body)
+	}
+
+	events, err := db.Audit().List(context.Background(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Action != "routes.set" || events[0].Actor != "api" {
+		t.Errorf("audit events: %+v", events)
+	}
+
+	status, body = request(t, server, http.MethodDelete, path, testToken, "")
+	if status != http.StatusNoContent {
+		t.Fatalf("DELETE: %d %s", status, body)
+	}
+	if _, err = os.Stat(filepath.Join(perUser, "alice")); !os.IsNotExist(err) {
+		t.Errorf("config-per-user file after DELETE: %v", err)
+	}
+}
+
+func TestRoutesErrors(t *testing.T) {
+	server, _ := testServer(t, t.TempDir())
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{"unknown user", http.MethodGet, "/api/v1/users/bob/routes", "", http.StatusNotFound},
+		{"put unknown user", http.MethodPut, "/api/v1/users/bob/routes", `{"dns": ["1.1.1.1"]}`, http.StatusNotFound},
+		{"invalid route", http.MethodPut, "/api/v1/users/alice/routes", `{"routes": ["10.0.0.0/33"]}`, http.StatusBadRequest},
+		{"unknown field", http.MethodPut, "/api/v1/users/alice/routes", `{"route": ["10.0.0.0/8"]}`, http.StatusBadRequest},
+		{"malformed body", http.MethodPut, "/api/v1/users/alice/routes", `{"routes":`, http.StatusBadRequest},
+		{"method not allowed", http.MethodPost, "/api/v1/users/alice/routes", "{}", http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := request(t, server, tt.method, tt.path, testToken, tt.body)
+			if status != tt.status {
+				t.Errorf("status %d, want %d: %s", status, tt.status, body)
+			}
+		})
+	}
+}
+
+func TestRoutesDisabled(t *testing.T) {
+	server, _ := testServer(t, "")
+
+	status, body := request(t, server, http.MethodGet, "/api/v1/users/alice/routes", testToken, "")
+	if status != http.StatusConflict {
+		t.Errorf("status %d, want %d: %s", status, http.StatusConflict, body)
+	}
+}
@@ -0,0 +1,138 @@
+package api
+
+import (
+	"eidolonVPN/internal/routes"
+	"eidolonVPN/internal/storage"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Действия через API пишутся в журнал от этого имени
+const actor = "api"
+
+// userRoutes - персональные маршруты пользователя в JSON
+type userRoutes struct {
+	Username    string     `json:"username,omitempty"`
+	Routes      []string   `json:"routes"`
+	NoRoutes    []string   `json:"no_routes"`
+	DNS         []string   `json:"dns"`
+	IPv4Network string     `json:"ipv4_network"`
+	UpdatedAt   *time.Time `json:"updated_at,omitempty"`
+}
+
+// newUserRoutes переводит настройки из базы в JSON; пустые списки отдаются как []
+func newUserRoutes(r storage.UserRoutes) userRoutes {
+	body := userRoutes{
+		Username:    r.Username,
+		Routes:      append([]string{}, r.Routes...),
+		NoRoutes:    append([]string{}, r.NoRoutes...),
+		DNS:         append([]string{}, r.DNS...),
+		IPv4Network: r.IPv4Network,
+	}
+	if !r.UpdatedAt.IsZero() {
+		body.UpdatedAt = &r.UpdatedAt
+	}
+	return body
+}
+
+// enabled отвечает 409, если каталог config-per-user не задан
+func (s *Server) enabled(w http.ResponseWriter) bool {
+	if !s.routes.Enabled() {
+		writeError(w, http.StatusConflict, "personal routes are disabled: network.config_per_user is not set")
+		return false
+	}
+	return true
+}
+
+// routesFailed переводит ошибку сервиса маршрутов в ответ
+func routesFailed(w http.ResponseWriter, username string, err error) {
+	if err == storage.ErrNotFound {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("user %s not found", username))
+		return
+	}
+	fmt.Printf("API request for routes of %s failed: %v\n", username, err)
+	writeError(w, http.StatusInternalServerError, err.Error())
+}
+
+// listRoutes - GET /api/v1/routes: пользователи с персональными маршрутами
+func (s *Server) listRoutes(w http.ResponseWriter, r *http.Request) {
+	if !s.enabled(w) {
+		return
+	}
+	list, err := s.routes.List(r.Context())
+	if err != nil {
+		routesFailed(w, "all users", err)
+		return
+	}
+
+	body := make([]userRoutes, 0, len(list))
+	for _, item := range list {
+		body = append(body, newUserRoutes(item))
+	}
+	writeJSON(w, http.StatusOK, body)
+}
+
+// getRoutes - GET /api/v1/users/{name}/routes
+func (s *Server) getRoutes(w http.ResponseWriter, r *http.Request) {
+	if !s.enabled(w) {
+		return
+	}
+	username := r.PathValue("name")
+	current, err := s.routes.Get(r.Context(), username)
+	if err != nil {
+		routesFailed(w, username, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newUserRoutes(*current))
+}
+
+// setRoutes - PUT /api/v1/users/{name}/routes: заменяет настройки пользователя целиком
+func (s *Server) setRoutes(w http.ResponseWriter, r *http.Request) {
+	if !s.enabled(w) {
+		return
+	}
+	username := r.PathValue("name")
+
+	var body userRoutes
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid body: %v", err))
+		return
+	}
+	update := storage.UserRoutes{
+		Routes:      body.Routes,
+		NoRoutes:    body.NoRoutes,
+		DNS:         body.DNS,
+		IPv4Network: body.IPv4Network,
+	}
+	err = routes.Validate(update)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	set, err := s.routes.Set(r.Context(), username, update, actor)
+	if err != nil {
+		routesFailed(w, username, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newUserRoutes(*set))
+}
+
+// clearRoutes - DELETE /api/v1/users/{name}/routes
+func (s *Server) clearRoutes(w http.ResponseWriter, r *http.Request) {
+	if !s.enabled(w) {
+		return
+	}
+	username := r.PathValue("name")
+	err := s.routes.Clear(r.Context(), username, actor)
+	if err != nil {
+		routesFailed(w, username, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,326 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"eidolonVPN/internal/errors"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Имена внутри архива
+const (
+	databaseEntry = "database.db"
+	manifestEntry = "manifest.json"
+	archivePrefix = "eidolon-"
+	archiveSuffix = ".tar.gz"
+	checksumExt   = ".sha256"
+)
+
+// Source - каталог, добавляемый в архив под именем Name
+type Source struct {
+	Name string
+	Dir  string
+}
+
+// Manifest описывает содержимое архива
+type Manifest struct {
+	CreatedAt     time.Time         `json:"created_at"`
+	SchemaVersion int               `json:"schema_version"`
+	Sources       []string          `json:"sources"`
+	Files         map[string]string `json:"files"` // Путь в архиве -> sha256
+}
+
+// writeArchive упаковывает снимок базы и каталоги в tar.gz и возвращает sha256 архива
+func writeArchive(target, databaseFile string, sources []Source, manifest Manifest) (string, int64, error) {
+	tmp := target + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return "", 0, errors.CallBackupError(fmt.Sprintf("Failed to create %s", tmp), err)
+	}
+	defer os.Remove(tmp)
+
+	hash := sha256.New()
+	gz := gzip.NewWriter(io.MultiWriter(file, hash))
+	tw := tar.NewWriter(gz)
+
+	manifest.Files = map[string]string{}
+	err = addFile(tw, databaseFile, databaseEntry, manifest.Files)
+	if err == nil {
+		for _, source := range sources {
+			manifest.Sources = append(manifest.Sources, source.Name)
+			err = addDir(tw, source, manifest.Files)
+			if err != nil {
+				break
+			}
+		}
+	}
+	if err == nil {
+		err = addManifest(tw, manifest)
+	}
+	if err == nil {
+		err = tw.Close()
+	}
+	if err == nil {
+		err = gz.Close()
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", 0, errors.CallBackupError(fmt.Sprintf("Failed to write archive %s", target), err)
+	}
+
+	info, err := os.Stat(tmp)
+	if err != nil {
+		return "", 0, errors.CallBackupError(fmt.Sprintf("Failed to stat %s", tmp), err)
+	}
+	err = os.Rename(tmp, target)
+	if err != nil {
+		return "", 0, errors.CallBackupError(fmt.Sprintf("Failed to move archive to %s", target), err)
+	}
+
+	checksum := hex.EncodeToString(hash.Sum(nil))
+	// Формат sha256sum, чтобы архив можно было проверить вручную
+	line := fmt.Sprintf("%s  %s\n", checksum, filepath.Base(target))
+	err = os.WriteFile(target+checksumExt, []byte(line), 0600)
+	if err != nil {
+		return "", 0, errors.CallBackupError(fmt.Sprintf("Failed to write checksum for %s", target), err)
+	}
+	return checksum, info.Size(), nil
+}
+
+// addDir добавляет содержимое каталога под префиксом source.Name
+func addDir(tw *tar.Writer, source Source, files map[string]string) error {
+	return filepath.Walk(source.Dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			// Отсутствующий каталог (например, сертификаты еще не выпущены) не ошибка
+			if os.IsNotExist(err) && file == source.Dir {
+				return nil
+			}
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(source.Dir, file)
+		if err != nil {
+			return err
+		}
+		return addFile(tw, file, path.Join(source.Name, filepath.ToSlash(rel)), files)
+	})
+}
+
+// addFile добавляет файл в архив и запоминает его контрольную сумму
+func addFile(tw *tar.Writer, file, name string, files map[string]string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	err = tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    int64(info.Mode().Perm()),
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+	})
+	if err != nil {
+		return err
+	}
+
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(tw, hash), f)
+	if err != nil {
+		return err
+	}
+	files[name] = hex.EncodeToString(hash.Sum(nil))
+	return nil
+}
+
+// addManifest записывает manifest.json последним элементом архива
+func addManifest(tw *tar.Writer, manifest Manifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = tw.WriteHeader(&tar.Header{
+		Name:    manifestEntry,
+		Mode:    0600,
+		Size:    int64(len(data)),
+		ModTime: manifest.CreatedAt,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = tw.Write(data)
+	return err
+}
+
+// VerifyChecksum сверяет архив с файлом .sha256 рядом с ним
+func VerifyChecksum(archive string) error {
+	data, err := os.ReadFile(archive + checksumExt)
+	if err != nil {
+		return errors.CallBackupError(fmt.Sprintf("Failed to read checksum of %s", archive), err)
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return errors.CallBackupError(fmt.Sprintf("Empty checksum file for %s", archive), nil)
+	}
+
+	file, err := os.Open(archive)
+	if err != nil {
+		return errors.CallBackupError(fmt.Sprintf("Failed to open %s", archive), err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	_, err = io.Copy(hash, file)
+	if err != nil {
+		return errors.CallBackupError(fmt.Sprintf("Failed to read %s", archive), err)
+	}
+	if actual := hex.EncodeToString(hash.Sum(nil)); actual != fields[0] {
+		return errors.CallBackupError(fmt.Sprintf("Checksum mismatch for %s: expected %s, got %s", archive, fields[0], actual), nil)
+	}
+	return nil
+}
+
+// extractArchive распаковывает архив в dir и проверяет файлы по манифесту
+func extractArchive(archive, dir string) (*Manifest, error) {
+	file, err := os.Open(archive)
+	if err != nil {
+		return nil, errors.CallBackupError(fmt.Sprintf("Failed to open %s", archive), err)
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, errors.CallBackupError(fmt.Sprintf("%s is not a gzip archive", archive), err)
+	}
+	defer gz.Close()
+
+	var manifest *Manifest
+	actual := map[string]string{}
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.CallBackupError(fmt.Sprintf("Failed to read %s", archive), err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		if header.Name == manifestEntry {
+			manifest = &Manifest{}
+			err = json.NewDecoder(tr).Decode(manifest)
+			if err != nil {
+				return nil, errors.CallBackupError("Failed to parse manifest", err)
+			}
+			continue
+		}
+
+		// Защита от выхода за пределы каталога распаковки
+		name := path.Clean(header.Name)
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return nil, errors.CallBackupError(fmt.Sprintf("Unsafe path %q in archive", header.Name), nil)
+		}
+
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		checksum, err := extractFile(tr, target, os.FileMode(header.Mode).Perm())
+		if err != nil {
+			return nil, errors.CallBackupError(fmt.Sprintf("Failed to extract %s", name), err)
+		}
+		actual[name] = checksum
+	}
+
+	if manifest == nil {
+		return nil, errors.CallBackupError(fmt.Sprintf("No manifest in %s", archive), nil)
+	}
+	if _, ok := manifest.Files[databaseEntry]; !ok {
+		return nil, errors.CallBackupError(fmt.Sprintf("No database in %s", archive), nil)
+	}
+	for name, expected := range manifest.Files {
+		if actual[name] != expected {
+			return nil, errors.CallBackupError(fmt.Sprintf("File %s is missing or damaged", name), nil)
+		}
+	}
+	return manifest, nil
+}
+
+// extractFile записывает один файл и возвращает его sha256
+func extractFile(r io.Reader, target string, perm os.FileMode) (string, error) {
+	err := os.MkdirAll(filepath.Dir(target), 0755)
+	if err != nil {
+		return "", err
+	}
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(f, hash), r)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// listArchives возвращает архивы eidolon в каталоге от старых к новым
+func listArchives(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.CallBackupError(fmt.Sprintf("Failed to list %s", dir), err)
+	}
+
+	var archives []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || !strings.HasPrefix(name, archivePrefix) || !strings.HasSuffix(name, archiveSuffix) {
+			continue
+		}
+		// Архивы с другими именами (например, eidolon-manual.tar.gz) положил оператор:
+		// они не участвуют ни в расписании, ни в ротации
+		if _, ok := archiveTime(name); !ok {
+			continue
+		}
+		archives = append(archives, filepath.Join(dir, name))
+	}
+	// Имя содержит время создания, лексикографический порядок совпадает с хронологическим
+	sort.Strings(archives)
+	return archives, nil
+}
+
+// archiveTime извлекает время создания из имени архива
+func archiveTime(archive string) (time.Time, bool) {
+	name := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(archive), archivePrefix), archiveSuffix)
+	t, err := time.ParseInLocation(timestampLayout, name, time.Local)
+	return t, err == nil
+}
@@ -0,0 +1,158 @@
+package backup
+
+import (
+	"context"
+	"eidolonVPN/internal/errors"
+	"eidolonVPN/internal/storage"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Суффикс, с которым сохраняются замененные при восстановлении файлы
+const preRestoreSuffix = ".pre-restore"
+
+// Restore проверяет архив и подменяет им базу и каталоги.
+// База должна быть закрыта; прежние данные остаются рядом с суффиксом .pre-restore
+func Restore(ctx context.Context, archive, databasePath string, sources []Source) error {
+	// Контрольная сумма необязательна: архив могли скопировать без .sha256
+	if _, err := os.Stat(archive + checksumExt); err == nil {
+		err = VerifyChecksum(archive)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Распаковываем рядом с базой, чтобы подмена была переименованием
+	err := os.MkdirAll(filepath.Dir(databasePath), 0755)
+	if err != nil {
+		return errors.CallBackupError(fmt.Sprintf("Failed to create directory for %s", databasePath), err)
+	}
+	staging, err := os.MkdirTemp(filepath.Dir(databasePath), ".eidolon-restore-")
+	if err != nil {
+		return errors.CallBackupError("Failed to create staging directory", err)
+	}
+	defer os.RemoveAll(staging)
+
+	manifest, err := extractArchive(archive, staging)
+	if err != nil {
+		return err
+	}
+
+	stagedDB := filepath.Join(staging, databaseEntry)
+	err = storage.CheckIntegrity(ctx, stagedDB)
+	if err != nil {
+		return errors.CallBackupError(fmt.Sprintf("Database in %s failed validation", archive), err)
+	}
+
+	// Архив проверен целиком, дальше только подмена
+	err = swapDatabase(stagedDB, databasePath)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Restored database %s from %s\n", databasePath, archive)
+
+	restored := map[string]bool{}
+	for _, name := range manifest.Sources {
+		restored[name] = true
+	}
+	for _, source := range sources {
+		if !restored[source.Name] {
+			continue
+		}
+		err = swapDir(filepath.Join(staging, source.Name), source.Dir)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Restored %s from %s\n", source.Dir, archive)
+	}
+	return nil
+}
+
+// swapDatabase заменяет файл базы, перенося прежний вместе с WAL
+func swapDatabase(staged, target string) error {
+	backup := target + preRestoreSuffix
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		os.Remove(backup + suffix)
+		err := os.Rename(target+suffix, backup+suffix)
+		if err != nil && !os.IsNotExist(err) {
+			return errors.CallBackupError(fmt.Sprintf("Failed to move %s aside", target+suffix), err)
+		}
+	}
+
+	err := os.Rename(staged, target)
+	if err != nil {
+		return errors.CallBackupError(fmt.Sprintf("Failed to move restored database to %s", target), err)
+	}
+	return nil
+}
+
+// swapDir заменяет каталог восстановленным. Каталоги могут быть на другом разделе,
+// поэтому содержимое сначала копируется рядом с целевым каталогом
+func swapDir(staged, target string) error {
+	tmp := target + ".restore-tmp"
+	os.RemoveAll(tmp)
+
+	err := copyDir(staged, tmp)
+	if err != nil {
+		os.RemoveAll(tmp)
+		return errors.CallBackupError(fmt.Sprintf("Failed to stage %s", target), err)
+	}
+
+	backup := target + preRestoreSuffix
+	os.RemoveAll(backup)
+	err = os.Rename(target, backup)
+	if err != nil && !os.IsNotExist(err) {
+		return errors.CallBackupError(fmt.Sprintf("Failed to move %s aside", target), err)
+	}
+
+	err = os.Rename(tmp, target)
+	if err != nil {
+		return errors.CallBackupError(fmt.Sprintf("Failed to move restored files to %s", target), err)
+	}
+	return nil
+}
+
+// copyDir рекурсивно копирует каталог с правами файлов
+func copyDir(src, dst string) error {
+	err := os.MkdirAll(dst, 0755)
+	if err != nil {
+		return err
+	}
+	return filepath.Walk(src, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, file)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		if info.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		return copyFile(file, target, info.Mode().Perm())
+	})
+}
+
+// copyFile копирует один файл
+func copyFile(src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,238 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"eidolonVPN/internal/storage"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// tarEntry - элемент архива, собранного вручную
+type tarEntry struct {
+	name string
+	data string
+}
+
+// testDatabase создает базу с пользователем username и возвращает путь к ее снимку
+func testDatabase(t *testing.T, dir, username string) string {
+	t.Helper()
+
+	db, err := storage.Open(filepath.Join(dir, "source.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	err = db.Users().Create(ctx, &storage.User{Username: username})
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshot := filepath.Join(dir, "snapshot.db")
+	err = db.Snapshot(ctx, snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return snapshot
+}
+
+// writeRawArchive собирает tar.gz из entries с манифестом, в котором указаны
+// контрольные суммы files (nil - суммы всех entries)
+func writeRawArchive(t *testing.T, target string, entries []tarEntry, files map[string]string) {
+	t.Helper()
+
+	if files == nil {
+		files = map[string]string{}
+		for _, entry := range entries {
+			files[entry.name] = sum([]byte(entry.data))
+		}
+	}
+	manifest, err := json.Marshal(Manifest{CreatedAt: time.Now(), Files: files})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries = append(entries, tarEntry{manifestEntry, string(manifest)})
+
+	file, err := os.Create(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	gz := gzip.NewWriter(file)
+	tw := tar.NewWriter(gz)
+	for _, entry := range entries {
+		err = tw.WriteHeader(&tar.Header{Name: entry.name, Mode: 0600, Size: int64(len(entry.data))})
+		if err == nil {
+			_, err = tw.Write([]byte(entry.data))
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = tw.Close(); err == nil {
+		err = gz.Close()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// usernames возвращает пользователей базы
+func usernames(t *testing.T, path string) []string {
+	t.Helper()
+
+	db, err := storage.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	users, err := db.Users().List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, user := range users {
+		names = append(names, user.Username)
+	}
+	return names
+}
+
+func TestRestoreRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	certs := filepath.Join(dir, "certs")
+	err := os.MkdirAll(filepath.Join(certs, "clients"), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	touch(t, certs, "server-cert.pem")
+	err = os.WriteFile(filepath.Join(certs, "clients", "alice.pem"), []byte("alice"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	archive := filepath.Join(dir, "eidolon-20260101-030000.tar.gz")
+	sources := []Source{{Name: "certs", Dir: certs}}
+	_, _, err = writeArchive(archive, testDatabase(t, dir, "alice"), sources, Manifest{CreatedAt: time.Now()})
+	if err != nil {
+		t.Fatalf("writeArchive: %v", err)
+	}
+	if err = VerifyChecksum(archive); err != nil {
+		t.Fatalf("VerifyChecksum: %v", err)
+	}
+
+	// Текущие данные отличаются от архива
+	databasePath := filepath.Join(dir, "data", "eidolon.db")
+	testDatabase(t, filepath.Dir(databasePath), "bob")
+	err = os.Rename(filepath.Join(filepath.Dir(databasePath), "snapshot.db"), databasePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(certs, "clients", "alice.pem"), []byte("changed"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = Restore(context.Background(), archive, databasePath, sources)
+	if err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if names := usernames(t, databasePath); len(names) != 1 || names[0] != "alice" {
+		t.Errorf("restored users %q, want [alice]", names)
+	}
+	if data, _ := os.ReadFile(filepath.Join(certs, "clients", "alice.pem")); string(data) != "alice" {
+		t.Errorf("restored certificate %q", data)
+	}
+	if names := usernames(t, databasePath+preRestoreSuffix); len(names) != 1 || names[0] != "bob" {
+		t.Errorf("previous database not kept: %q", names)
+	}
+}
+
+func TestRestoreRejectsArchive(t *testing.T) {
+	dir := t.TempDir()
+	database, err := os.ReadFile(testDatabase(t, dir, "alice"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := tarEntry{databaseEntry, string(database)}
+
+	tests := []struct {
+		name    string
+		prepare func(archive string)
+		want    string
+	}{
+		{"path traversal", func(archive string) {
+			writeRawArchive(t, archive, []tarEntry{db, {"certs/../../escaped", "evil"}}, nil)
+		}, "Unsafe path"},
+		{"absolute path", func(archive string) {
+			writeRawArchive(t, archive, []tarEntry{db, {"/tmp/escaped", "evil"}}, nil)
+		}, "Unsafe path"},
+		{"checksum mismatch", func(archive string) {
+			writeRawArchive(t, archive, []tarEntry{db}, nil)
+			line := strings.Repeat("0", 64) + "  " + filepath.Base(archive) + "\n"
+			err := os.WriteFile(archive+checksumExt, []byte(line), 0600)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}, "Checksum mismatch"},
+		{"damaged file", func(archive string) {
+			writeRawArchive(t, archive, []tarEntry{db, {"certs/ca.pem", "changed"}}, map[string]string{
+				databaseEntry:  sum(database),
+				"certs/ca.pem": sum([]byte("original")),
+			})
+		}, "missing or damaged"},
+		{"no database", func(archive string) {
+			writeRawArchive(t, archive, []tarEntry{{"certs/ca.pem", "ca"}}, nil)
+		}, "No database"},
+		{"corrupted database", func(archive string) {
+			writeRawArchive(t, archive, []tarEntry{{databaseEntry, "not a database"}}, nil)
+		}, "failed validation"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			work := t.TempDir()
+			archive := filepath.Join(work, "eidolon-20260101-030000.tar.gz")
+			tt.prepare(archive)
+
+			databasePath := filepath.Join(work, "data", "eidolon.db")
+			err := os.MkdirAll(filepath.Dir(databasePath), 0755)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = os.WriteFile(databasePath, []byte("current"), 0600)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = Restore(context.Background(), archive, databasePath, nil)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Restore error %v, want %q", err, tt.want)
+			}
+			// Проверка провалилась до подмены: база на месте, за пределы распаковки ничего не вышло
+			if data, _ := os.ReadFile(databasePath); string(data) != "current" {
+				t.Errorf("database replaced by rejected archive")
+			}
+			if _, err := os.Stat(filepath.Join(work, "data", "escaped")); err == nil {
+				t.Error("archive entry written outside staging directory")
+			}
+			if matches, _ := filepath.Glob(filepath.Join(work, "data", ".eidolon-restore-*")); len(matches) != 0 {
+				t.Errorf("staging directory left behind: %q", matches)
+			}
+		})
+	}
+}
+
+// sum возвращает sha256 данных в hex
+func sum(data []byte) string {
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
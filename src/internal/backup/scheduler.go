@@ -0,0 +1,259 @@
+package backup
+
+import (
+	"context"
+	"eidolonVPN/internal/config"
+	"eidolonVPN/internal/config/structures"
+	"eidolonVPN/internal/errors"
+	"eidolonVPN/internal/storage"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Формат времени в имени архива
+const timestampLayout = "20060102-150405"
+
+// Интервалы планировщика
+const (
+	recheckInterval = time.Hour        // Перечитывание настроек, пока до бэкапа далеко
+	retryInterval   = 15 * time.Minute // Повтор после неудачного бэкапа
+)
+
+// Result - итог одного резервного копирования
+type Result struct {
+	Path      string
+	Checksum  string // sha256 архива
+	Size      int64
+	StartedAt time.Time
+	Duration  time.Duration
+	Pruned    []string // Удаленные старые архивы
+	Err       error
+}
+
+// Scheduler создает резервные копии по StorageConfig.BackupConfig
+type Scheduler struct {
+	registry  *config.Registry
+	db        *storage.DB
+	ocservDir string // Каталог сгенерированного ocserv.conf
+	mutex     sync.Mutex
+	running   sync.Mutex // Не допускает параллельных бэкапов
+	last      Result
+	onResult  func(Result)
+}
+
+// NewScheduler создает планировщик резервного копирования
+func NewScheduler(registry *config.Registry, db *storage.DB, ocservDir string) *Scheduler {
+	return &Scheduler{registry: registry, db: db, ocservDir: ocservDir}
+}
+
+// OnResult устанавливает обработчик результата каждого бэкапа
+func (s *Scheduler) OnResult(handler func(Result)) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.onResult = handler
+}
+
+// Last возвращает результат последнего бэкапа
+func (s *Scheduler) Last() Result {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.last
+}
+
+// Run делает бэкапы с заданной частотой до отмены контекста
+func (s *Scheduler) Run(ctx context.Context) {
+	for {
+		cfg := s.registry.Current().Main.Storage.BackupConfig
+		wait := recheckInterval
+
+		if cfg.Enabled {
+			next, err := s.nextRun(cfg)
+			if err != nil {
+				fmt.Printf("Backup schedule failed: %v\n", err)
+			} else if !next.After(time.Now()) {
+				result := s.Backup(ctx)
+				if result.Err == nil {
+					continue
+				}
+				wait = retryInterval
+			} else if until := time.Until(next); until < wait {
+				wait = until
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// Backup делает резервную копию сейчас, записывает результат и удаляет лишние архивы
+func (s *Scheduler) Backup(ctx context.Context) Result {
+	s.running.Lock()
+	defer s.running.Unlock()
+
+	snapshot := s.registry.Current()
+	result := Result{StartedAt: time.Now()}
+	result.Path, result.Checksum, result.Size, result.Err = s.create(ctx, snapshot)
+	if result.Err == nil {
+		result.Pruned, result.Err = prune(snapshot.Main.Storage.BackupConfig)
+	}
+	result.Duration = time.Since(result.StartedAt)
+
+	s.record(ctx, result)
+	return result
+}
+
+// create снимает базу и упаковывает архив
+func (s *Scheduler) create(ctx context.Context, snapshot *config.Snapshot) (string, string, int64, error) {
+	storageConfig := snapshot.Main.Storage
+	cfg := storageConfig.BackupConfig
+
+	err := os.MkdirAll(cfg.Path, 0700)
+	if err != nil {
+		return "", "", 0, errors.CallBackupError(fmt.Sprintf("Failed to create %s", cfg.Path), err)
+	}
+
+	tempDir := storageConfig.TempDir
+	if tempDir == "" {
+		tempDir = os.TempDir()
+	}
+	databaseFile := filepath.Join(tempDir, fmt.Sprintf("eidolon-snapshot-%d.db", time.Now().UnixNano()))
+	defer os.Remove(databaseFile)
+
+	err = s.db.Snapshot(ctx, databaseFile)
+	if err != nil {
+		return "", "", 0, err
+	}
+
+	version, err := s.db.SchemaVersion(ctx)
+	if err != nil {
+		return "", "", 0, err
+	}
+
+	target := filepath.Join(cfg.Path, archivePrefix+time.Now().Format(timestampLayout)+archiveSuffix)
+	manifest := Manifest{CreatedAt: time.Now(), SchemaVersion: version}
+	checksum, size, err := writeArchive(target, databaseFile, s.Sources(snapshot), manifest)
+	if err != nil {
+		return "", "", 0, err
+	}
+	return target, checksum, size, nil
+}
+
+// Sources возвращает каталоги, которые попадают в архив по текущим настройкам
+func (s *Scheduler) Sources(snapshot *config.Snapshot) []Source {
+	return SourcesFor(snapshot, s.registry.Paths(), s.ocservDir)
+}
+
+// SourcesFor возвращает каталоги для архива: сертификаты и конфиги по флагам BackupConfig
+func SourcesFor(snapshot *config.Snapshot, configPaths []string, ocservDir string) []Source {
+	cfg := snapshot.Main.Storage.BackupConfig
+	security := snapshot.OpenConnect.Security
+
+	var sources []Source
+	if cfg.IncludeCerts {
+		sources = append(sources, Source{Name: "certs", Dir: security.CAPath})
+		// Собственный CA может лежать отдельно от серверного сертификата
+		if security.ClientCA != "" {
+			caDir := filepath.Dir(security.ClientCA)
+			if filepath.Clean(caDir) != filepath.Clean(security.CAPath) {
+				sources = append(sources, Source{Name: "ca", Dir: caDir})
+			}
+		}
+		sources = append(sources, Source{Name: "users", Dir: filepath.Join(snapshot.Main.Storage.DataDir, "users")})
+	}
+	if cfg.IncludeConfigs {
+		for i, dir := range configPaths {
+			name := "config"
+			if i > 0 {
+				name = fmt.Sprintf("config%d", i)
+			}
+			sources = append(sources, Source{Name: name, Dir: dir})
+		}
+		if ocservDir != "" {
+			sources = append(sources, Source{Name: "ocserv", Dir: ocservDir})
+		}
+	}
+	return sources
+}
+
+// nextRun вычисляет время следующего бэкапа от последнего архива
+func (s *Scheduler) nextRun(cfg structures.BackupPathConfig) (time.Time, error) {
+	archives, err := listArchives(cfg.Path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if len(archives) == 0 {
+		return time.Now(), nil
+	}
+
+	// listArchives отдает только архивы с разбираемым временем в имени
+	last, _ := archiveTime(archives[len(archives)-1])
+
+	switch cfg.Frequency {
+	case "weekly":
+		return last.AddDate(0, 0, 7), nil
+	case "monthly":
+		return last.AddDate(0, 1, 0), nil
+	default:
+		return last.AddDate(0, 0, 1), nil
+	}
+}
+
+// record сохраняет результат, пишет его в журнал аудита и вызывает обработчик
+func (s *Scheduler) record(ctx context.Context, result Result) {
+	s.mutex.Lock()
+	s.last = result
+	handler := s.onResult
+	s.mutex.Unlock()
+
+	event := &storage.AuditEvent{Actor: "system", Action: "backup.create", Target: result.Path}
+	if result.Err != nil {
+		event.Action = "backup.failed"
+		event.Details = result.Err.Error()
+		fmt.Printf("Backup failed: %v\n", result.Err)
+	} else {
+		event.Details = fmt.Sprintf("sha256=%s size=%d duration=%s pruned=%d",
+			result.Checksum, result.Size, result.Duration.Round(time.Millisecond), len(result.Pruned))
+	}
+	err := s.db.Audit().Record(ctx, event)
+	if err != nil {
+		fmt.Printf("Failed to record backup result: %v\n", err)
+	}
+
+	if handler != nil {
+		handler(result)
+	}
+}
+
+// prune удаляет старые архивы сверх MaxBackups (0 - хранить все)
+func prune(cfg structures.BackupPathConfig) ([]string, error) {
+	if cfg.MaxBackups <= 0 {
+		return nil, nil
+	}
+	archives, err := listArchives(cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+	if len(archives) <= cfg.MaxBackups {
+		return nil, nil
+	}
+
+	var removed []string
+	for _, archive := range archives[:len(archives)-cfg.MaxBackups] {
+		err = os.Remove(archive)
+		if err != nil && !os.IsNotExist(err) {
+			return removed, errors.CallBackupError(fmt.Sprintf("Failed to remove old backup %s", archive), err)
+		}
+		os.Remove(archive + checksumExt)
+		removed = append(removed, archive)
+	}
+	return removed, nil
+}
@@ -0,0 +1,126 @@
+package backup
+
+import (
+	"eidolonVPN/internal/config/structures"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// touch создает пустые файлы в каталоге
+func touch(t *testing.T, dir string, names ...string) {
+	t.Helper()
+
+	for _, name := range names {
+		err := os.WriteFile(filepath.Join(dir, name), nil, 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// listNames возвращает имена файлов каталога
+func listNames(t *testing.T, dir string) []string {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+func TestNextRun(t *testing.T) {
+	last := time.Date(2026, 1, 31, 3, 0, 0, 0, time.Local)
+	tests := []struct {
+		frequency string
+		want      time.Time
+	}{
+		{"daily", last.AddDate(0, 0, 1)},
+		{"", last.AddDate(0, 0, 1)},
+		{"weekly", last.AddDate(0, 0, 7)},
+		{"monthly", last.AddDate(0, 1, 0)},
+	}
+
+	dir := t.TempDir()
+	// Архив оператора сортируется последним, но в расписании не участвует
+	touch(t, dir,
+		"eidolon-20260130-030000.tar.gz",
+		"eidolon-"+last.Format(timestampLayout)+".tar.gz",
+		"eidolon-manual.tar.gz",
+		"eidolon-snapshot.db",
+	)
+
+	s := &Scheduler{}
+	for _, tt := range tests {
+		next, err := s.nextRun(structures.BackupPathConfig{Path: dir, Frequency: tt.frequency})
+		if err != nil {
+			t.Fatalf("nextRun(%s): %v", tt.frequency, err)
+		}
+		if !next.Equal(tt.want) {
+			t.Errorf("nextRun(%s) = %v, want %v", tt.frequency, next, tt.want)
+		}
+	}
+}
+
+func TestNextRunWithoutArchives(t *testing.T) {
+	dir := t.TempDir()
+	touch(t, dir, "eidolon-manual.tar.gz")
+
+	s := &Scheduler{}
+	for _, path := range []string{dir, filepath.Join(dir, "missing")} {
+		next, err := s.nextRun(structures.BackupPathConfig{Path: path, Frequency: "daily"})
+		if err != nil {
+			t.Fatalf("nextRun: %v", err)
+		}
+		if next.After(time.Now()) {
+			t.Errorf("nextRun(%s) = %v, want backup now", path, next)
+		}
+	}
+}
+
+func TestPrune(t *testing.T) {
+	dir := t.TempDir()
+	touch(t, dir,
+		"eidolon-20260101-030000.tar.gz", "eidolon-20260101-030000.tar.gz.sha256",
+		"eidolon-20260102-030000.tar.gz", "eidolon-20260102-030000.tar.gz.sha256",
+		"eidolon-20260103-030000.tar.gz", "eidolon-20260103-030000.tar.gz.sha256",
+		"eidolon-20260104-030000.tar.gz", "eidolon-20260104-030000.tar.gz.sha256",
+		"eidolon-manual.tar.gz", "notes.txt",
+	)
+
+	removed, err := prune(structures.BackupPathConfig{Path: dir, MaxBackups: 2})
+	if err != nil {
+		t.Fatalf("prune: %v", err)
+	}
+	want := []string{
+		filepath.Join(dir, "eidolon-20260101-030000.tar.gz"),
+		filepath.Join(dir, "eidolon-20260102-030000.tar.gz"),
+	}
+	if !slices.Equal(removed, want) {
+		t.Errorf("removed %q, want %q", removed, want)
+	}
+
+	left := []string{
+		"eidolon-20260103-030000.tar.gz", "eidolon-20260103-030000.tar.gz.sha256",
+		"eidolon-20260104-030000.tar.gz", "eidolon-20260104-030000.tar.gz.sha256",
+		"eidolon-manual.tar.gz", "notes.txt",
+	}
+	if names := listNames(t, dir); !slices.Equal(names, left) {
+		t.Errorf("left %q, want %q", names, left)
+	}
+
+	// Повторная ротация ничего не удаляет, MaxBackups 0 хранит все
+	for _, max := range []int{2, 0} {
+		removed, err = prune(structures.BackupPathConfig{Path: dir, MaxBackups: max})
+		if err != nil || len(removed) != 0 {
+			t.Errorf("prune(max %d) removed %q, %v", max, removed, err)
+		}
+	}
+}
@@ -8,7 +8,8 @@ import (
 	"eidolonVPN/internal/errors/handlers"
 )
 
-// Универсальная функция загрузки конфигов
+// Универсальная функция загрузки конфигов.
+// Приоритет значений: флаги > переменные окружения > файл > значения по умолчанию
 func LoadConfig(configName string, paths []string, cfg interface{}) error {
 
 	// Открываю viper
@@ -23,7 +24,13 @@ func LoadConfig(configName string, paths []string, cfg interface{}) error {
 		v.AddConfigPath(path)
 	}
 
-	err := v.ReadInConfig()
+	// Переопределения из окружения и флагов
+	err := bindOverrides(v, configName, cfg)
+	if err != nil {
+		return err
+	}
+
+	err = v.ReadInConfig()
 	if err != nil {
 		return handlers.ConfigErrHandler(configName, err)
 	}
@@ -33,5 +40,6 @@ func LoadConfig(configName string, paths []string, cfg interface{}) error {
 		return handlers.ConfigErrHandler(configName, err)
 	}
 
-	return nil
+	// Проверяем значения полей, собирая все ошибки сразу
+	return Validate(configName, cfg)
 }
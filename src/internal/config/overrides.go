@@ -0,0 +1,130 @@
+package config
+
+import (
+	"eidolonVPN/internal/errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// Порядок приоритета значений: флаги > переменные окружения > файл > значения по умолчанию.
+//
+// Переменные окружения: EIDOLON_<КОНФИГ>_<КЛЮЧ>, где точки в пути ключа заменены на "_",
+// например EIDOLON_OPENCONNECT_NETWORK_MTU=1300.
+// Флаги: --<конфиг>.<ключ>, например --openconnect.network.mtu=1300.
+// Списки в переменных окружения и флагах задаются через запятую.
+
+// EnvPrefix - префикс переменных окружения eidolon
+const EnvPrefix = "EIDOLON"
+
+var (
+	flagsMutex sync.Mutex
+	flagSet    *pflag.FlagSet
+)
+
+// configKey - ключ конфига с типом поля
+type configKey struct {
+	Name string
+	Type reflect.Type
+}
+
+// UseFlags задает набор флагов, значения которых переопределяют конфиги в LoadConfig
+func UseFlags(fs *pflag.FlagSet) {
+	flagsMutex.Lock()
+	defer flagsMutex.Unlock()
+
+	flagSet = fs
+}
+
+// RegisterFlags добавляет флаг --<конфиг>.<ключ> для каждого поля конфига
+func RegisterFlags(fs *pflag.FlagSet, configName string, cfg interface{}) {
+	for _, key := range configKeys(reflect.TypeOf(cfg), "") {
+		name := configName + "." + key.Name
+		usage := fmt.Sprintf("override %s in %s.yaml", key.Name, configName)
+
+		switch {
+		case key.Type == reflect.TypeOf(time.Duration(0)):
+			fs.Duration(name, 0, usage)
+		case key.Type.Kind() == reflect.String:
+			fs.String(name, "", usage)
+		case key.Type.Kind() == reflect.Int:
+			fs.Int(name, 0, usage)
+		case key.Type.Kind() == reflect.Bool:
+			fs.Bool(name, false, usage)
+		case key.Type.Kind() == reflect.Slice && key.Type.Elem().Kind() == reflect.String:
+			fs.StringSlice(name, nil, usage)
+		case key.Type.Kind() == reflect.Slice && key.Type.Elem().Kind() == reflect.Int:
+			fs.IntSlice(name, nil, usage)
+		case key.Type.Kind() == reflect.Slice && key.Type.Elem().Kind() == reflect.Int64:
+			fs.Int64Slice(name, nil, usage)
+		}
+	}
+}
+
+// bindOverrides привязывает переменные окружения и флаги ко всем ключам конфига
+func bindOverrides(v *viper.Viper, configName string, cfg interface{}) error {
+	v.SetEnvPrefix(EnvPrefix + "_" + strings.ToUpper(configName))
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+
+	flagsMutex.Lock()
+	fs := flagSet
+	flagsMutex.Unlock()
+
+	// Явно привязываем каждый ключ: AutomaticEnv не видит ключи, которых нет в файле
+	for _, key := range configKeys(reflect.TypeOf(cfg), "") {
+		err := v.BindEnv(key.Name)
+		if err != nil {
+			return errors.CallConfigError(fmt.Sprintf("Failed to bind env for %s", key.Name), err)
+		}
+
+		if fs == nil {
+			continue
+		}
+		flag := fs.Lookup(configName + "." + key.Name)
+		if flag == nil || !flag.Changed {
+			continue
+		}
+		err = v.BindPFlag(key.Name, flag)
+		if err != nil {
+			return errors.CallConfigError(fmt.Sprintf("Failed to bind flag for %s", key.Name), err)
+		}
+	}
+
+	return nil
+}
+
+// configKeys собирает пути всех листовых полей по тегам mapstructure
+func configKeys(t reflect.Type, prefix string) []configKey {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var keys []configKey
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := strings.Split(field.Tag.Get("mapstructure"), ",")[0]
+		if tag == "" || tag == "-" {
+			continue
+		}
+
+		name := tag
+		if prefix != "" {
+			name = prefix + "." + tag
+		}
+
+		if field.Type.Kind() == reflect.Struct {
+			keys = append(keys, configKeys(field.Type, name)...)
+			continue
+		}
+		keys = append(keys, configKey{Name: name, Type: field.Type})
+	}
+	return keys
+}
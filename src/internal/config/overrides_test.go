@@ -0,0 +1,97 @@
+package config
+
+import (
+	"eidolonVPN/internal/config/structures"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+// Минимальный корректный openconnect.yaml с MTU из файла
+const testOpenConnectYAML = `server: "vpn.example.com"
+port: 443
+protocol: "tcp"
+interface: "eidolon0"
+socket: "/run/ocserv.socket"
+security:
+  auth: "plain[passwd=/etc/ocserv/passwd]"
+network:
+  mtu: 1400
+  lan: "10.20.30.0"
+  lan_mask: "255.255.255.0"
+  dns_servers: ["8.8.8.8"]
+`
+
+// loadOpenConnect загружает openconnect.yaml из каталога
+func loadOpenConnect(t *testing.T, dir string) structures.OpenConnectConfig {
+	t.Helper()
+
+	var cfg structures.OpenConnectConfig
+	err := LoadConfig("openconnect", []string{dir}, &cfg)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	return cfg
+}
+
+func TestOverridesPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "openconnect.yaml"), []byte(testOpenConnectYAML), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { UseFlags(nil) })
+
+	// Только файл
+	cfg := loadOpenConnect(t, dir)
+	if cfg.Network.MTU != 1400 || !slices.Equal(cfg.Network.DNSServers, []string{"8.8.8.8"}) {
+		t.Errorf("file values: mtu=%d dns=%q", cfg.Network.MTU, cfg.Network.DNSServers)
+	}
+
+	// Окружение перекрывает файл, в том числе ключи, которых в файле нет
+	t.Setenv("EIDOLON_OPENCONNECT_NETWORK_MTU", "1300")
+	t.Setenv("EIDOLON_OPENCONNECT_NETWORK_DNS_SERVERS", "1.1.1.1,1.0.0.1")
+	t.Setenv("EIDOLON_OPENCONNECT_SESSION_IDLE_TIMEOUT", "600")
+	cfg = loadOpenConnect(t, dir)
+	if cfg.Network.MTU != 1300 || cfg.Session.IdleTimeout != 600 {
+		t.Errorf("env values: mtu=%d idle_timeout=%d", cfg.Network.MTU, cfg.Session.IdleTimeout)
+	}
+	if !slices.Equal(cfg.Network.DNSServers, []string{"1.1.1.1", "1.0.0.1"}) {
+		t.Errorf("env list: dns=%q", cfg.Network.DNSServers)
+	}
+
+	// Флаги перекрывают окружение; незаданный флаг значение не трогает
+	fs := pflag.NewFlagSet("eidolon", pflag.ContinueOnError)
+	RegisterFlags(fs, "openconnect", structures.OpenConnectConfig{})
+	err = fs.Parse([]string{"--openconnect.network.mtu=1280"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	UseFlags(fs)
+	cfg = loadOpenConnect(t, dir)
+	if cfg.Network.MTU != 1280 {
+		t.Errorf("flag value: mtu=%d, want 1280", cfg.Network.MTU)
+	}
+	if cfg.Session.IdleTimeout != 600 || cfg.Port != 443 {
+		t.Errorf("unset flags changed values: idle_timeout=%d port=%d", cfg.Session.IdleTimeout, cfg.Port)
+	}
+}
+
+func TestOverridesAreValidated(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "openconnect.yaml"), []byte(testOpenConnectYAML), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Значение из окружения проходит ту же проверку, что и значение из файла
+	t.Setenv("EIDOLON_OPENCONNECT_NETWORK_MTU", "100")
+	var cfg structures.OpenConnectConfig
+	err = LoadConfig("openconnect", []string{dir}, &cfg)
+	if paths := invalidPaths(t, err); !slices.Equal(paths, []string{"network.mtu"}) {
+		t.Errorf("invalid fields %q, want [network.mtu]", paths)
+	}
+}
@@ -0,0 +1,170 @@
+package config
+
+import (
+	"context"
+	"eidolonVPN/internal/config/structures"
+	"eidolonVPN/internal/errors"
+	stderrors "errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
+
+// Задержка перед перезагрузкой, чтобы пережить серию событий от редактора
+const watchDebounce = 500 * time.Millisecond
+
+// Snapshot - согласованный набор конфигов, загруженный за один раз.
+// Снимок неизменяем: подсистемы читают его, но не модифицируют
+type Snapshot struct {
+	Version     uint64    // Растет с каждой успешной загрузкой
+	LoadedAt    time.Time // Время загрузки
+	Main        structures.MainConfig
+	OpenConnect structures.OpenConnectConfig
+	Telegram    structures.TelegramConfig // Пустой, если telegram.yaml нет
+}
+
+// Registry хранит текущий снимок конфигов и атомарно заменяет его при перезагрузке
+type Registry struct {
+	paths       []string
+	current     atomic.Pointer[Snapshot]
+	mutex       sync.Mutex // Сериализует перезагрузки и подписки
+	subscribers []func(*Snapshot)
+}
+
+// NewRegistry загружает конфиги из указанных каталогов и создает реестр
+func NewRegistry(paths []string) (*Registry, error) {
+	r := &Registry{paths: paths}
+
+	snapshot, err := r.load(1)
+	if err != nil {
+		return nil, err
+	}
+	r.current.Store(snapshot)
+
+	return r, nil
+}
+
+// Current возвращает текущий снимок конфигов
+func (r *Registry) Current() *Snapshot {
+	return r.current.Load()
+}
+
+// Paths возвращает каталоги поиска конфигов
+func (r *Registry) Paths() []string {
+	return append([]string(nil), r.paths...)
+}
+
+// Subscribe регистрирует обработчик, вызываемый после каждой успешной перезагрузки
+func (r *Registry) Subscribe(handler func(*Snapshot)) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.subscribers = append(r.subscribers, handler)
+}
+
+// Reload перечитывает конфиги. Снимок заменяется только если все конфиги валидны
+func (r *Registry) Reload() (*Snapshot, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	snapshot, err := r.load(r.current.Load().Version + 1)
+	if err != nil {
+		return nil, err
+	}
+	r.current.Store(snapshot)
+
+	for _, handler := range r.subscribers {
+		handler(snapshot)
+	}
+
+	return snapshot, nil
+}
+
+// Watch следит за YAML конфигами и перезагружает реестр до отмены контекста
+func (r *Registry) Watch(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return errors.CallConfigError("Failed to create config watcher", err)
+	}
+
+	// Следим за каталогами: редакторы часто заменяют файл целиком
+	for _, path := range r.paths {
+		err = watcher.Add(path)
+		if err != nil {
+			watcher.Close()
+			return errors.CallConfigError(fmt.Sprintf("Failed to watch %s", path), err)
+		}
+	}
+
+	go func() {
+		defer watcher.Close()
+
+		var reload <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if !isYamlConfig(event.Name) {
+					continue
+				}
+				if event.Has(fsnotify.Write) || event.Has(fsnotify.Create) || event.Has(fsnotify.Rename) {
+					reload = time.After(watchDebounce)
+				}
+
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				fmt.Printf("Config watcher error: %v\n", err)
+
+			case <-reload:
+				reload = nil
+				if _, err := r.Reload(); err != nil {
+					fmt.Printf("Config reload failed, keeping previous version: %v\n", err)
+				}
+			}
+		}
+	}()
+
+	return nil
+}
+
+// load читает все конфиги в новый снимок
+func (r *Registry) load(version uint64) (*Snapshot, error) {
+	snapshot := &Snapshot{Version: version, LoadedAt: time.Now()}
+
+	err := LoadConfig("main", r.paths, &snapshot.Main)
+	if err != nil {
+		return nil, err
+	}
+
+	err = LoadConfig("openconnect", r.paths, &snapshot.OpenConnect)
+	if err != nil {
+		return nil, err
+	}
+
+	// Бот необязателен: без telegram.yaml он просто выключен
+	err = LoadConfig("telegram", r.paths, &snapshot.Telegram)
+	if err != nil && !stderrors.As(err, &viper.ConfigFileNotFoundError{}) {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+// isYamlConfig проверяет, относится ли событие к YAML конфигу
+func isYamlConfig(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	return ext == ".yaml" || ext == ".yml"
+}
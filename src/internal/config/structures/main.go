@@ -5,6 +5,7 @@ type MainConfig struct {
 	Service ServiceConfig `yaml:"service" mapstructure:"service"`
 	Logging LoggingConfig `yaml:"logging" mapstructure:"logging"`
 	Storage StorageConfig `yaml:"storage" mapstructure:"storage"`
+	API     APIConfig     `yaml:"api" mapstructure:"api"`
 }
 
 // ServiceConfig определяет основные параметры работы сервиса
@@ -40,8 +41,19 @@ type StorageConfig struct {
 
 // BackupPathConfig определяет настройки резервного копирования
 type BackupPathConfig struct {
-	Enabled    bool   `yaml:"enabled" mapstructure:"enabled"`         // Включено ли резервное копирование
-	Path       string `yaml:"path" mapstructure:"path"`               // Путь для хранения бэкапов
-	Frequency  string `yaml:"frequency" mapstructure:"frequency"`     // Частота (daily, weekly, monthly)
-	MaxBackups int    `yaml:"max_backups" mapstructure:"max_backups"` // Максимальное количество бэкапов
+	Enabled        bool   `yaml:"enabled" mapstructure:"enabled"`                 // Включено ли резервное копирование
+	Path           string `yaml:"path" mapstructure:"path"`                       // Путь для хранения бэкапов
+	Frequency      string `yaml:"frequency" mapstructure:"frequency"`             // Частота (daily, weekly, monthly)
+	MaxBackups     int    `yaml:"max_backups" mapstructure:"max_backups"`         // Максимальное количество бэкапов
+	IncludeCerts   bool   `yaml:"include_certs" mapstructure:"include_certs"`     // Добавлять CA, серверные и клиентские сертификаты
+	IncludeConfigs bool   `yaml:"include_configs" mapstructure:"include_configs"` // Добавлять конфиги eidolon и сгенерированный ocserv.conf
+}
+
+// APIConfig определяет HTTP API управления eidolon
+type APIConfig struct {
+	Enabled  bool   `yaml:"enabled" mapstructure:"enabled"`
+	Listen   string `yaml:"listen" mapstructure:"listen"`       // Адрес HTTP сервера, например 127.0.0.1:8080
+	Token    string `yaml:"token" mapstructure:"token"`         // Bearer токен, не короче 32 символов
+	CertFile string `yaml:"cert_file" mapstructure:"cert_file"` // TLS на стороне eidolon, если нет прокси
+	KeyFile  string `yaml:"key_file" mapstructure:"key_file"`
 }
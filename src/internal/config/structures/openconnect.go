@@ -1,49 +1,131 @@
 package structures
 
+import "time"
+
 // База OpenConnect конфига
 type OpenConnectConfig struct {
-	Name      string         `yaml:"name" mapstructure:"name"`
-	Server    string         `yaml:"server" mapstructure:"server"`
-	Port      int            `yaml:"port" mapstructure:"port"`
-	Protocol  string         `yaml:"protocol" mapstructure:"protocol"`   // udp/tcp
-	Interface string         `yaml:"interface" mapstructure:"interface"` // tun интерфейс
-	Socket    string         `yaml:"socket" mapstructure:"socket"`
-	Security  SecurityConfig `yaml:"security" mapstructure:"security"`
-	Network   NetworkConfig  `yaml:"network" mapstructure:"network"`
-	Debug     DebugConfig    `yaml:"debug" mapstructure:"debug"`
+	Name              string           `yaml:"name" mapstructure:"name"`
+	Server            string           `yaml:"server" mapstructure:"server"`
+	Port              int              `yaml:"port" mapstructure:"port"`
+	Protocol          string           `yaml:"protocol" mapstructure:"protocol"`   // udp/tcp
+	Interface         string           `yaml:"interface" mapstructure:"interface"` // tun интерфейс
+	Socket            string           `yaml:"socket" mapstructure:"socket"`
+	OcctlSocket       string           `yaml:"occtl_socket" mapstructure:"occtl_socket"` // Управляющий сокет occtl
+	Security          SecurityConfig   `yaml:"security" mapstructure:"security"`
+	Network           NetworkConfig    `yaml:"network" mapstructure:"network"`
+	Limits            LimitsConfig     `yaml:"limits" mapstructure:"limits"`
+	Session           SessionConfig    `yaml:"session" mapstructure:"session"`
+	Debug             DebugConfig      `yaml:"debug" mapstructure:"debug"`
+	CiscoClientCompat bool             `yaml:"cisco_client_compat" mapstructure:"cisco_client_compat"` // Совместимость с клиентами AnyConnect
+	Supervisor        SupervisorConfig `yaml:"supervisor" mapstructure:"supervisor"`
 }
 
 // Настройки безопасности
 type SecurityConfig struct {
-	CertPath       string   `yaml:"cert_path" mapstructure:"cert_path"`
-	KeyPath        string   `yaml:"key_path" mapstructure:"key_path"`
-	Auth           string   `yaml:"auth" mapstructure:"auth"`
-	CAPath         string   `yaml:"ca_path" mapstructure:"ca_path"`
-	CACert         string   `yaml:"ca_cert" mapstructure:"ca_cert"`
-	CAKey          string   `yaml:"ca_key" mapstructure:"ca_key"`
-	NoCertCheck    bool     `yaml:"no_cert_check" mapstructure:"no_cert_check"`
-	AllowedCiphers []string `yaml:"allowed_ciphers" mapstructure:"allowed_ciphers"`
-	DisableIPv6    bool     `yaml:"disable_ipv6" mapstructure:"disable_ipv6"`
+	CertPath       string        `yaml:"cert_path" mapstructure:"cert_path"`
+	KeyPath        string        `yaml:"key_path" mapstructure:"key_path"`
+	Auth           string        `yaml:"auth" mapstructure:"auth"`
+	CAPath         string        `yaml:"ca_path" mapstructure:"ca_path"`
+	CACert         string        `yaml:"ca_cert" mapstructure:"ca_cert"`
+	CAKey          string        `yaml:"ca_key" mapstructure:"ca_key"`
+	ClientCA       string        `yaml:"client_ca" mapstructure:"client_ca"`         // CA для проверки клиентских сертификатов
+	ClientCAKey    string        `yaml:"client_ca_key" mapstructure:"client_ca_key"` // Ключ CA: если задан, eidolon сам ведет CA
+	CertAuth       string        `yaml:"cert_auth" mapstructure:"cert_auth"`         // "", optional, required
+	CRL            string        `yaml:"crl" mapstructure:"crl"`                     // Список отозванных клиентских сертификатов
+	NoCertCheck    bool          `yaml:"no_cert_check" mapstructure:"no_cert_check"` // Не проверять клиентские сертификаты
+	AllowedCiphers []string      `yaml:"allowed_ciphers" mapstructure:"allowed_ciphers"`
+	DisableIPv6    bool          `yaml:"disable_ipv6" mapstructure:"disable_ipv6"`
+	IsolateWorkers bool          `yaml:"isolate_workers" mapstructure:"isolate_workers"` // seccomp-изоляция worker процессов
+	Ban            BanConfig     `yaml:"ban" mapstructure:"ban"`
+	Renewal        RenewalConfig `yaml:"renewal" mapstructure:"renewal"`
+	ACME           ACMEConfig    `yaml:"acme" mapstructure:"acme"`
+	ServerKey      KeyConfig     `yaml:"server_key" mapstructure:"server_key"` // Ключ серверного сертификата
+	ClientKey      KeyConfig     `yaml:"client_key" mapstructure:"client_key"` // Ключи собственного CA и клиентских сертификатов
+}
+
+// Алгоритм и размер приватного ключа
+type KeyConfig struct {
+	Type string `yaml:"type" mapstructure:"type"` // rsa, ecdsa, ed25519; пусто - rsa
+	Size int    `yaml:"size" mapstructure:"size"` // RSA: 2048/3072/4096, ECDSA: 256/384; 0 - по умолчанию
+}
+
+// Настройки получения серверного сертификата по ACME
+type ACMEConfig struct {
+	Enabled      bool   `yaml:"enabled" mapstructure:"enabled"`
+	DirectoryURL string `yaml:"directory_url" mapstructure:"directory_url"` // Пусто - Let's Encrypt production
+	DirectoryCA  string `yaml:"directory_ca" mapstructure:"directory_ca"`   // Корень TLS тестового CA, например Pebble
+	Email        string `yaml:"email" mapstructure:"email"`
+	Challenge    string `yaml:"challenge" mapstructure:"challenge"`     // http-01 или tls-alpn-01
+	ListenAddr   string `yaml:"listen_addr" mapstructure:"listen_addr"` // Адрес сервера проверки, по умолчанию :80 / :443
+	AccountKey   string `yaml:"account_key" mapstructure:"account_key"` // Ключ ACME аккаунта
+}
+
+// Настройки продления серверного сертификата
+type RenewalConfig struct {
+	Before        time.Duration `yaml:"before" mapstructure:"before"`                 // Перевыпуск за это время до истечения
+	CheckInterval time.Duration `yaml:"check_interval" mapstructure:"check_interval"` // Периодичность проверки
+}
+
+// Настройки блокировки IP: баллы ocserv за неудачные попытки и список /ban
+type BanConfig struct {
+	MaxScore      int    `yaml:"max_score" mapstructure:"max_score"`           // Порог блокировки, 0 - отключено
+	WrongPassword int    `yaml:"wrong_password" mapstructure:"wrong_password"` // Баллы за неверный пароль
+	Connection    int    `yaml:"connection" mapstructure:"connection"`         // Баллы за подключение
+	ResetTime     int    `yaml:"reset_time" mapstructure:"reset_time"`         // Время сброса баллов в секундах
+	List          string `yaml:"list" mapstructure:"list"`                     // Файл адресов, заблокированных /ban; рядом пишется connect-script
+}
+
+// Ограничения подключений
+type LimitsConfig struct {
+	MaxClients     int   `yaml:"max_clients" mapstructure:"max_clients"`           // 0 - без ограничений
+	MaxSameClients int   `yaml:"max_same_clients" mapstructure:"max_same_clients"` // Сессий на одного пользователя
+	RateLimitMs    int   `yaml:"rate_limit_ms" mapstructure:"rate_limit_ms"`       // Минимальный интервал между подключениями
+	TrafficQuota   int64 `yaml:"traffic_quota" mapstructure:"traffic_quota"`       // Байт (rx+tx) на пользователя за календарный месяц, 0 - без квоты
+}
+
+// Настройки сессий
+type SessionConfig struct {
+	Keepalive         int `yaml:"keepalive" mapstructure:"keepalive"`                     // Секунды
+	DPD               int `yaml:"dpd" mapstructure:"dpd"`                                 // Dead peer detection, секунды
+	MobileDPD         int `yaml:"mobile_dpd" mapstructure:"mobile_dpd"`                   // DPD для мобильных клиентов
+	IdleTimeout       int `yaml:"idle_timeout" mapstructure:"idle_timeout"`               // Отключение неактивных, секунды
+	MobileIdleTimeout int `yaml:"mobile_idle_timeout" mapstructure:"mobile_idle_timeout"` // Для мобильных клиентов
 }
 
 // Настройки сети
 type NetworkConfig struct {
-	MTU           int      `yaml:"mtu" mapstructure:"mtu"`
-	LAN           string   `yaml:"lan" mapstructure:"lan"`
-	LANMask       string   `yaml:"lan_mask" mapstructure:"lan_mask"`
-	DNSServers    []string `yaml:"dns_servers" mapstructure:"dns_servers"`
-	SearchDomains []string `yaml:"search_domains" mapstructure:"search_domains"`
-	Routes        []string `yaml:"routes" mapstructure:"routes"`
-	ExcludeRoutes []string `yaml:"exclude_routes" mapstructure:"exclude_routes"`
-	DefaultRoute  bool     `yaml:"default_route" mapstructure:"default_route"`
+	MTU                int      `yaml:"mtu" mapstructure:"mtu"`
+	LAN                string   `yaml:"lan" mapstructure:"lan"`
+	LANMask            string   `yaml:"lan_mask" mapstructure:"lan_mask"`
+	IPv6Network        string   `yaml:"ipv6_network" mapstructure:"ipv6_network"` // Например fda9:4efe:7e3b:03ea::/48
+	DNSServers         []string `yaml:"dns_servers" mapstructure:"dns_servers"`
+	SearchDomains      []string `yaml:"search_domains" mapstructure:"search_domains"`
+	Routes             []string `yaml:"routes" mapstructure:"routes"`
+	ExcludeRoutes      []string `yaml:"exclude_routes" mapstructure:"exclude_routes"`
+	DefaultRoute       bool     `yaml:"default_route" mapstructure:"default_route"`
+	ConfigPerUser      string   `yaml:"config_per_user" mapstructure:"config_per_user"`           // Каталог персональных маршрутов, пусто - отключено
+	ConfigPerGroup     string   `yaml:"config_per_group" mapstructure:"config_per_group"`         // Каталог политик групп, пусто - отключено
+	DefaultGroupConfig string   `yaml:"default_group_config" mapstructure:"default_group_config"` // Файл политики группы default для пользователей без группы
+	TunnelAllDNS       bool     `yaml:"tunnel_all_dns" mapstructure:"tunnel_all_dns"`
+	Compression        bool     `yaml:"compression" mapstructure:"compression"`
 }
 
 // Настройки отладки
 type DebugConfig struct {
 	Verbose   int    `yaml:"verbose" mapstructure:"verbose"` // 0-3
 	LogFile   string `yaml:"log_file" mapstructure:"log_file"`
-	Timestamp bool   `yaml:"timestamp" mapstructure:"timestamp"`
-	NoHTTPS   bool   `yaml:"no_https" mapstructure:"no_https"` // Для тестирования
+	Timestamp bool   `yaml:"timestamp" mapstructure:"timestamp"` // Метки времени в логах ocserv
+	NoHTTPS   bool   `yaml:"no_https" mapstructure:"no_https"`   // Для тестирования: открытый сокет listen-clear-file
+}
+
+// Настройки перезапуска ocserv супервизором
+type SupervisorConfig struct {
+	RestartPolicy  string        `yaml:"restart_policy" mapstructure:"restart_policy"`   // always, on-failure, never
+	InitialBackoff time.Duration `yaml:"initial_backoff" mapstructure:"initial_backoff"` // Первая задержка перед перезапуском
+	MaxBackoff     time.Duration `yaml:"max_backoff" mapstructure:"max_backoff"`         // Максимальная задержка
+	MaxRestarts    int           `yaml:"max_restarts" mapstructure:"max_restarts"`       // Лимит падений в окне до остановки
+	RestartWindow  time.Duration `yaml:"restart_window" mapstructure:"restart_window"`   // Окно подсчета падений
+	StopTimeout    time.Duration `yaml:"stop_timeout" mapstructure:"stop_timeout"`       // Ожидание после SIGTERM до SIGKILL
 }
 
 // Пользовательская аутентификация
@@ -0,0 +1,24 @@
+package structures
+
+import "time"
+
+// TelegramConfig содержит настройки Telegram бота
+type TelegramConfig struct {
+	Enabled     bool          `yaml:"enabled" mapstructure:"enabled"`
+	Token       string        `yaml:"token" mapstructure:"token"`               // Токен от @BotFather
+	APIURL      string        `yaml:"api_url" mapstructure:"api_url"`           // Пусто - https://api.telegram.org
+	Admins      []int64       `yaml:"admins" mapstructure:"admins"`             // Telegram ID администраторов: все команды
+	Operators   []int64       `yaml:"operators" mapstructure:"operators"`       // Только просмотр: /status, /users
+	PollTimeout time.Duration `yaml:"poll_timeout" mapstructure:"poll_timeout"` // Таймаут long polling getUpdates
+	Webhook     WebhookConfig `yaml:"webhook" mapstructure:"webhook"`
+}
+
+// WebhookConfig определяет прием обновлений через webhook вместо long polling
+type WebhookConfig struct {
+	Enabled     bool   `yaml:"enabled" mapstructure:"enabled"`
+	URL         string `yaml:"url" mapstructure:"url"`                   // Публичный HTTPS адрес, который зарегистрируется в Telegram
+	Listen      string `yaml:"listen" mapstructure:"listen"`             // Локальный адрес HTTP сервера
+	SecretToken string `yaml:"secret_token" mapstructure:"secret_token"` // Обязателен, проверяется в заголовке X-Telegram-Bot-Api-Secret-Token
+	CertFile    string `yaml:"cert_file" mapstructure:"cert_file"`       // TLS на стороне eidolon, если нет прокси
+	KeyFile     string `yaml:"key_file" mapstructure:"key_file"`
+}
@@ -0,0 +1,438 @@
+package config
+
+import (
+	"eidolonVPN/internal/config/structures"
+	"eidolonVPN/internal/errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// secret_token webhook: 1-256 символов A-Z, a-z, 0-9, _ и - (ограничение Bot API)
+var secretTokenPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,256}$`)
+
+// Минимальная длина токена HTTP API: токен дает полный доступ к маршрутам пользователей
+const minAPITokenLength = 32
+
+// FieldError описывает ошибку в конкретном поле конфига
+type FieldError struct {
+	Path    string // Путь к полю в YAML, например network.dns_servers[0]
+	Message string
+}
+
+// ValidationError содержит все ошибки валидации конфига
+type ValidationError struct {
+	Config string
+	Fields []FieldError
+}
+
+// Error реализует интерфейс error
+func (e ValidationError) Error() string {
+	parts := make([]string, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		parts = append(parts, fmt.Sprintf("%s: %s", field.Path, field.Message))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Validate проверяет загруженный конфиг известного типа
+func Validate(configName string, cfg interface{}) error {
+	v := &validator{}
+
+	switch c := cfg.(type) {
+	case *structures.MainConfig:
+		validateMainConfig(v, *c)
+	case *structures.OpenConnectConfig:
+		validateOpenConnectConfig(v, *c)
+	case *structures.TelegramConfig:
+		validateTelegramConfig(v, *c)
+	default:
+		return nil
+	}
+
+	if len(v.fields) == 0 {
+		return nil
+	}
+	return errors.CallConfigError(
+		fmt.Sprintf("Config %s has %d invalid field(s)", configName, len(v.fields)),
+		ValidationError{Config: configName, Fields: v.fields},
+	)
+}
+
+// Проверка main.yaml
+func validateMainConfig(v *validator, cfg structures.MainConfig) {
+	if cfg.Service.Host != "" && net.ParseIP(cfg.Service.Host) == nil && !isHostname(cfg.Service.Host) {
+		v.add("service.host", "%q is neither an IP address nor a hostname", cfg.Service.Host)
+	}
+	for i, port := range cfg.Service.Ports {
+		v.port(fmt.Sprintf("service.ports[%d]", i), port)
+	}
+
+	v.oneOf("logging.level", cfg.Logging.Level, "debug", "info", "warn", "error")
+	v.oneOf("logging.format", cfg.Logging.Format, "json", "text")
+	v.nonNegative("logging.max_size", cfg.Logging.MaxSize)
+	v.nonNegative("logging.max_backups", cfg.Logging.MaxBackups)
+
+	v.required("storage.database_path", cfg.Storage.DatabasePath)
+	v.required("storage.data_dir", cfg.Storage.DataDir)
+
+	backup := cfg.Storage.BackupConfig
+	if backup.Enabled {
+		v.required("storage.backup.path", backup.Path)
+		v.oneOf("storage.backup.frequency", backup.Frequency, "daily", "weekly", "monthly")
+	}
+	v.nonNegative("storage.backup.max_backups", backup.MaxBackups)
+
+	api := cfg.API
+	if api.Enabled {
+		v.required("api.listen", api.Listen)
+		if len(api.Token) < minAPITokenLength {
+			v.add("api.token", "is required: at least %d characters", minAPITokenLength)
+		}
+		if (api.CertFile == "") != (api.KeyFile == "") {
+			v.add("api", "cert_file and key_file must be set together")
+		}
+		if api.CertFile != "" {
+			v.fileExists("api.cert_file", api.CertFile)
+			v.fileExists("api.key_file", api.KeyFile)
+		}
+	}
+}
+
+// Проверка openconnect.yaml
+func validateOpenConnectConfig(v *validator, cfg structures.OpenConnectConfig) {
+	v.required("server", cfg.Server)
+	v.port("port", cfg.Port)
+	v.oneOf("protocol", cfg.Protocol, "tcp", "udp")
+	v.required("interface", cfg.Interface)
+	v.required("socket", cfg.Socket)
+
+	// Безопасность
+	v.required("security.auth", cfg.Security.Auth)
+	// Внешний CA должен существовать, собственный CA eidolon создаст сам
+	if cfg.Security.ClientCA != "" && cfg.Security.ClientCAKey == "" && !cfg.Security.NoCertCheck {
+		v.fileExists("security.client_ca", cfg.Security.ClientCA)
+	}
+	if cfg.Security.CertAuth != "" {
+		v.oneOf("security.cert_auth", cfg.Security.CertAuth, "optional", "required")
+		if cfg.Security.ClientCA == "" {
+			v.add("security.client_ca", "is required when cert_auth is enabled")
+		}
+		// Без ca-cert ocserv с auth = "certificate" не запустится
+		if cfg.Security.CertAuth == "required" && cfg.Security.NoCertCheck {
+			v.add("security.no_cert_check", "cannot be set when cert_auth is required")
+		}
+	}
+	if cfg.Security.CRL != "" && cfg.Security.ClientCA == "" {
+		v.add("security.crl", "requires security.client_ca")
+	}
+	v.nonNegative("security.ban.max_score", cfg.Security.Ban.MaxScore)
+	v.nonNegative("security.ban.wrong_password", cfg.Security.Ban.WrongPassword)
+	v.nonNegative("security.ban.connection", cfg.Security.Ban.Connection)
+	v.nonNegative("security.ban.reset_time", cfg.Security.Ban.ResetTime)
+	v.absPath("security.ban.list", cfg.Security.Ban.List)
+	if cfg.Security.ACME.Enabled {
+		v.required("security.acme.account_key", cfg.Security.ACME.AccountKey)
+		if cfg.Security.ACME.Challenge != "" {
+			v.oneOf("security.acme.challenge", cfg.Security.ACME.Challenge, "http-01", "tls-alpn-01")
+		}
+		// Продление идет при запущенном ocserv, поэтому tls-alpn-01 не может слушать его порт;
+		// внешний 443 должен пробрасываться на listen_addr
+		if cfg.Security.ACME.Challenge == "tls-alpn-01" {
+			_, port, err := net.SplitHostPort(cfg.Security.ACME.ListenAddr)
+			switch {
+			case cfg.Security.ACME.ListenAddr == "":
+				v.add("security.acme.listen_addr", "is required for tls-alpn-01: port 443 is taken by ocserv")
+			case err != nil:
+				v.add("security.acme.listen_addr", "%q is not a host:port address", cfg.Security.ACME.ListenAddr)
+			case port == strconv.Itoa(cfg.Port):
+				v.add("security.acme.listen_addr", "%q must not use ocserv port %d", cfg.Security.ACME.ListenAddr, cfg.Port)
+			}
+		}
+		if cfg.Security.ACME.DirectoryCA != "" {
+			v.fileExists("security.acme.directory_ca", cfg.Security.ACME.DirectoryCA)
+		}
+		if net.ParseIP(cfg.Server) != nil {
+			v.add("server", "ACME requires a DNS name, got IP %s", cfg.Server)
+		}
+	}
+	v.key("security.server_key", cfg.Security.ServerKey)
+	v.key("security.client_key", cfg.Security.ClientKey)
+	if cfg.Security.ACME.Enabled && cfg.Security.ServerKey.Type == "ed25519" {
+		v.add("security.server_key.type", "ed25519 is not supported by ACME CAs, use rsa or ecdsa")
+	}
+	if cfg.Security.Renewal.Before < 0 || cfg.Security.Renewal.CheckInterval < 0 {
+		v.add("security.renewal", "durations must not be negative")
+	}
+
+	// Сеть
+	if cfg.Network.MTU < 576 || cfg.Network.MTU > 9000 {
+		v.add("network.mtu", "%d is out of range 576-9000", cfg.Network.MTU)
+	}
+	v.ipv4("network.lan", cfg.Network.LAN)
+	v.mask("network.lan_mask", cfg.Network.LANMask)
+	if cfg.Network.IPv6Network != "" {
+		v.cidr("network.ipv6_network", cfg.Network.IPv6Network)
+	}
+	for i, dns := range cfg.Network.DNSServers {
+		v.ip(fmt.Sprintf("network.dns_servers[%d]", i), dns)
+	}
+	for i, domain := range cfg.Network.SearchDomains {
+		if !isHostname(domain) {
+			v.add(fmt.Sprintf("network.search_domains[%d]", i), "%q is not a valid domain", domain)
+		}
+	}
+	for i, route := range cfg.Network.Routes {
+		v.route(fmt.Sprintf("network.routes[%d]", i), route)
+	}
+	for i, route := range cfg.Network.ExcludeRoutes {
+		v.route(fmt.Sprintf("network.exclude_routes[%d]", i), route)
+	}
+
+	// eidolon пишет в config-per-user и config-per-group файлы по именам пользователей
+	// и групп и удаляет свои устаревшие, поэтому каталоги не должны совпадать
+	v.absPath("network.config_per_user", cfg.Network.ConfigPerUser)
+	v.absPath("network.config_per_group", cfg.Network.ConfigPerGroup)
+	v.absPath("network.default_group_config", cfg.Network.DefaultGroupConfig)
+	perUser, perGroup := filepath.Clean(cfg.Network.ConfigPerUser), filepath.Clean(cfg.Network.ConfigPerGroup)
+	if cfg.Network.ConfigPerUser != "" && cfg.Network.ConfigPerGroup != "" && perUser == perGroup {
+		v.add("network.config_per_group", "must differ from network.config_per_user")
+	}
+	if cfg.Network.DefaultGroupConfig != "" {
+		dir := filepath.Dir(filepath.Clean(cfg.Network.DefaultGroupConfig))
+		if (cfg.Network.ConfigPerUser != "" && dir == perUser) || (cfg.Network.ConfigPerGroup != "" && dir == perGroup) {
+			v.add("network.default_group_config", "must be outside config_per_user and config_per_group directories")
+		}
+	}
+
+	// Ограничения и сессии
+	v.nonNegative("limits.max_clients", cfg.Limits.MaxClients)
+	v.nonNegative("limits.max_same_clients", cfg.Limits.MaxSameClients)
+	v.nonNegative("limits.rate_limit_ms", cfg.Limits.RateLimitMs)
+	if cfg.Limits.TrafficQuota < 0 {
+		v.add("limits.traffic_quota", "must not be negative, got %d", cfg.Limits.TrafficQuota)
+	}
+	v.nonNegative("session.keepalive", cfg.Session.Keepalive)
+	v.nonNegative("session.dpd", cfg.Session.DPD)
+	v.nonNegative("session.mobile_dpd", cfg.Session.MobileDPD)
+	v.nonNegative("session.idle_timeout", cfg.Session.IdleTimeout)
+	v.nonNegative("session.mobile_idle_timeout", cfg.Session.MobileIdleTimeout)
+
+	// Отладка
+	if cfg.Debug.Verbose < 0 || cfg.Debug.Verbose > 9 {
+		v.add("debug.verbose", "%d is out of range 0-9", cfg.Debug.Verbose)
+	}
+
+	// Супервизор
+	if cfg.Supervisor.RestartPolicy != "" {
+		v.oneOf("supervisor.restart_policy", cfg.Supervisor.RestartPolicy, "always", "on-failure", "never")
+	}
+	v.nonNegative("supervisor.max_restarts", cfg.Supervisor.MaxRestarts)
+	if cfg.Supervisor.InitialBackoff < 0 || cfg.Supervisor.MaxBackoff < 0 ||
+		cfg.Supervisor.RestartWindow < 0 || cfg.Supervisor.StopTimeout < 0 {
+		v.add("supervisor", "durations must not be negative")
+	}
+}
+
+// Проверка telegram.yaml
+func validateTelegramConfig(v *validator, cfg structures.TelegramConfig) {
+	if !cfg.Enabled {
+		return
+	}
+	v.required("token", cfg.Token)
+	if cfg.APIURL != "" && !strings.HasPrefix(cfg.APIURL, "http://") && !strings.HasPrefix(cfg.APIURL, "https://") {
+		v.add("api_url", "%q must be an http(s) URL", cfg.APIURL)
+	}
+	for i, id := range cfg.Admins {
+		if id <= 0 {
+			v.add(fmt.Sprintf("admins[%d]", i), "%d is not a Telegram user id", id)
+		}
+	}
+	for i, id := range cfg.Operators {
+		if id <= 0 {
+			v.add(fmt.Sprintf("operators[%d]", i), "%d is not a Telegram user id", id)
+		}
+	}
+	if cfg.PollTimeout < 0 {
+		v.add("poll_timeout", "must not be negative")
+	}
+
+	if cfg.Webhook.Enabled {
+		if !strings.HasPrefix(cfg.Webhook.URL, "https://") {
+			v.add("webhook.url", "%q must be an https URL", cfg.Webhook.URL)
+		}
+		v.required("webhook.listen", cfg.Webhook.Listen)
+		// Без секрета любой, кто достучится до listen, подделает обновление от администратора
+		if !secretTokenPattern.MatchString(cfg.Webhook.SecretToken) {
+			v.add("webhook.secret_token", "is required: 1-256 characters A-Z, a-z, 0-9, _ and -")
+		}
+		if (cfg.Webhook.CertFile == "") != (cfg.Webhook.KeyFile == "") {
+			v.add("webhook", "cert_file and key_file must be set together")
+		}
+		if cfg.Webhook.CertFile != "" {
+			v.fileExists("webhook.cert_file", cfg.Webhook.CertFile)
+			v.fileExists("webhook.key_file", cfg.Webhook.KeyFile)
+		}
+	}
+}
+
+// validator собирает ошибки всех полей вместо остановки на первой
+type validator struct {
+	fields []FieldError
+}
+
+// add добавляет ошибку поля
+func (v *validator) add(path string, format string, args ...interface{}) {
+	v.fields = append(v.fields, FieldError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// required проверяет, что строка не пуста
+func (v *validator) required(path, value string) {
+	if strings.TrimSpace(value) == "" {
+		v.add(path, "is required")
+	}
+}
+
+// nonNegative проверяет, что число не отрицательно
+func (v *validator) nonNegative(path string, value int) {
+	if value < 0 {
+		v.add(path, "must not be negative, got %d", value)
+	}
+}
+
+// port проверяет диапазон порта
+func (v *validator) port(path string, port int) {
+	if port < 1 || port > 65535 {
+		v.add(path, "port %d is out of range 1-65535", port)
+	}
+}
+
+// oneOf проверяет, что значение входит в перечисление
+func (v *validator) oneOf(path, value string, allowed ...string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	v.add(path, "%q must be one of %s", value, strings.Join(allowed, ", "))
+}
+
+// absPath проверяет, что непустой путь абсолютный
+func (v *validator) absPath(path, value string) {
+	if value != "" && !filepath.IsAbs(value) {
+		v.add(path, "%q must be an absolute path", value)
+	}
+}
+
+// ip проверяет IP адрес
+func (v *validator) ip(path, value string) {
+	if net.ParseIP(value) == nil {
+		v.add(path, "%q is not a valid IP address", value)
+	}
+}
+
+// ipv4 проверяет IPv4 адрес
+func (v *validator) ipv4(path, value string) {
+	ip := net.ParseIP(value)
+	if ip == nil || ip.To4() == nil {
+		v.add(path, "%q is not a valid IPv4 address", value)
+	}
+}
+
+// mask проверяет IPv4 маску подсети
+func (v *validator) mask(path, value string) {
+	ip := net.ParseIP(value)
+	if ip == nil || ip.To4() == nil {
+		v.add(path, "%q is not a valid netmask", value)
+		return
+	}
+	if _, bits := net.IPMask(ip.To4()).Size(); bits == 0 {
+		v.add(path, "%q is not a contiguous netmask", value)
+	}
+}
+
+// cidr проверяет сеть в нотации CIDR
+func (v *validator) cidr(path, value string) {
+	if _, _, err := net.ParseCIDR(value); err != nil {
+		v.add(path, "%q is not a valid CIDR", value)
+	}
+}
+
+// route проверяет маршрут ocserv: default, CIDR или адрес/маска
+func (v *validator) route(path, value string) {
+	if !ValidRoute(value) {
+		v.add(path, "%q is not a valid route", value)
+	}
+}
+
+// ValidRoute сообщает, является ли значение маршрутом ocserv: default, CIDR или адрес/маска
+func ValidRoute(value string) bool {
+	if value == "default" {
+		return true
+	}
+	if _, _, err := net.ParseCIDR(value); err == nil {
+		return true
+	}
+	parts := strings.SplitN(value, "/", 2)
+	if len(parts) == 2 && net.ParseIP(parts[0]) != nil {
+		if mask := net.ParseIP(parts[1]); mask != nil && mask.To4() != nil {
+			if _, bits := net.IPMask(mask.To4()).Size(); bits != 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// key проверяет алгоритм и размер ключа
+func (v *validator) key(path string, cfg structures.KeyConfig) {
+	switch cfg.Type {
+	case "", "rsa":
+		if cfg.Size != 0 && cfg.Size != 2048 && cfg.Size != 3072 && cfg.Size != 4096 {
+			v.add(path+".size", "RSA key size %d must be one of 2048, 3072, 4096", cfg.Size)
+		}
+	case "ecdsa":
+		if cfg.Size != 0 && cfg.Size != 256 && cfg.Size != 384 {
+			v.add(path+".size", "ECDSA curve size %d must be one of 256, 384", cfg.Size)
+		}
+	case "ed25519":
+		if cfg.Size != 0 {
+			v.add(path+".size", "ed25519 has a fixed key size")
+		}
+	default:
+		v.add(path+".type", "%q must be one of rsa, ecdsa, ed25519", cfg.Type)
+	}
+}
+
+// fileExists проверяет наличие файла
+func (v *validator) fileExists(path, file string) {
+	info, err := os.Stat(file)
+	switch {
+	case err != nil:
+		v.add(path, "file %q is not accessible: %v", file, err)
+	case info.IsDir():
+		v.add(path, "%q is a directory, expected a file", file)
+	}
+}
+
+// isHostname проверяет синтаксис доменного имени
+func isHostname(value string) bool {
+	if value == "" || len(value) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(strings.TrimSuffix(value, "."), ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
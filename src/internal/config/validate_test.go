@@ -0,0 +1,211 @@
+package config
+
+import (
+	"eidolonVPN/internal/config/structures"
+	stderrors "errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// validOpenConnect - минимальный корректный openconnect.yaml
+func validOpenConnect() structures.OpenConnectConfig {
+	return structures.OpenConnectConfig{
+		Server:    "vpn.example.com",
+		Port:      443,
+		Protocol:  "tcp",
+		Interface: "eidolon0",
+		Socket:    "/run/ocserv.socket",
+		Security:  structures.SecurityConfig{Auth: "plain[passwd=/etc/ocserv/passwd]"},
+		Network:   structures.NetworkConfig{MTU: 1400, LAN: "10.20.30.0", LANMask: "255.255.255.0"},
+	}
+}
+
+// validMain - минимальный корректный main.yaml
+func validMain() structures.MainConfig {
+	return structures.MainConfig{
+		Logging: structures.LoggingConfig{Level: "info", Format: "text"},
+		Storage: structures.StorageConfig{DatabasePath: "/var/lib/eidolon/eidolon.db", DataDir: "/var/lib/eidolon"},
+	}
+}
+
+// invalidPaths возвращает пути полей из ошибки валидации
+func invalidPaths(t *testing.T, err error) []string {
+	t.Helper()
+
+	if err == nil {
+		return nil
+	}
+	var validation ValidationError
+	if !stderrors.As(err, &validation) {
+		t.Fatalf("got %v, want ValidationError", err)
+	}
+	paths := make([]string, 0, len(validation.Fields))
+	for _, field := range validation.Fields {
+		paths = append(paths, field.Path)
+	}
+	return paths
+}
+
+func TestValidateOpenConnect(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing.pem")
+
+	tests := []struct {
+		name   string
+		change func(c *structures.OpenConnectConfig)
+		paths  []string // Ожидаемые пути ошибок, пусто - конфиг корректен
+	}{
+		{"valid", func(c *structures.OpenConnectConfig) {}, nil},
+		{"port 0", func(c *structures.OpenConnectConfig) { c.Port = 0 }, []string{"port"}},
+		{"port too large", func(c *structures.OpenConnectConfig) { c.Port = 70000 }, []string{"port"}},
+		{"sctp", func(c *structures.OpenConnectConfig) { c.Protocol = "sctp" }, []string{"protocol"}},
+		{"negative traffic quota", func(c *structures.OpenConnectConfig) { c.Limits.TrafficQuota = -1 }, []string{"limits.traffic_quota"}},
+		{"relative ban list", func(c *structures.OpenConnectConfig) { c.Security.Ban.List = "banned-ips" }, []string{"security.ban.list"}},
+		{"bad lan_mask", func(c *structures.OpenConnectConfig) { c.Network.LANMask = "255.0.255.0" }, []string{"network.lan_mask"}},
+		{"lan_mask not an address", func(c *structures.OpenConnectConfig) { c.Network.LANMask = "24" }, []string{"network.lan_mask"}},
+		{"non-IP DNS", func(c *structures.OpenConnectConfig) {
+			c.Network.DNSServers = []string{"1.1.1.1", "dns.example.com"}
+		}, []string{"network.dns_servers[1]"}},
+		{"bad route", func(c *structures.OpenConnectConfig) {
+			c.Network.Routes = []string{"10.0.0.0/8", "10.0.0.0/33"}
+		}, []string{"network.routes[1]"}},
+		{"mtu out of range", func(c *structures.OpenConnectConfig) { c.Network.MTU = 100 }, []string{"network.mtu"}},
+		{"missing client CA", func(c *structures.OpenConnectConfig) {
+			c.Security.ClientCA = missing
+			c.Security.CertAuth = "optional"
+		}, []string{"security.client_ca"}},
+		{"missing ACME directory CA", func(c *structures.OpenConnectConfig) {
+			c.Security.ACME = structures.ACMEConfig{Enabled: true, AccountKey: "/certs/acme.key", DirectoryCA: missing}
+		}, []string{"security.acme.directory_ca"}},
+		{"required cert auth without cert check", func(c *structures.OpenConnectConfig) {
+			c.Security.ClientCA = "/certs/ca-cert.pem"
+			c.Security.ClientCAKey = "/certs/ca-key.pem"
+			c.Security.CertAuth = "required"
+			c.Security.NoCertCheck = true
+		}, []string{"security.no_cert_check"}},
+		{"optional cert auth without cert check", func(c *structures.OpenConnectConfig) {
+			c.Security.ClientCA = "/certs/ca-cert.pem"
+			c.Security.ClientCAKey = "/certs/ca-key.pem"
+			c.Security.CertAuth = "optional"
+			c.Security.NoCertCheck = true
+		}, nil},
+		{"tls-alpn-01 without listen_addr", func(c *structures.OpenConnectConfig) {
+			c.Security.ACME = structures.ACMEConfig{Enabled: true, AccountKey: "/certs/acme.key", Challenge: "tls-alpn-01"}
+		}, []string{"security.acme.listen_addr"}},
+		{"tls-alpn-01 on ocserv port", func(c *structures.OpenConnectConfig) {
+			c.Security.ACME = structures.ACMEConfig{Enabled: true, AccountKey: "/certs/acme.key", Challenge: "tls-alpn-01", ListenAddr: ":443"}
+		}, []string{"security.acme.listen_addr"}},
+		{"tls-alpn-01 on separate port", func(c *structures.OpenConnectConfig) {
+			c.Security.ACME = structures.ACMEConfig{Enabled: true, AccountKey: "/certs/acme.key", Challenge: "tls-alpn-01", ListenAddr: "127.0.0.1:8443"}
+		}, nil},
+		{"relative config_per_user", func(c *structures.OpenConnectConfig) {
+			c.Network.ConfigPerUser = "config-per-user"
+		}, []string{"network.config_per_user"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validOpenConnect()
+			tt.change(&cfg)
+			paths := invalidPaths(t, Validate("openconnect", &cfg))
+			if !slices.Equal(paths, tt.paths) {
+				t.Errorf("invalid fields %q, want %q", paths, tt.paths)
+			}
+		})
+	}
+}
+
+func TestValidateReportsAllFields(t *testing.T) {
+	cfg := validOpenConnect()
+	cfg.Port = 0
+	cfg.Protocol = "sctp"
+	cfg.Network.LANMask = "255.0.255.0"
+	cfg.Network.DNSServers = []string{"not-an-ip"}
+	cfg.Security.ClientCA = filepath.Join(t.TempDir(), "missing.pem")
+	cfg.Security.CertAuth = "required"
+
+	err := Validate("openconnect", &cfg)
+	want := []string{"port", "protocol", "security.client_ca", "network.lan_mask", "network.dns_servers[0]"}
+	if paths := invalidPaths(t, err); !slices.Equal(paths, want) {
+		t.Errorf("invalid fields %q, want %q", paths, want)
+	}
+	if !strings.Contains(err.Error(), "openconnect has 5 invalid field(s)") {
+		t.Errorf("error does not count fields: %v", err)
+	}
+}
+
+func TestValidateMain(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(c *structures.MainConfig)
+		paths  []string
+	}{
+		{"valid", func(c *structures.MainConfig) {}, nil},
+		{"invalid log level", func(c *structures.MainConfig) { c.Logging.Level = "verbose" }, []string{"logging.level"}},
+		{"invalid log format", func(c *structures.MainConfig) { c.Logging.Format = "xml" }, []string{"logging.format"}},
+		{"port 0", func(c *structures.MainConfig) { c.Service.Ports = []int{8080, 0} }, []string{"service.ports[1]"}},
+		{"bad host", func(c *structures.MainConfig) { c.Service.Host = "bad host" }, []string{"service.host"}},
+		{"missing storage", func(c *structures.MainConfig) { c.Storage = structures.StorageConfig{} },
+			[]string{"storage.database_path", "storage.data_dir"}},
+		{"api disabled without token", func(c *structures.MainConfig) { c.API.Listen = "127.0.0.1:8080" }, nil},
+		{"api", func(c *structures.MainConfig) {
+			c.API = structures.APIConfig{Enabled: true, Listen: "127.0.0.1:8080", Token: strings.Repeat("t", 32)}
+		}, nil},
+		{"api with short token", func(c *structures.MainConfig) {
+			c.API = structures.APIConfig{Enabled: true, Listen: "127.0.0.1:8080", Token: "secret"}
+		}, []string{"api.token"}},
+		{"api without listen", func(c *structures.MainConfig) {
+			c.API = structures.APIConfig{Enabled: true, Token: strings.Repeat("t", 32), CertFile: "/etc/eidolon/api.pem"}
+		}, []string{"api.listen", "api", "api.cert_file", "api.key_file"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validMain()
+			tt.change(&cfg)
+			paths := invalidPaths(t, Validate("main", &cfg))
+			if !slices.Equal(paths, tt.paths) {
+				t.Errorf("invalid fields %q, want %q", paths, tt.paths)
+			}
+		})
+	}
+}
+
+func TestValidateWebhookSecret(t *testing.T) {
+	tests := []struct {
+		name    string
+		enabled bool
+		secret  string
+		valid   bool
+	}{
+		{"polling without secret", false, "", true},
+		{"webhook with secret", true, "Abc_123-xyz", true},
+		{"webhook without secret", true, "", false},
+		{"webhook with blank secret", true, "   ", false},
+		{"webhook with forbidden characters", true, "secret token!", false},
+		{"webhook with too long secret", true, strings.Repeat("a", 257), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &structures.TelegramConfig{
+				Enabled: true,
+				Token:   "123456:token",
+				Webhook: structures.WebhookConfig{
+					Enabled:     tt.enabled,
+					URL:         "https://vpn.example.com/telegram",
+					Listen:      ":8443",
+					SecretToken: tt.secret,
+				},
+			}
+			err := Validate("telegram", cfg)
+			if tt.valid && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if !tt.valid && (err == nil || !strings.Contains(err.Error(), "webhook.secret_token")) {
+				t.Errorf("got %v, want webhook.secret_token error", err)
+			}
+		})
+	}
+}
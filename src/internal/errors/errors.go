@@ -52,3 +52,48 @@ func CallOpenConnectError(msg string, err error) error {
 func CallUtilsError(msg string, err error) error {
 	return CallError("utils", msg, err)
 }
+
+// Обработка ошибок PKI
+func CallPKIError(msg string, err error) error {
+	return CallError("pki", msg, err)
+}
+
+// Обработка ошибок хранилища
+func CallStorageError(msg string, err error) error {
+	return CallError("storage", msg, err)
+}
+
+// Обработка ошибок резервного копирования
+func CallBackupError(msg string, err error) error {
+	return CallError("backup", msg, err)
+}
+
+// Обработка ошибок файла паролей ocpasswd
+func CallPasswdError(msg string, err error) error {
+	return CallError("passwd", msg, err)
+}
+
+// Обработка ошибок Telegram бота
+func CallTelegramError(msg string, err error) error {
+	return CallError("telegram", msg, err)
+}
+
+// Обработка ошибок персональных маршрутов
+func CallRoutesError(msg string, err error) error {
+	return CallError("routes", msg, err)
+}
+
+// Обработка ошибок групп и их политик
+func CallGroupsError(msg string, err error) error {
+	return CallError("groups", msg, err)
+}
+
+// Обработка ошибок управления пользователями
+func CallUsersError(msg string, err error) error {
+	return CallError("users", msg, err)
+}
+
+// Обработка ошибок HTTP API
+func CallAPIError(msg string, err error) error {
+	return CallError("api", msg, err)
+}
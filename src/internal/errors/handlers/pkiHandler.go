@@ -0,0 +1,21 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+
+	ers "eidolonVPN/internal/errors"
+)
+
+// Обработка файловых ошибок PKI
+func PKIFileErrHandler(path string, err error) error {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return ers.CallPKIError(fmt.Sprintf("File %s not found", path), err)
+	case errors.Is(err, fs.ErrPermission):
+		return ers.CallPKIError(fmt.Sprintf("Cannot access %s, not enough permissions", path), err)
+	default:
+		return ers.CallPKIError(fmt.Sprintf("Unexpected error with %s: %v", path, err), err)
+	}
+}
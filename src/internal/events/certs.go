@@ -0,0 +1,41 @@
+package events
+
+import (
+	"context"
+	"eidolonVPN/internal/storage"
+	"fmt"
+	"time"
+)
+
+// Параметры проверки клиентских сертификатов
+const (
+	certCheckInterval = 24 * time.Hour
+	certExpiryWarning = 14 * 24 * time.Hour
+)
+
+// WatchClientCerts раз в сутки публикует CertExpiring для клиентских сертификатов,
+// истекающих в ближайшие две недели, до отмены контекста
+func WatchClientCerts(ctx context.Context, db *storage.DB, bus *Bus) {
+	for {
+		certs, err := db.Certs().ListExpiring(ctx, time.Now().Add(certExpiryWarning))
+		if err != nil {
+			fmt.Printf("Client certificate check failed: %v\n", err)
+		}
+		for _, cert := range certs {
+			days := int(time.Until(cert.NotAfter).Hours() / 24)
+			message := fmt.Sprintf("Сертификат пользователя %s (serial %s) истекает %s, осталось дней: %d",
+				cert.Username, cert.Serial, cert.NotAfter.Format("2006-01-02"), days)
+			if days < 0 {
+				message = fmt.Sprintf("Сертификат пользователя %s (serial %s) истек %s",
+					cert.Username, cert.Serial, cert.NotAfter.Format("2006-01-02"))
+			}
+			bus.Publish(Event{Kind: CertExpiring, Key: "client:" + cert.Serial, Message: message})
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(certCheckInterval):
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Очередь каждого подписчика; при переполнении события отбрасываются, а не блокируют издателя
+const subscriberBuffer = 64
+
+// Kind - тип события
+type Kind string
+
+// Типы событий сервиса
+const (
+	OcservCrash   Kind = "ocserv_crash"  // ocserv завершился без запроса
+	CertExpiring  Kind = "cert_expiring" // Сертификат скоро истекает или не продлен
+	BackupFailed  Kind = "backup_failed"
+	QuotaExceeded Kind = "quota_exceeded" // Пользователь исчерпал лимит
+)
+
+// Kinds - все типы событий в порядке показа в настройках подписки
+var Kinds = []Kind{OcservCrash, CertExpiring, BackupFailed, QuotaExceeded}
+
+// Event - событие сервиса
+type Event struct {
+	Kind    Kind
+	Key     string // Объект события в пределах типа: путь сертификата, пользователь; по нему работает дедупликация
+	Message string
+	Time    time.Time
+}
+
+// Bus - шина событий: подсистемы публикуют, подписчики получают в своих горутинах
+type Bus struct {
+	mutex       sync.Mutex
+	subscribers []chan Event
+}
+
+// NewBus создает пустую шину
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe вызывает handler для каждого события до отмены контекста
+func (b *Bus) Subscribe(ctx context.Context, handler func(Event)) {
+	queue := make(chan Event, subscriberBuffer)
+
+	b.mutex.Lock()
+	b.subscribers = append(b.subscribers, queue)
+	b.mutex.Unlock()
+
+	go func() {
+		defer b.unsubscribe(queue)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event := <-queue:
+				handler(event)
+			}
+		}
+	}()
+}
+
+// Publish рассылает событие подписчикам, не дожидаясь обработки
+func (b *Bus) Publish(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for _, queue := range b.subscribers {
+		select {
+		case queue <- event:
+		default:
+			fmt.Printf("Event queue is full, dropped %s %s\n", event.Kind, event.Key)
+		}
+	}
+}
+
+// unsubscribe удаляет очередь подписчика
+func (b *Bus) unsubscribe(queue chan Event) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for i, q := range b.subscribers {
+		if q == queue {
+			b.subscribers = append(b.subscribers[:i], b.subscribers[i+1:]...)
+			return
+		}
+	}
+}
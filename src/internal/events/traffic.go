@@ -0,0 +1,139 @@
+package events
+
+import (
+	"context"
+	"eidolonVPN/internal/config"
+	"eidolonVPN/internal/openconnect"
+	"eidolonVPN/internal/storage"
+	"eidolonVPN/internal/utils"
+	"fmt"
+	"time"
+)
+
+// Как часто снимать счетчики сессий ocserv. Трафик сессии, закрытой между
+// опросами, учитывается по последнему снятому значению
+const trafficInterval = time.Minute
+
+// sessionCounters - счетчики сессии ocserv при прошлом опросе
+type sessionCounters struct {
+	username string
+	rx, tx   int64
+}
+
+// trafficMeter переносит прирост трафика сессий в таблицу traffic
+// и публикует QuotaExceeded при превышении limits.traffic_quota
+type trafficMeter struct {
+	registry *config.Registry
+	db       *storage.DB
+	bus      *Bus
+	last     map[int]sessionCounters // По ID сессии ocserv
+	notified map[string]string       // Пользователь -> месяц, за который отправлено уведомление
+}
+
+// WatchTraffic раз в минуту учитывает трафик пользователей по occtl до отмены контекста
+func WatchTraffic(ctx context.Context, registry *config.Registry, db *storage.DB, manager *openconnect.Manager, bus *Bus) {
+	meter := newTrafficMeter(registry, db, bus)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(trafficInterval):
+		}
+
+		// Счетчики нового процесса ocserv начинаются с нуля
+		if !manager.IsRunning() {
+			meter.last = map[int]sessionCounters{}
+			continue
+		}
+		sessions, err := manager.Occtl().ListUsers(ctx)
+		if err == nil {
+			err = meter.record(ctx, sessions, time.Now())
+		}
+		if err != nil {
+			fmt.Printf("Traffic accounting failed: %v\n", err)
+		}
+	}
+}
+
+// newTrafficMeter создает счетчик без известных сессий
+func newTrafficMeter(registry *config.Registry, db *storage.DB, bus *Bus) *trafficMeter {
+	return &trafficMeter{
+		registry: registry,
+		db:       db,
+		bus:      bus,
+		last:     map[int]sessionCounters{},
+		notified: map[string]string{},
+	}
+}
+
+// record добавляет прирост счетчиков с прошлого опроса и проверяет квоту
+func (m *trafficMeter) record(ctx context.Context, sessions []openconnect.OcctlUser, now time.Time) error {
+	current := make(map[int]sessionCounters, len(sessions))
+	deltas := map[string][2]int64{}
+	for _, session := range sessions {
+		counters := sessionCounters{username: session.Username, rx: int64(session.RX), tx: int64(session.TX)}
+		current[session.ID] = counters
+
+		prev, ok := m.last[session.ID]
+		if !ok || prev.username != counters.username || counters.rx < prev.rx || counters.tx < prev.tx {
+			// Новая сессия: весь ее трафик еще не учтен
+			prev = sessionCounters{}
+		}
+		delta := deltas[counters.username]
+		delta[0] += counters.rx - prev.rx
+		delta[1] += counters.tx - prev.tx
+		deltas[counters.username] = delta
+	}
+	// При ошибке записи прирост теряется, но не учитывается повторно
+	m.last = current
+
+	for username, delta := range deltas {
+		if delta[0] == 0 && delta[1] == 0 {
+			continue
+		}
+		user, err := m.db.Users().GetByUsername(ctx, username)
+		if err == storage.ErrNotFound {
+			continue // Пользователь ocserv, которого нет в базе
+		}
+		if err != nil {
+			return err
+		}
+		err = m.db.Traffic().Add(ctx, user.ID, now, delta[0], delta[1])
+		if err != nil {
+			return err
+		}
+		err = m.checkQuota(ctx, user, now)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkQuota публикует QuotaExceeded один раз за месяц, когда трафик пользователя
+// с начала месяца (UTC, как в таблице traffic) достигает квоты
+func (m *trafficMeter) checkQuota(ctx context.Context, user *storage.User, now time.Time) error {
+	quota := m.registry.Current().OpenConnect.Limits.TrafficQuota
+	month := now.UTC().Format("2006-01")
+	if quota <= 0 || m.notified[user.Username] == month {
+		return nil
+	}
+
+	monthStart := time.Date(now.UTC().Year(), now.UTC().Month(), 1, 0, 0, 0, 0, time.UTC)
+	rx, tx, err := m.db.Traffic().Total(ctx, user.ID, monthStart)
+	if err != nil {
+		return err
+	}
+	if rx+tx < quota {
+		return nil
+	}
+
+	m.notified[user.Username] = month
+	m.bus.Publish(Event{
+		Kind: QuotaExceeded,
+		Key:  "traffic:" + user.Username,
+		Message: fmt.Sprintf("Пользователь %s израсходовал за %s %s трафика при квоте %s",
+			user.Username, month, utils.FormatBytes(rx+tx), utils.FormatBytes(quota)),
+	})
+	return nil
+}
@@ -0,0 +1,168 @@
+package events
+
+import (
+	"context"
+	"eidolonVPN/internal/config"
+	"eidolonVPN/internal/openconnect"
+	"eidolonVPN/internal/storage"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testMeter создает счетчик трафика с квотой quota и базой, где есть пользователь alice
+func testMeter(t *testing.T, quota int64) (*trafficMeter, *storage.DB, <-chan Event) {
+	t.Helper()
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"main.yaml": `logging: {level: info, format: text}
+storage: {database_path: /tmp/eidolon.db, data_dir: /tmp}
+`,
+		"openconnect.yaml": fmt.Sprintf(`server: "vpn.example.com"
+port: 443
+protocol: tcp
+interface: eidolon0
+socket: /run/ocserv.socket
+security:
+  auth: "plain[passwd=/etc/ocserv/passwd]"
+network: {mtu: 1400, lan: 10.20.30.0, lan_mask: 255.255.255.0}
+limits: {traffic_quota: %d}
+`, quota),
+	}
+	for name, content := range files {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	registry, err := config.NewRegistry([]string{dir})
+	if err != nil {
+		t.Fatalf("NewRegistry: %v", err)
+	}
+
+	db, err := storage.Open(filepath.Join(dir, "eidolon.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	err = db.Users().Create(context.Background(), &storage.User{Username: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	bus := NewBus()
+	published := make(chan Event, 16)
+	bus.Subscribe(ctx, func(event Event) { published <- event })
+
+	return newTrafficMeter(registry, db, bus), db, published
+}
+
+// session - сессия в том виде, в каком ее отдает occtl -j show users
+func session(t *testing.T, id int, username string, rx, tx int64) openconnect.OcctlUser {
+	t.Helper()
+
+	var user openconnect.OcctlUser
+	raw := fmt.Sprintf(`{"ID": %d, "Username": %q, "RX": "%d", "TX": "%d"}`, id, username, rx, tx)
+	err := json.Unmarshal([]byte(raw), &user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+// expectEvents ждет count событий и проверяет, что лишних нет
+func expectEvents(t *testing.T, published <-chan Event, count int) []Event {
+	t.Helper()
+
+	var list []Event
+	for len(list) < count {
+		select {
+		case event := <-published:
+			list = append(list, event)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("got %d events, want %d", len(list), count)
+		}
+	}
+	select {
+	case event := <-published:
+		t.Fatalf("unexpected event: %+v", event)
+	case <-time.After(50 * time.Millisecond):
+	}
+	return list
+}
+
+func TestTrafficQuota(t *testing.T) {
+	meter, db, published := testMeter(t, 1000)
+	ctx := context.Background()
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	alice, err := db.Users().GetByUsername(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	total := func(since time.Time) int64 {
+		t.Helper()
+		rx, tx, err := db.Traffic().Total(ctx, alice.ID, since)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rx + tx
+	}
+	record := func(at time.Time, sessions ...openconnect.OcctlUser) {
+		t.Helper()
+		err := meter.record(ctx, sessions, at)
+		if err != nil {
+			t.Fatalf("record: %v", err)
+		}
+	}
+
+	// Весь трафик новой сессии; пользователь, которого нет в базе, пропускается
+	record(now, session(t, 1, "alice", 300, 100), session(t, 2, "ghost", 5000, 5000))
+	// Прирост первой сессии и вторая сессия того же пользователя
+	record(now, session(t, 1, "alice", 500, 200), session(t, 3, "alice", 100, 0))
+	if got := total(now); got != 800 {
+		t.Fatalf("traffic %d, want 800", got)
+	}
+	expectEvents(t, published, 0)
+
+	// Первая сессия закрылась, вторая переходит квоту
+	record(now, session(t, 3, "alice", 400, 0))
+	events := expectEvents(t, published, 1)
+	if events[0].Kind != QuotaExceeded || events[0].Key != "traffic:alice" {
+		t.Errorf("unexpected event: %+v", events[0])
+	}
+
+	// Уведомление отправляется один раз за месяц
+	record(now, session(t, 3, "alice", 900, 0))
+	expectEvents(t, published, 0)
+
+	// ID сессии переиспользован новым процессом ocserv: счетчики меньше прежних
+	record(now, session(t, 3, "alice", 50, 0))
+	if got := total(now); got != 1650 {
+		t.Errorf("traffic after counter reset %d, want 1650", got)
+	}
+
+	// В новом месяце трафик считается заново
+	next := now.AddDate(0, 1, 0)
+	record(next, session(t, 3, "alice", 150, 0))
+	if got := total(next); got != 100 {
+		t.Errorf("traffic in next month %d, want 100", got)
+	}
+	expectEvents(t, published, 0)
+}
+
+func TestTrafficWithoutQuota(t *testing.T) {
+	meter, _, published := testMeter(t, 0)
+
+	err := meter.record(context.Background(), []openconnect.OcctlUser{session(t, 1, "alice", 1<<40, 1<<40)}, time.Now())
+	if err != nil {
+		t.Fatalf("record: %v", err)
+	}
+	expectEvents(t, published, 0)
+}
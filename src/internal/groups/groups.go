@@ -0,0 +1,340 @@
+package groups
+
+import (
+	"bytes"
+	"context"
+	"eidolonVPN/internal/config"
+	"eidolonVPN/internal/errors"
+	"eidolonVPN/internal/passwd"
+	"eidolonVPN/internal/routes"
+	"eidolonVPN/internal/storage"
+	"eidolonVPN/internal/utils"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultGroup - группа, политика которой пишется в default-group-config
+// и применяется к пользователям без группы или без файла своей группы
+const DefaultGroup = "default"
+
+// Как часто сверять файлы политик с базой на случай изменений в обход Sync
+const syncInterval = time.Minute
+
+// Имя группы попадает в поле group файла паролей и в имя файла config-per-group
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// Info - группа с политикой и участниками
+type Info struct {
+	storage.Group
+	Policy  storage.GroupPolicy
+	Members []string
+}
+
+// Service управляет группами, их политиками и членством пользователей.
+// Политики раскладываются в каталог config-per-group и файл default-group-config,
+// членство попадает в ocserv через поле group файла паролей
+type Service struct {
+	registry   *config.Registry
+	db         *storage.DB
+	passwdSync *passwd.Syncer // nil, если ocserv не использует plain[passwd=...]
+	mutex      sync.Mutex
+}
+
+// NewService создает сервис групп
+func NewService(registry *config.Registry, db *storage.DB, passwdSync *passwd.Syncer) *Service {
+	return &Service{registry: registry, db: db, passwdSync: passwdSync}
+}
+
+// Enabled сообщает, задан ли каталог config-per-group
+func (s *Service) Enabled() bool {
+	return s.registry.Current().OpenConnect.Network.ConfigPerGroup != ""
+}
+
+// List возвращает все группы с политиками и участниками
+func (s *Service) List(ctx context.Context) ([]Info, error) {
+	groups, err := s.db.Groups().List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	policies, err := s.db.Policies().List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	users, err := s.db.Users().List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]Info, 0, len(groups))
+	for _, group := range groups {
+		info := Info{Group: group, Policy: storage.GroupPolicy{GroupID: group.ID, Group: group.Name}}
+		for _, policy := range policies {
+			if policy.GroupID == group.ID {
+				info.Policy = policy
+			}
+		}
+		for _, user := range users {
+			if user.GroupID != nil && *user.GroupID == group.ID {
+				info.Members = append(info.Members, user.Username)
+			}
+		}
+		list = append(list, info)
+	}
+	return list, nil
+}
+
+// Get возвращает группу по имени
+func (s *Service) Get(ctx context.Context, name string) (*Info, error) {
+	list, err := s.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, info := range list {
+		if info.Name == name {
+			return &info, nil
+		}
+	}
+	return nil, storage.ErrNotFound
+}
+
+// Create добавляет группу
+func (s *Service) Create(ctx context.Context, name, description, actor string) (*storage.Group, error) {
+	if !namePattern.MatchString(name) {
+		return nil, errors.CallGroupsError(fmt.Sprintf("%q is not a valid group name", name), nil)
+	}
+
+	group := &storage.Group{Name: name, Description: description}
+	err := s.db.InTx(ctx, func(tx *storage.Tx) error {
+		err := tx.Groups().Create(ctx, group)
+		if err != nil {
+			return err
+		}
+		return tx.Audit().Record(ctx, &storage.AuditEvent{Actor: actor, Action: "group.create", Target: name, Details: description})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+// Delete удаляет группу вместе с политикой; участники остаются без группы
+func (s *Service) Delete(ctx context.Context, name, actor string) error {
+	err := s.db.InTx(ctx, func(tx *storage.Tx) error {
+		group, err := tx.Groups().GetByName(ctx, name)
+		if err != nil {
+			return err
+		}
+		err = tx.Groups().Delete(ctx, group.ID)
+		if err != nil {
+			return err
+		}
+		return tx.Audit().Record(ctx, &storage.AuditEvent{Actor: actor, Action: "group.delete", Target: name})
+	})
+	if err != nil {
+		return err
+	}
+	return s.apply(ctx, true)
+}
+
+// SetPolicy заменяет политику группы, пишет журнал и обновляет файлы политик
+func (s *Service) SetPolicy(ctx context.Context, name string, policy storage.GroupPolicy, actor string) (*storage.GroupPolicy, error) {
+	err := Validate(policy)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.InTx(ctx, func(tx *storage.Tx) error {
+		group, err := tx.Groups().GetByName(ctx, name)
+		if err != nil {
+			return err
+		}
+		policy.GroupID, policy.Group = group.ID, group.Name
+		err = tx.Policies().Set(ctx, &policy)
+		if err != nil {
+			return err
+		}
+		return tx.Audit().Record(ctx, &storage.AuditEvent{
+			Actor:   actor,
+			Action:  "group.policy",
+			Target:  name,
+			Details: Summary(policy),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &policy, s.apply(ctx, false)
+}
+
+// UpdatePolicy меняет политику группы функцией change поверх текущей
+func (s *Service) UpdatePolicy(ctx context.Context, name, actor string, change func(p *storage.GroupPolicy)) (*storage.GroupPolicy, error) {
+	info, err := s.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	policy := info.Policy
+	change(&policy)
+	return s.SetPolicy(ctx, name, policy, actor)
+}
+
+// Assign назначает пользователю группу; пустое имя убирает пользователя из группы.
+// Группа доходит до ocserv только через файл паролей, поэтому без него назначение отклоняется
+func (s *Service) Assign(ctx context.Context, username, name, actor string) error {
+	if s.passwdSync == nil {
+		return errors.CallGroupsError("Group membership requires plain[passwd=...] auth: with certificate auth ocserv takes the group from the certificate", nil)
+	}
+
+	err := s.db.InTx(ctx, func(tx *storage.Tx) error {
+		user, err := tx.Users().GetByUsername(ctx, username)
+		if err != nil {
+			return err
+		}
+		user.GroupID = nil
+		if name != "" {
+			group, err := tx.Groups().GetByName(ctx, name)
+			if err != nil {
+				return err
+			}
+			user.GroupID = &group.ID
+		}
+		err = tx.Users().Update(ctx, user)
+		if err != nil {
+			return err
+		}
+		return tx.Audit().Record(ctx, &storage.AuditEvent{
+			Actor:   actor,
+			Action:  "user.group",
+			Target:  username,
+			Details: "group=" + name,
+		})
+	})
+	if err != nil {
+		return err
+	}
+	return s.apply(ctx, true)
+}
+
+// Sync приводит каталог config-per-group и файл default-group-config в соответствие с базой.
+// Возвращает true, если какой-либо файл был изменен
+func (s *Service) Sync(ctx context.Context) (bool, error) {
+	network := s.registry.Current().OpenConnect.Network
+	if network.ConfigPerGroup == "" && network.DefaultGroupConfig == "" {
+		return false, nil
+	}
+
+	policies, err := s.db.Policies().List(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	files := map[string][]byte{}
+	defaultPolicy := storage.GroupPolicy{Group: DefaultGroup}
+	for _, policy := range policies {
+		if policy.Group == DefaultGroup {
+			defaultPolicy = policy
+		}
+		if !utils.ValidFileName(policy.Group) {
+			fmt.Printf("Skipping policy of group %q: name is not usable as file name\n", policy.Group)
+			continue
+		}
+		files[policy.Group] = Render(policy)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	changed := false
+	if network.ConfigPerGroup != "" {
+		changed, err = utils.SyncDir(network.ConfigPerGroup, files)
+		if err != nil {
+			return changed, errors.CallGroupsError("Failed to sync config-per-group", err)
+		}
+	}
+	// Файл пишется и без политики: ocserv ожидает его, раз директива задана
+	if network.DefaultGroupConfig != "" {
+		written, err := utils.WriteFile(network.DefaultGroupConfig, Render(defaultPolicy), 0644)
+		if err != nil {
+			return changed, errors.CallGroupsError("Failed to write default-group-config", err)
+		}
+		changed = changed || written
+	}
+	return changed, nil
+}
+
+// Run сверяет файлы политик с базой сразу и затем периодически до отмены контекста
+func (s *Service) Run(ctx context.Context) {
+	for {
+		_, err := s.Sync(ctx)
+		if err != nil {
+			fmt.Printf("Group policies sync failed: %v\n", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(syncInterval):
+		}
+	}
+}
+
+// apply обновляет файлы политик и при изменении членства - файл паролей
+func (s *Service) apply(ctx context.Context, membership bool) error {
+	_, err := s.Sync(ctx)
+	if err != nil {
+		return err
+	}
+	if membership && s.passwdSync != nil {
+		_, err = s.passwdSync.Sync(ctx)
+	}
+	return err
+}
+
+// Validate проверяет политику перед сохранением
+func Validate(policy storage.GroupPolicy) error {
+	err := routes.Validate(storage.UserRoutes{Routes: policy.Routes, NoRoutes: policy.NoRoutes, DNS: policy.DNS})
+	if err != nil {
+		return errors.CallGroupsError("Invalid policy", err)
+	}
+	if policy.RxPerSec < 0 || policy.TxPerSec < 0 {
+		return errors.CallGroupsError("Bandwidth limit cannot be negative", nil)
+	}
+	if policy.MaxSameClients < 0 || policy.IdleTimeout < 0 || policy.MobileIdleTimeout < 0 {
+		return errors.CallGroupsError("Limits and timeouts cannot be negative", nil)
+	}
+	return nil
+}
+
+// Render возвращает содержимое файла config-per-group
+func Render(policy storage.GroupPolicy) []byte {
+	var b bytes.Buffer
+	b.Write(routes.Render(storage.UserRoutes{Routes: policy.Routes, NoRoutes: policy.NoRoutes, DNS: policy.DNS}))
+	if policy.RxPerSec > 0 {
+		b.WriteString(fmt.Sprintf("rx-data-per-sec = %d\n", policy.RxPerSec))
+	}
+	if policy.TxPerSec > 0 {
+		b.WriteString(fmt.Sprintf("tx-data-per-sec = %d\n", policy.TxPerSec))
+	}
+	if policy.MaxSameClients > 0 {
+		b.WriteString(fmt.Sprintf("max-same-clients = %d\n", policy.MaxSameClients))
+	}
+	if policy.IdleTimeout > 0 {
+		b.WriteString(fmt.Sprintf("idle-timeout = %d\n", policy.IdleTimeout))
+	}
+	if policy.MobileIdleTimeout > 0 {
+		b.WriteString(fmt.Sprintf("mobile-idle-timeout = %d\n", policy.MobileIdleTimeout))
+	}
+	return b.Bytes()
+}
+
+// Summary - краткое описание политики для журнала
+func Summary(policy storage.GroupPolicy) string {
+	if policy.Empty() {
+		return "cleared"
+	}
+	return fmt.Sprintf("route=%s no-route=%s dns=%s rx=%d tx=%d max-same-clients=%d idle=%d mobile-idle=%d",
+		strings.Join(policy.Routes, ","), strings.Join(policy.NoRoutes, ","), strings.Join(policy.DNS, ","),
+		policy.RxPerSec, policy.TxPerSec, policy.MaxSameClients, policy.IdleTimeout, policy.MobileIdleTimeout)
+}
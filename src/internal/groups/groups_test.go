@@ -0,0 +1,72 @@
+package groups
+
+import (
+	"context"
+	"eidolonVPN/internal/errors"
+	"eidolonVPN/internal/storage"
+	"eidolonVPN/internal/utils"
+	stderrors "errors"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy storage.GroupPolicy
+		valid  bool
+	}{
+		{"empty", storage.GroupPolicy{}, true},
+		{"full", storage.GroupPolicy{
+			Routes: []string{"10.0.0.0/8"}, NoRoutes: []string{"10.1.0.0/16"}, DNS: []string{"1.1.1.1"},
+			RxPerSec: 1 << 20, TxPerSec: 1 << 20, MaxSameClients: 2, IdleTimeout: 600, MobileIdleTimeout: 1800,
+		}, true},
+		{"bad route", storage.GroupPolicy{Routes: []string{"10.0.0.0/33"}}, false},
+		{"default no-route", storage.GroupPolicy{NoRoutes: []string{"default"}}, false},
+		{"bad dns", storage.GroupPolicy{DNS: []string{"dns.example.com"}}, false},
+		{"negative bandwidth", storage.GroupPolicy{RxPerSec: -1}, false},
+		{"negative timeout", storage.GroupPolicy{IdleTimeout: -1}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.policy)
+			if tt.valid {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			// Ошибки политики приходят от модуля groups, а не routes
+			var moduleErr errors.ModuleError
+			if !stderrors.As(err, &moduleErr) || moduleErr.Module != "groups" {
+				t.Errorf("got %v, want groups error", err)
+			}
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	data := string(Render(storage.GroupPolicy{Routes: []string{"10.0.0.0/8"}, RxPerSec: 1024, IdleTimeout: 600}))
+	if !strings.HasPrefix(data, utils.GeneratedHeader) {
+		t.Errorf("policy file lacks generated header:\n%s", data)
+	}
+	for _, line := range []string{"route = 10.0.0.0/8\n", "rx-data-per-sec = 1024\n", "idle-timeout = 600\n"} {
+		if !strings.Contains(data, line) {
+			t.Errorf("policy file lacks %q:\n%s", line, data)
+		}
+	}
+	if strings.Contains(data, "tx-data-per-sec") || strings.Contains(data, "max-same-clients") {
+		t.Errorf("zero limits rendered:\n%s", data)
+	}
+}
+
+func TestAssignRequiresPasswd(t *testing.T) {
+	// Без файла паролей группа не доходит до ocserv: назначение отклоняется до обращения к базе
+	service := &Service{}
+	err := service.Assign(context.Background(), "alice", "staff", "test")
+	var moduleErr errors.ModuleError
+	if !stderrors.As(err, &moduleErr) || moduleErr.Module != "groups" {
+		t.Errorf("got %v, want groups error", err)
+	}
+}
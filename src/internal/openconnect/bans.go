@@ -0,0 +1,55 @@
+package openconnect
+
+import (
+	"eidolonVPN/internal/errors"
+	"eidolonVPN/internal/utils"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// BanScriptPath возвращает путь к connect-script, который проверяет список блокировок
+func BanScriptPath(list string) string {
+	return list + ".sh"
+}
+
+// banScript отклоняет подключение, если адрес клиента есть в списке. ocserv запускает
+// connect-script при каждом подключении и разрывает его при ненулевом коде выхода.
+// Нечитаемый список подключения не блокирует, чтобы не отрезать всех пользователей
+func banScript(list string) string {
+	return fmt.Sprintf(`#!/bin/sh
+%s[ "$REASON" = "connect" ] || exit 0
+ip="${IP_REAL#::ffff:}"
+if grep -qxF -- "$ip" %q 2>/dev/null; then
+	echo "eidolon: connection from banned address $ip rejected" >&2
+	exit 1
+fi
+exit 0
+`, utils.GeneratedHeader, list)
+}
+
+// WriteBanScript записывает connect-script и создает пустой список, если его нет.
+// Вызывается до запуска ocserv: без скрипта ocserv отклонял бы все подключения
+func WriteBanScript(list string) error {
+	_, err := utils.WriteFile(BanScriptPath(list), []byte(banScript(list)), 0755)
+	if err != nil {
+		return errors.CallOpenConnectError("Failed to write ban connect-script", err)
+	}
+	if _, err := os.Stat(list); os.IsNotExist(err) {
+		return WriteBanList(list, nil)
+	}
+	return nil
+}
+
+// WriteBanList записывает адреса по одному в строке; ocserv читает файл при каждом подключении
+func WriteBanList(list string, ips []string) error {
+	data := []byte(utils.GeneratedHeader)
+	if len(ips) > 0 {
+		data = append(data, strings.Join(ips, "\n")+"\n"...)
+	}
+	_, err := utils.WriteFile(list, data, 0644)
+	if err != nil {
+		return errors.CallOpenConnectError("Failed to write ban list", err)
+	}
+	return nil
+}
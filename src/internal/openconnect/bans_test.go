@@ -0,0 +1,93 @@
+package openconnect
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runBanScript запускает connect-script так, как его запускает ocserv
+func runBanScript(t *testing.T, list, reason, ip string) bool {
+	t.Helper()
+
+	cmd := exec.Command(BanScriptPath(list))
+	cmd.Env = append(os.Environ(), "REASON="+reason, "IP_REAL="+ip)
+	err := cmd.Run()
+	if _, ok := err.(*exec.ExitError); err != nil && !ok {
+		t.Fatalf("connect-script did not run: %v", err)
+	}
+	return err == nil
+}
+
+func TestBanScript(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+	list := filepath.Join(t.TempDir(), "banned-ips")
+
+	// Скрипт пишется вместе с пустым списком
+	err := WriteBanScript(list)
+	if err != nil {
+		t.Fatalf("WriteBanScript: %v", err)
+	}
+	if !runBanScript(t, list, "connect", "198.51.100.7") {
+		t.Error("connection rejected with empty ban list")
+	}
+
+	err = WriteBanList(list, []string{"198.51.100.7", "2001:db8::1"})
+	if err != nil {
+		t.Fatalf("WriteBanList: %v", err)
+	}
+	tests := []struct {
+		reason string
+		ip     string
+		allow  bool
+	}{
+		{"connect", "198.51.100.7", false},
+		{"connect", "::ffff:198.51.100.7", false},
+		{"connect", "2001:db8::1", false},
+		{"connect", "198.51.100.70", true},
+		{"connect", "198.51.100.", true},
+		{"disconnect", "198.51.100.7", true},
+	}
+	for _, tt := range tests {
+		if got := runBanScript(t, list, tt.reason, tt.ip); got != tt.allow {
+			t.Errorf("%s from %s: allowed %t, want %t", tt.reason, tt.ip, got, tt.allow)
+		}
+	}
+
+	// Существующий список при повторной записи скрипта сохраняется
+	err = WriteBanScript(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runBanScript(t, list, "connect", "198.51.100.7") {
+		t.Error("ban list reset by WriteBanScript")
+	}
+
+	// Нечитаемый список не блокирует всех
+	err = os.Remove(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !runBanScript(t, list, "connect", "198.51.100.7") {
+		t.Error("connection rejected without ban list")
+	}
+}
+
+func TestBanScriptInConfig(t *testing.T) {
+	cfg := baseConfig()
+	if strings.Contains(generateOCservConfig(cfg), "connect-script") {
+		t.Error("connect-script set without ban list")
+	}
+
+	cfg.Security.Ban.List = "/var/lib/eidolon/banned-ips"
+	if !strings.Contains(generateOCservConfig(cfg), "connect-script = /var/lib/eidolon/banned-ips.sh\n") {
+		t.Errorf("connect-script missing:\n%s", generateOCservConfig(cfg))
+	}
+	if !needsRestart([]string{"connect-script"}) {
+		t.Error("connect-script change applied without restart")
+	}
+}
@@ -1,58 +1,109 @@
 package openconnect
 
 import (
+	"bytes"
+	"context"
 	"eidolonVPN/internal/config"
 	"eidolonVPN/internal/config/structures"
 	"eidolonVPN/internal/errors"
+	stderrors "errors"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
 	"sync"
 	"syscall"
+	"time"
 )
 
+// Время ожидания завершения процесса после SIGTERM по умолчанию
+const defaultStopTimeout = 10 * time.Second
+
+// ErrAlreadyRunning возвращается Start, если процесс уже запущен
+var ErrAlreadyRunning = stderrors.New("process already running")
+
 // Manager управляет процессом OpenConnect
 type Manager struct {
 	cmd        *exec.Cmd
 	config     structures.OpenConnectConfig
 	configPath string
 	running    bool
+	stopping   bool // Остановка запрошена через Stop/Restart
+	done       chan struct{}
+	lastExit   ExitStatus
 	mutex      sync.Mutex
 	logWriter  io.Writer
+	onReload   func(ReloadEvent)
+	lastReload ReloadEvent // Последнее примененное изменение ocserv.conf
+	onExit     func(ExitStatus)
+	onStart    func(supervised bool)
 }
 
-// NewManager создает новый экземпляр менеджера OpenConnect
-func NewManager(configPath string) (*Manager, error) {
-	var ocConfig structures.OpenConnectConfig
+// ExitStatus описывает завершение процесса ocserv
+type ExitStatus struct {
+	Err       error     // Ошибка Wait, nil при нормальном завершении
+	Code      int       // Код выхода, -1 если процесс убит сигналом
+	Requested bool      // Завершение было запрошено через Stop/Restart
+	StartedAt time.Time // Время запуска процесса
+	Time      time.Time // Время завершения
+}
 
-	err := config.LoadConfig("openconnect", []string{"/eidolon/service/config"}, &ocConfig)
-	if err != nil {
-		return nil, err
+// Failed сообщает, завершился ли процесс с ошибкой
+func (s ExitStatus) Failed() bool {
+	return s.Err != nil
+}
+
+// NewManager создает новый экземпляр менеджера OpenConnect.
+// Менеджер подписывается на реестр и применяет каждую новую версию конфигурации
+func NewManager(registry *config.Registry, configPath string) (*Manager, error) {
+	if registry == nil {
+		return nil, errors.CallOpenConnectError("Config registry is not provided", nil)
 	}
 
-	return &Manager{
-		config:     ocConfig,
+	m := &Manager{
+		config:     registry.Current().OpenConnect,
 		configPath: configPath,
 		running:    false,
 		logWriter:  os.Stdout, // По умолчанию логи в stdout
-	}, nil
+	}
+
+	registry.Subscribe(func(snapshot *config.Snapshot) {
+		_, err := m.Apply(snapshot.OpenConnect)
+		if err != nil {
+			fmt.Printf("Failed to apply OpenConnect config v%d: %v\n", snapshot.Version, err)
+		}
+	})
+
+	return m, nil
 }
 
 // Start запускает процесс OpenConnect
 func (m *Manager) Start() error {
+	return m.start(false)
+}
+
+// start запускает процесс; supervised - запуск выполняет супервизор после падения
+func (m *Manager) start(supervised bool) error {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
 	if m.running {
-		return errors.CallOpenConnectError("Process already running", nil)
+		return errors.CallOpenConnectError("Failed to start OpenConnect", ErrAlreadyRunning)
 	}
 
 	// Проверяем наличие конфигурации
-	exists, err := CheckOCconfig(m.configPath)
+	exists, err := CheckOCconfig(m.config, m.configPath)
 	if err != nil || !exists {
 		// Генерируем конфигурацию, если она не существует или неверна
-		err = GenerateOCconfig("/eidolon/service/config", m.configPath)
+		err = GenerateOCconfig(m.config, m.configPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	// connect-script должен существовать до первого подключения
+	if m.config.Security.Ban.List != "" {
+		err = WriteBanScript(m.config.Security.Ban.List)
 		if err != nil {
 			return err
 		}
@@ -73,8 +124,8 @@ func (m *Manager) Start() error {
 	}
 
 	// Объединяем stdout и stderr в один writer
-	go io.Copy(m.logWriter, stdout)
-	go io.Copy(m.logWriter, stderr)
+	go io.Copy(m.outputWriter(), stdout)
+	go io.Copy(m.outputWriter(), stderr)
 
 	// Запускаем процесс
 	err = m.cmd.Start()
@@ -83,12 +134,30 @@ func (m *Manager) Start() error {
 	}
 
 	m.running = true
+	m.stopping = false
+	m.done = make(chan struct{})
+
+	// Обработчик вызывается без блокировки менеджера
+	if handler := m.onStart; handler != nil {
+		defer func() { go handler(supervised) }()
+	}
 
 	// Запускаем горутину для отслеживания завершения процесса
+	cmd, done, startedAt := m.cmd, m.done, time.Now()
 	go func() {
-		err := m.cmd.Wait()
+		err := cmd.Wait()
 		m.mutex.Lock()
 		m.running = false
+		status := ExitStatus{
+			Err:       err,
+			Code:      cmd.ProcessState.ExitCode(),
+			Requested: m.stopping,
+			StartedAt: startedAt,
+			Time:      time.Now(),
+		}
+		m.lastExit = status
+		handler := m.onExit
+		close(done)
 		m.mutex.Unlock()
 
 		if err != nil {
@@ -96,32 +165,61 @@ func (m *Manager) Start() error {
 		} else {
 			fmt.Println("OpenConnect process exited normally")
 		}
+
+		if handler != nil {
+			handler(status)
+		}
 	}()
 
 	return nil
 }
 
-// Stop останавливает процесс OpenConnect
-func (m *Manager) Stop() error {
+// Stop останавливает процесс OpenConnect и ждет его завершения.
+// Если процесс не завершился за отведенное время или контекст отменен, посылается SIGKILL
+func (m *Manager) Stop(ctx context.Context) (ExitStatus, error) {
 	m.mutex.Lock()
-	defer m.mutex.Unlock()
-
 	if !m.running {
-		return errors.CallOpenConnectError("Process not running", nil)
+		m.mutex.Unlock()
+		return ExitStatus{}, errors.CallOpenConnectError("Process not running", nil)
+	}
+
+	m.stopping = true
+	process, done := m.cmd.Process, m.done
+	timeout := m.config.Supervisor.StopTimeout
+	m.mutex.Unlock()
+
+	if timeout <= 0 {
+		timeout = defaultStopTimeout
 	}
 
 	// Посылаем SIGTERM для graceful shutdown
-	err := m.cmd.Process.Signal(syscall.SIGTERM)
+	err := process.Signal(syscall.SIGTERM)
 	if err != nil {
 		// Если не удалось послать SIGTERM, принудительно завершаем
-		err = m.cmd.Process.Kill()
+		err = process.Kill()
 		if err != nil {
-			return errors.CallOpenConnectError("Failed to kill process", err)
+			return ExitStatus{}, errors.CallOpenConnectError("Failed to kill process", err)
 		}
 	}
 
-	// Статус обновится в горутине Wait
-	return nil
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+	case <-timer.C:
+		fmt.Printf("OpenConnect did not exit within %v, sending SIGKILL\n", timeout)
+		process.Kill()
+		<-done
+	case <-ctx.Done():
+		process.Kill()
+		<-done
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.lastExit, nil
 }
 
 // IsRunning проверяет, запущен ли процесс
@@ -132,6 +230,125 @@ func (m *Manager) IsRunning() bool {
 	return m.running
 }
 
+// Restart останавливает процесс и запускает его заново
+func (m *Manager) Restart() error {
+	if m.IsRunning() {
+		_, err := m.Stop(context.Background())
+		if err != nil {
+			return err
+		}
+	}
+
+	return m.Start()
+}
+
+// Signal посылает сигнал запущенному процессу
+func (m *Manager) Signal(sig os.Signal) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if !m.running {
+		return errors.CallOpenConnectError("Process not running", nil)
+	}
+
+	err := m.cmd.Process.Signal(sig)
+	if err != nil {
+		return errors.CallOpenConnectError(fmt.Sprintf("Failed to send %v to process", sig), err)
+	}
+
+	return nil
+}
+
+// OnExit устанавливает обработчик завершения процесса
+func (m *Manager) OnExit(handler func(ExitStatus)) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.onExit = handler
+}
+
+// onStarted устанавливает обработчик успешного запуска процесса
+func (m *Manager) onStarted(handler func(supervised bool)) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.onStart = handler
+}
+
+// Occtl возвращает клиент управляющего интерфейса запущенного ocserv
+func (m *Manager) Occtl() *OcctlClient {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return NewOcctlClient(m.config.OcctlSocket)
+}
+
+// ApplyRevocation перечитывает CRL в ocserv и отключает живые сессии пользователя.
+// Новые сессии с отозванным сертификатом ocserv отклонит после SIGHUP
+func (m *Manager) ApplyRevocation(ctx context.Context, userID string) error {
+	if !m.IsRunning() {
+		return nil
+	}
+
+	err := m.Signal(syscall.SIGHUP)
+	if err != nil {
+		return err
+	}
+
+	// occtl считает ошибкой отключение пользователя без сессий
+	sessions, err := m.Occtl().ListUsers(ctx)
+	if err != nil {
+		return err
+	}
+	for _, session := range sessions {
+		if session.Username == userID {
+			return m.Occtl().Disconnect(ctx, userID)
+		}
+	}
+	return nil
+}
+
+// supervisorConfig возвращает текущие настройки супервизора
+func (m *Manager) supervisorConfig() structures.SupervisorConfig {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.config.Supervisor
+}
+
+// outputWriter возвращает writer для вывода ocserv с учетом настроек отладки
+func (m *Manager) outputWriter() io.Writer {
+	if m.config.Debug.Timestamp {
+		return &timestampWriter{out: m.logWriter, lineStart: true}
+	}
+	return m.logWriter
+}
+
+// timestampWriter добавляет метку времени в начало каждой строки
+type timestampWriter struct {
+	out       io.Writer
+	lineStart bool
+}
+
+// Write реализует io.Writer
+func (w *timestampWriter) Write(p []byte) (int, error) {
+	var buf bytes.Buffer
+	for _, b := range p {
+		if w.lineStart {
+			buf.WriteString(time.Now().Format(time.RFC3339))
+			buf.WriteByte(' ')
+		}
+		buf.WriteByte(b)
+		w.lineStart = b == '\n'
+	}
+
+	_, err := w.out.Write(buf.Bytes())
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
 // SetLogWriter устанавливает writer для вывода логов
 func (m *Manager) SetLogWriter(writer io.Writer) {
 	m.mutex.Lock()
@@ -0,0 +1,196 @@
+package openconnect
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeOcserv подкладывает testdata/fake-ocserv.sh под именем ocserv в PATH и возвращает
+// менеджер с базовой конфигурацией и путь к журналу запусков и сигналов
+func fakeOcserv(t *testing.T, mode string) (*Manager, string) {
+	t.Helper()
+
+	script, err := filepath.Abs(filepath.Join("testdata", "fake-ocserv.sh"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	err = os.Symlink(script, filepath.Join(dir, "ocserv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := filepath.Join(dir, "ocserv.log")
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("FAKE_OCSERV_LOG", log)
+	t.Setenv("FAKE_OCSERV_MODE", mode)
+
+	m := &Manager{
+		config:     baseConfig(),
+		configPath: filepath.Join(dir, "ocserv.conf"),
+		logWriter:  io.Discard,
+	}
+	t.Cleanup(func() {
+		if m.IsRunning() {
+			m.mutex.Lock()
+			m.stopping = true
+			m.cmd.Process.Kill()
+			done := m.done
+			m.mutex.Unlock()
+			<-done
+		}
+	})
+	return m, log
+}
+
+// logLines возвращает записи журнала фиктивного ocserv
+func logLines(t *testing.T, log string) []string {
+	t.Helper()
+
+	data, err := os.ReadFile(log)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Fields(strings.ReplaceAll(string(data), "start ", "start-"))
+}
+
+// countPrefix считает записи журнала с префиксом
+func countPrefix(lines []string, prefix string) int {
+	count := 0
+	for _, line := range lines {
+		if strings.HasPrefix(line, prefix) {
+			count++
+		}
+	}
+	return count
+}
+
+// waitLog ждет, пока в журнале не станет count записей с префиксом
+func waitLog(t *testing.T, log, prefix string, count int) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for countPrefix(logLines(t, log), prefix) < count {
+		if time.Now().After(deadline) {
+			t.Fatalf("waiting for %d %q in log, got %q", count, prefix, logLines(t, log))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestNeedsRestart(t *testing.T) {
+	tests := []struct {
+		changed []string
+		restart bool
+	}{
+		{nil, false},
+		{[]string{"mtu"}, false},
+		{[]string{"dns", "route", "no-route", "max-clients"}, false},
+		{[]string{"mtu", "tcp-port"}, true},
+		{[]string{"auth"}, true},
+		{[]string{"udp-port"}, true},
+		{[]string{"socket-file"}, true},
+	}
+
+	for _, tt := range tests {
+		if got := needsRestart(tt.changed); got != tt.restart {
+			t.Errorf("needsRestart(%q) = %v, want %v", tt.changed, got, tt.restart)
+		}
+	}
+}
+
+func TestApplySignalsOrRestarts(t *testing.T) {
+	m, log := fakeOcserv(t, "")
+	if err := m.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	waitLog(t, log, "start", 1)
+
+	// Изменение MTU ocserv перечитывает по SIGHUP
+	cfg := baseConfig()
+	cfg.Network.MTU = 1300
+	event, err := m.Apply(cfg)
+	if err != nil {
+		t.Fatalf("Apply mtu: %v", err)
+	}
+	if event.Restart || !slices.Equal(event.ChangedKeys, []string{"mtu"}) {
+		t.Errorf("mtu change: %+v", event)
+	}
+	waitLog(t, log, "hup", 1)
+
+	// Порт применяется только перезапуском
+	cfg.Port = 8443
+	event, err = m.Apply(cfg)
+	if err != nil {
+		t.Fatalf("Apply port: %v", err)
+	}
+	if !event.Restart || !slices.Equal(event.ChangedKeys, []string{"tcp-port"}) {
+		t.Errorf("port change: %+v", event)
+	}
+	waitLog(t, log, "start", 2)
+
+	lines := logLines(t, log)
+	if countPrefix(lines, "term") != 1 || countPrefix(lines, "hup") != 1 {
+		t.Errorf("unexpected signals: %q", lines)
+	}
+	if last := m.LastReload(); !last.Restart || last.Err != nil {
+		t.Errorf("LastReload = %+v", last)
+	}
+
+	// Повторное применение той же конфигурации ничего не делает
+	event, err = m.Apply(cfg)
+	if err != nil || len(event.ChangedKeys) != 0 {
+		t.Errorf("repeated Apply: %+v, %v", event, err)
+	}
+}
+
+func TestStopEscalatesToKill(t *testing.T) {
+	m, log := fakeOcserv(t, "stubborn")
+	m.config.Supervisor.StopTimeout = 200 * time.Millisecond
+	if err := m.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	waitLog(t, log, "start", 1)
+
+	started := time.Now()
+	status, err := m.Stop(context.Background())
+	if err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if elapsed := time.Since(started); elapsed < 200*time.Millisecond {
+		t.Errorf("SIGKILL sent after %v, before stop timeout", elapsed)
+	}
+	if !status.Requested || status.Code != -1 {
+		t.Errorf("exit status: %+v, want requested kill", status)
+	}
+	if m.IsRunning() {
+		t.Error("process still running after Stop")
+	}
+	if countPrefix(logLines(t, log), "term-ignored") == 0 {
+		t.Error("SIGTERM was not sent before SIGKILL")
+	}
+}
+
+func TestStopGraceful(t *testing.T) {
+	m, log := fakeOcserv(t, "")
+	if err := m.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	waitLog(t, log, "start", 1)
+
+	status, err := m.Stop(context.Background())
+	if err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if !status.Requested || status.Code != 0 || status.Failed() {
+		t.Errorf("exit status: %+v, want clean requested exit", status)
+	}
+}
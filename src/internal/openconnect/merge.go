@@ -0,0 +1,190 @@
+package openconnect
+
+import (
+	"eidolonVPN/internal/errors/handlers"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Формат метки времени в имени резервной копии; наносекунды различают записи в одну секунду,
+// а лексикографический порядок имен совпадает с хронологическим
+const backupTimeFormat = "20060102-150405.000000000"
+
+// Сколько резервных копий ocserv.conf хранить, более старые удаляются
+const maxConfigBackups = 10
+
+// MergeOCconfig накладывает сгенерированные директивы на существующий конфиг.
+// Eidolon владеет только своими директивами: они заменяются на месте первого
+// вхождения, остальные строки оператора и комментарии сохраняются.
+// Возвращает итоговый документ и список директив, значения которых были перезаписаны
+func MergeOCconfig(existing, generated *OCDocument) (*OCDocument, []ConfigMismatch) {
+	owned := make(map[string]bool)
+	for _, key := range managedDirectives {
+		owned[key] = true
+	}
+	for _, key := range generated.Keys() {
+		owned[key] = true
+	}
+
+	conflicts := CompareOCconfig(generated, existing)
+	// Отсутствующая директива - не конфликт, а просто новая настройка
+	filtered := conflicts[:0]
+	for _, conflict := range conflicts {
+		if len(conflict.Actual) > 0 {
+			filtered = append(filtered, conflict)
+		}
+	}
+
+	merged := &OCDocument{}
+	emitted := make(map[string]bool)
+	for _, line := range existing.Lines {
+		if !owned[line.Key] {
+			merged.Lines = append(merged.Lines, line)
+			continue
+		}
+		// Первое вхождение директивы заменяем сгенерированными значениями
+		if !emitted[line.Key] {
+			merged.Lines = append(merged.Lines, generatedLines(generated, line.Key)...)
+			emitted[line.Key] = true
+		}
+	}
+
+	// Новые директивы добавляем в конец
+	for _, key := range generated.Keys() {
+		if !emitted[key] {
+			merged.Lines = append(merged.Lines, generatedLines(generated, key)...)
+		}
+	}
+
+	return merged, filtered
+}
+
+// WriteOCconfig записывает сгенерированный конфиг, сохраняя правки оператора.
+// Перед перезаписью существующего файла рядом создается резервная копия
+func WriteOCconfig(targetPath string, content string) ([]ConfigMismatch, error) {
+	generated, err := ParseOCconfig(content)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(targetPath)
+	if os.IsNotExist(err) {
+		return nil, writeFileAtomic(targetPath, []byte(generated.String()), 0644)
+	}
+	if err != nil {
+		return nil, handlers.OpenConnectFileErrHandler(targetPath, err)
+	}
+
+	existing, err := ParseOCconfig(string(data))
+	if err != nil {
+		// Нечитаемый файл сохраняем в резервной копии и заменяем целиком
+		existing = &OCDocument{}
+	}
+
+	merged, conflicts := MergeOCconfig(existing, generated)
+	result := merged.String()
+	if result == string(data) {
+		return conflicts, nil
+	}
+
+	_, err = backupFile(targetPath, data)
+	if err != nil {
+		return conflicts, err
+	}
+
+	for _, conflict := range conflicts {
+		fmt.Printf("ocserv.conf conflict, overwritten: %s\n", conflict)
+	}
+
+	return conflicts, writeFileAtomic(targetPath, []byte(result), 0644)
+}
+
+// generatedLines возвращает все строки директивы из сгенерированного документа
+func generatedLines(doc *OCDocument, key string) []OCLine {
+	var lines []OCLine
+	for _, line := range doc.Lines {
+		if line.Key == key {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// backupFile сохраняет копию содержимого рядом с файлом с меткой времени
+// и удаляет копии сверх maxConfigBackups
+func backupFile(path string, data []byte) (string, error) {
+	stamp := time.Now().Format(backupTimeFormat)
+	backupPath := fmt.Sprintf("%s.%s.bak", path, stamp)
+
+	// O_EXCL не дает перезаписать чужую копию; при совпадении имени добавляется счетчик
+	var file *os.File
+	var err error
+	for i := 1; ; i++ {
+		file, err = os.OpenFile(backupPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if !os.IsExist(err) {
+			break
+		}
+		backupPath = fmt.Sprintf("%s.%s-%d.bak", path, stamp, i)
+	}
+	if err != nil {
+		return "", handlers.OpenConnectFileErrHandler(backupPath, err)
+	}
+
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(backupPath)
+		return "", handlers.OpenConnectFileErrHandler(backupPath, err)
+	}
+
+	pruneBackups(path)
+	return backupPath, nil
+}
+
+// pruneBackups оставляет только maxConfigBackups последних резервных копий файла
+func pruneBackups(path string) {
+	backups, err := filepath.Glob(path + ".*.bak")
+	if err != nil || len(backups) <= maxConfigBackups {
+		return
+	}
+
+	sort.Strings(backups)
+	for _, old := range backups[:len(backups)-maxConfigBackups] {
+		err = os.Remove(old)
+		if err != nil {
+			fmt.Printf("Failed to remove old backup %s: %v\n", old, err)
+		}
+	}
+}
+
+// writeFileAtomic записывает файл через временный файл и rename
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return handlers.OpenConnectFileErrHandler(path, err)
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Chmod(perm)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return handlers.OpenConnectFileErrHandler(path, err)
+	}
+
+	err = os.Rename(tmpPath, path)
+	if err != nil {
+		return handlers.OpenConnectFileErrHandler(path, err)
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package openconnect
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBackupFileUniqueAndPruned(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ocserv.conf")
+
+	seen := map[string]bool{}
+	for i := 0; i < maxConfigBackups+5; i++ {
+		backup, err := backupFile(path, []byte{byte(i)})
+		if err != nil {
+			t.Fatalf("backupFile: %v", err)
+		}
+		if seen[backup] {
+			t.Fatalf("backup %s written twice", backup)
+		}
+		seen[backup] = true
+	}
+
+	backups, err := filepath.Glob(path + ".*.bak")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != maxConfigBackups {
+		t.Fatalf("got %d backups, want %d", len(backups), maxConfigBackups)
+	}
+
+	// Остаются последние копии
+	for _, backup := range backups {
+		data, err := os.ReadFile(backup)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if int(data[0]) < 5 {
+			t.Errorf("old backup %s with content %d was kept", backup, data[0])
+		}
+	}
+}
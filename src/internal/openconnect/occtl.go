@@ -0,0 +1,255 @@
+package openconnect
+
+import (
+	"bytes"
+	"context"
+	"eidolonVPN/internal/errors"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Время ожидания ответа occtl по умолчанию
+const defaultOcctlTimeout = 10 * time.Second
+
+// OcctlClient управляет ocserv через управляющий интерфейс occtl
+type OcctlClient struct {
+	binary  string
+	socket  string
+	timeout time.Duration
+}
+
+// OcctlUser описывает подключенного пользователя
+type OcctlUser struct {
+	ID            int       `json:"ID"`
+	Username      string    `json:"Username"`
+	Groupname     string    `json:"Groupname"`
+	State         string    `json:"State"`
+	Vhost         string    `json:"vhost"`
+	Device        string    `json:"Device"`
+	MTU           occtlInt  `json:"MTU"`
+	RemoteIP      string    `json:"Remote IP"`
+	Location      string    `json:"Location"`
+	LocalDeviceIP string    `json:"Local Device IP"`
+	IPv4          string    `json:"IPv4"`
+	PTPIPv4       string    `json:"P-t-P IPv4"`
+	IPv6          string    `json:"IPv6"`
+	UserAgent     string    `json:"User-Agent"`
+	RX            occtlInt  `json:"RX"`
+	TX            occtlInt  `json:"TX"`
+	ConnectedAt   occtlInt  `json:"raw_connected_at"`
+	Session       string    `json:"Session"`
+	FullSession   string    `json:"Full session"`
+	TLSCipher     string    `json:"TLS ciphersuite"`
+	DTLSCipher    string    `json:"DTLS cipher"`
+	DNS           occtlList `json:"DNS"`
+	Routes        occtlList `json:"Routes"`
+	NoRoutes      occtlList `json:"No-routes"`
+}
+
+// OcctlStatus описывает общее состояние сервера
+type OcctlStatus struct {
+	Status           string   `json:"Status"`
+	ServerPID        occtlInt `json:"Server PID"`
+	SecModPID        occtlInt `json:"Sec-mod PID"`
+	UpSince          occtlInt `json:"raw_up_since"`
+	ActiveSessions   occtlInt `json:"Active sessions"`
+	TotalSessions    occtlInt `json:"Total sessions"`
+	TotalAuthFails   occtlInt `json:"Total authentication failures"`
+	IPsInBanList     occtlInt `json:"IPs in ban list"`
+	SessionsHandled  occtlInt `json:"Sessions handled"`
+	TimedOutSessions occtlInt `json:"Timed out sessions"`
+	AuthFailures     occtlInt `json:"Authentication failures"`
+	RX               occtlInt `json:"RX"`
+	TX               occtlInt `json:"TX"`
+}
+
+// OcctlBan описывает заблокированный IP адрес
+type OcctlBan struct {
+	IP    string   `json:"IP"`
+	Since occtlInt `json:"raw_since"`
+	Score occtlInt `json:"Score"`
+}
+
+// occtlInt принимает числа, которые occtl отдает как числом, так и строкой
+type occtlInt int64
+
+// UnmarshalJSON разбирает число или строку с числом
+func (i *occtlInt) UnmarshalJSON(data []byte) error {
+	raw := strings.Trim(strings.TrimSpace(string(data)), `"`)
+	if raw == "" || raw == "null" {
+		*i = 0
+		return nil
+	}
+
+	value, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return err
+	}
+	*i = occtlInt(value)
+	return nil
+}
+
+// occtlList принимает списки, которые occtl отдает как массивом, так и строкой:
+// для сессии без своих маршрутов "Routes" приходит как "defaultroute"
+type occtlList []string
+
+// UnmarshalJSON разбирает массив строк или одну строку
+func (l *occtlList) UnmarshalJSON(data []byte) error {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 || bytes.Equal(trimmed, []byte("null")) {
+		*l = nil
+		return nil
+	}
+
+	if trimmed[0] == '"' {
+		var value string
+		err := json.Unmarshal(trimmed, &value)
+		if err != nil {
+			return err
+		}
+		*l = nil
+		if value = strings.TrimSpace(value); value != "" {
+			*l = occtlList{value}
+		}
+		return nil
+	}
+
+	var values []string
+	err := json.Unmarshal(trimmed, &values)
+	if err != nil {
+		return err
+	}
+	*l = values
+	return nil
+}
+
+// NewOcctlClient создает клиент occtl для указанного управляющего сокета
+func NewOcctlClient(socket string) *OcctlClient {
+	return &OcctlClient{
+		binary:  "occtl",
+		socket:  socket,
+		timeout: defaultOcctlTimeout,
+	}
+}
+
+// SetBinary задает путь к исполняемому файлу occtl
+func (c *OcctlClient) SetBinary(binary string) {
+	c.binary = binary
+}
+
+// SetTimeout задает время ожидания ответа occtl
+func (c *OcctlClient) SetTimeout(timeout time.Duration) {
+	c.timeout = timeout
+}
+
+// ListUsers возвращает список подключенных пользователей
+func (c *OcctlClient) ListUsers(ctx context.Context) ([]OcctlUser, error) {
+	var users []OcctlUser
+	err := c.query(ctx, &users, "show", "users")
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// ShowUser возвращает информацию о сессии по ее ID
+func (c *OcctlClient) ShowUser(ctx context.Context, id int) (OcctlUser, error) {
+	var users []OcctlUser
+	err := c.query(ctx, &users, "show", "id", strconv.Itoa(id))
+	if err != nil {
+		return OcctlUser{}, err
+	}
+	if len(users) == 0 {
+		return OcctlUser{}, errors.CallOpenConnectError(fmt.Sprintf("Session %d not found", id), nil)
+	}
+	return users[0], nil
+}
+
+// Disconnect отключает все сессии пользователя
+func (c *OcctlClient) Disconnect(ctx context.Context, user string) error {
+	_, err := c.run(ctx, "disconnect", "user", user)
+	return err
+}
+
+// DisconnectID отключает одну сессию по ее ID
+func (c *OcctlClient) DisconnectID(ctx context.Context, id int) error {
+	_, err := c.run(ctx, "disconnect", "id", strconv.Itoa(id))
+	return err
+}
+
+// ShowStatus возвращает состояние сервера
+func (c *OcctlClient) ShowStatus(ctx context.Context) (OcctlStatus, error) {
+	var status OcctlStatus
+	err := c.query(ctx, &status, "show", "status")
+	return status, err
+}
+
+// ShowIPBans возвращает список заблокированных IP адресов
+func (c *OcctlClient) ShowIPBans(ctx context.Context) ([]OcctlBan, error) {
+	var bans []OcctlBan
+	err := c.query(ctx, &bans, "show", "ip", "bans")
+	if err != nil {
+		return nil, err
+	}
+	return bans, nil
+}
+
+// Unban снимает блокировку с IP адреса
+func (c *OcctlClient) Unban(ctx context.Context, ip string) error {
+	_, err := c.run(ctx, "unban", "ip", ip)
+	return err
+}
+
+// query выполняет команду и разбирает JSON ответ
+func (c *OcctlClient) query(ctx context.Context, result interface{}, args ...string) error {
+	out, err := c.run(ctx, args...)
+	if err != nil {
+		return err
+	}
+
+	// На пустой список occtl может ничего не вывести
+	if len(bytes.TrimSpace(out)) == 0 {
+		return nil
+	}
+
+	err = json.Unmarshal(out, result)
+	if err != nil {
+		return errors.CallOpenConnectError(fmt.Sprintf("Failed to parse occtl output for '%s'", strings.Join(args, " ")), err)
+	}
+	return nil
+}
+
+// run запускает occtl с заданными аргументами
+func (c *OcctlClient) run(ctx context.Context, args ...string) ([]byte, error) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	fullArgs := []string{"--json"}
+	if c.socket != "" {
+		fullArgs = append(fullArgs, "--socket-file", c.socket)
+	}
+	fullArgs = append(fullArgs, args...)
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, c.binary, fullArgs...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = strings.TrimSpace(stdout.String())
+		}
+		return nil, errors.CallOpenConnectError(fmt.Sprintf("occtl '%s' failed: %s", strings.Join(args, " "), msg), err)
+	}
+
+	return stdout.Bytes(), nil
+}
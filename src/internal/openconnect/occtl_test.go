@@ -0,0 +1,158 @@
+package openconnect
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// fakeOcctl возвращает клиент, работающий через testdata/fake-occtl.sh, и путь к журналу вызовов
+func fakeOcctl(t *testing.T) (*OcctlClient, string) {
+	t.Helper()
+
+	binary, err := filepath.Abs(filepath.Join("testdata", "fake-occtl.sh"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := filepath.Join(t.TempDir(), "occtl.log")
+	t.Setenv("FAKE_OCCTL_LOG", log)
+
+	client := NewOcctlClient("/run/test-occtl.socket")
+	client.SetBinary(binary)
+	return client, log
+}
+
+func TestOcctlListUsers(t *testing.T) {
+	client, log := fakeOcctl(t)
+
+	users, err := client.ListUsers(context.Background())
+	if err != nil {
+		t.Fatalf("ListUsers: %v", err)
+	}
+	if len(users) != 2 {
+		t.Fatalf("got %d users, want 2", len(users))
+	}
+
+	alice, bob := users[0], users[1]
+	if alice.ID != 42 || alice.Username != "alice" || alice.RemoteIP != "203.0.113.10" {
+		t.Errorf("unexpected alice: %+v", alice)
+	}
+	if alice.MTU != 1400 || alice.RX != 1024 || alice.TX != 2048 || alice.ConnectedAt != 1700000000 {
+		t.Errorf("unexpected alice counters: MTU=%d RX=%d TX=%d connected=%d", alice.MTU, alice.RX, alice.TX, alice.ConnectedAt)
+	}
+	if !slices.Equal(alice.Routes, []string{"defaultroute"}) {
+		t.Errorf("alice routes = %q, want [defaultroute]", alice.Routes)
+	}
+	if !slices.Equal(alice.DNS, []string{"8.8.8.8", "8.8.4.4"}) || len(alice.NoRoutes) != 0 {
+		t.Errorf("unexpected alice DNS/no-routes: %q %q", alice.DNS, alice.NoRoutes)
+	}
+
+	if bob.RX != 0 || bob.TX != 0 {
+		t.Errorf("bob counters = %d/%d, want 0/0", bob.RX, bob.TX)
+	}
+	if !slices.Equal(bob.DNS, []string{"1.1.1.1"}) || !slices.Equal(bob.NoRoutes, []string{"192.168.0.0/255.255.0.0"}) {
+		t.Errorf("unexpected bob DNS/no-routes: %q %q", bob.DNS, bob.NoRoutes)
+	}
+
+	data, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(data)); got != "--json --socket-file /run/test-occtl.socket show users" {
+		t.Errorf("occtl called with %q", got)
+	}
+}
+
+func TestOcctlShowUser(t *testing.T) {
+	client, _ := fakeOcctl(t)
+
+	user, err := client.ShowUser(context.Background(), 42)
+	if err != nil {
+		t.Fatalf("ShowUser: %v", err)
+	}
+	if user.Username != "alice" || !slices.Equal(user.Routes, []string{"defaultroute"}) || user.DNS != nil {
+		t.Errorf("unexpected user: %+v", user)
+	}
+
+	_, err = client.ShowUser(context.Background(), 404)
+	if err == nil {
+		t.Error("ShowUser of missing session succeeded")
+	}
+}
+
+func TestOcctlShowStatus(t *testing.T) {
+	client, _ := fakeOcctl(t)
+
+	status, err := client.ShowStatus(context.Background())
+	if err != nil {
+		t.Fatalf("ShowStatus: %v", err)
+	}
+	if status.Status != "online" || status.ServerPID != 100 || status.SecModPID != 101 ||
+		status.ActiveSessions != 2 || status.IPsInBanList != 1 {
+		t.Errorf("unexpected status: %+v", status)
+	}
+}
+
+func TestOcctlDisconnect(t *testing.T) {
+	client, log := fakeOcctl(t)
+	ctx := context.Background()
+
+	if err := client.Disconnect(ctx, "alice"); err != nil {
+		t.Errorf("Disconnect alice: %v", err)
+	}
+	if err := client.DisconnectID(ctx, 42); err != nil {
+		t.Errorf("DisconnectID 42: %v", err)
+	}
+
+	err := client.Disconnect(ctx, "nobody")
+	if err == nil || !strings.Contains(err.Error(), "user or id not found") {
+		t.Errorf("Disconnect nobody: got %v, want occtl stderr in error", err)
+	}
+
+	data, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := strings.Split(strings.TrimSpace(string(data)), "\n")
+	want := []string{
+		"--json --socket-file /run/test-occtl.socket disconnect user alice",
+		"--json --socket-file /run/test-occtl.socket disconnect id 42",
+		"--json --socket-file /run/test-occtl.socket disconnect user nobody",
+	}
+	if !slices.Equal(calls, want) {
+		t.Errorf("occtl calls:\n%s\nwant:\n%s", strings.Join(calls, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestOcctlListUnmarshal(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{`"defaultroute"`, []string{"defaultroute"}},
+		{`["10.0.0.0/8", "192.168.0.0/16"]`, []string{"10.0.0.0/8", "192.168.0.0/16"}},
+		{`""`, nil},
+		{`null`, nil},
+		{`[]`, []string{}},
+	}
+	for _, tt := range tests {
+		var list occtlList
+		err := json.Unmarshal([]byte(tt.input), &list)
+		if err != nil {
+			t.Errorf("%s: %v", tt.input, err)
+			continue
+		}
+		if !slices.Equal(list, tt.want) || (tt.want == nil) != (list == nil) {
+			t.Errorf("%s: got %q, want %q", tt.input, list, tt.want)
+		}
+	}
+
+	var list occtlList
+	if err := json.Unmarshal([]byte(`42`), &list); err == nil {
+		t.Error("number accepted as list")
+	}
+}
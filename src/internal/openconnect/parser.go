@@ -0,0 +1,161 @@
+package openconnect
+
+import (
+	"eidolonVPN/internal/errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Директивы, порядок значений которых не важен
+var unorderedDirectives = map[string]bool{
+	"route":     true,
+	"no-route":  true,
+	"dns":       true,
+	"split-dns": true,
+	"nbns":      true,
+	"iroute":    true,
+}
+
+// OCLine - строка ocserv.conf: директива, комментарий или пустая строка
+type OCLine struct {
+	Raw    string // Исходный текст строки
+	Key    string // Имя директивы, пусто для комментариев и пустых строк
+	Value  string // Значение без кавычек
+	Quoted bool   // Было ли значение в кавычках
+}
+
+// IsDirective сообщает, содержит ли строка директиву
+func (l OCLine) IsDirective() bool {
+	return l.Key != ""
+}
+
+// OCDocument - разобранный ocserv.conf с сохранением комментариев и порядка строк
+type OCDocument struct {
+	Lines []OCLine
+}
+
+// ConfigMismatch описывает расхождение директивы с эталоном
+type ConfigMismatch struct {
+	Key      string
+	Expected []string
+	Actual   []string
+}
+
+// String формирует описание расхождения
+func (c ConfigMismatch) String() string {
+	switch {
+	case len(c.Actual) == 0:
+		return fmt.Sprintf("parameter %s is missing", c.Key)
+	case len(c.Expected) == 0:
+		return fmt.Sprintf("parameter %s is not expected", c.Key)
+	default:
+		return fmt.Sprintf("parameter %s has value %q, expected %q", c.Key, c.Actual, c.Expected)
+	}
+}
+
+// ParseOCconfig разбирает содержимое ocserv.conf
+func ParseOCconfig(content string) (*OCDocument, error) {
+	doc := &OCDocument{}
+
+	for i, raw := range strings.Split(strings.TrimSuffix(content, "\n"), "\n") {
+		line := OCLine{Raw: raw}
+		trimmed := strings.TrimSpace(raw)
+
+		// Комментарии и пустые строки сохраняем как есть
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			doc.Lines = append(doc.Lines, line)
+			continue
+		}
+
+		parts := strings.SplitN(trimmed, "=", 2)
+		if len(parts) != 2 {
+			return nil, errors.CallOpenConnectError(fmt.Sprintf("line %d: expected 'key = value', got '%s'", i+1, trimmed), nil)
+		}
+
+		line.Key = strings.TrimSpace(parts[0])
+		if line.Key == "" {
+			return nil, errors.CallOpenConnectError(fmt.Sprintf("line %d: empty directive name", i+1), nil)
+		}
+
+		value := strings.TrimSpace(parts[1])
+		if len(value) >= 2 && strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`) {
+			value = value[1 : len(value)-1]
+			line.Quoted = true
+		} else if strings.HasPrefix(value, `"`) {
+			return nil, errors.CallOpenConnectError(fmt.Sprintf("line %d: unterminated quote in '%s'", i+1, line.Key), nil)
+		}
+		line.Value = value
+
+		doc.Lines = append(doc.Lines, line)
+	}
+
+	return doc, nil
+}
+
+// Values возвращает все значения директивы в порядке появления
+func (d *OCDocument) Values(key string) []string {
+	var values []string
+	for _, line := range d.Lines {
+		if line.Key == key {
+			values = append(values, line.Value)
+		}
+	}
+	return values
+}
+
+// Keys возвращает имена директив в порядке первого появления
+func (d *OCDocument) Keys() []string {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, line := range d.Lines {
+		if line.IsDirective() && !seen[line.Key] {
+			seen[line.Key] = true
+			keys = append(keys, line.Key)
+		}
+	}
+	return keys
+}
+
+// String собирает документ обратно в текст
+func (d *OCDocument) String() string {
+	var b strings.Builder
+	for _, line := range d.Lines {
+		b.WriteString(line.Raw)
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// CompareOCconfig сравнивает директивы эталона с фактическим конфигом.
+// Директивы, отсутствующие в эталоне, не считаются расхождением
+func CompareOCconfig(expected, actual *OCDocument) []ConfigMismatch {
+	var mismatches []ConfigMismatch
+	for _, key := range expected.Keys() {
+		want := expected.Values(key)
+		got := actual.Values(key)
+		if !equalValues(key, want, got) {
+			mismatches = append(mismatches, ConfigMismatch{Key: key, Expected: want, Actual: got})
+		}
+	}
+	return mismatches
+}
+
+// equalValues сравнивает значения директивы с учетом порядка, если он важен
+func equalValues(key string, a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	if unorderedDirectives[key] {
+		a = append([]string(nil), a...)
+		b = append([]string(nil), b...)
+		sort.Strings(a)
+		sort.Strings(b)
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,141 @@
+package openconnect
+
+import (
+	"eidolonVPN/internal/config/structures"
+	"sort"
+	"syscall"
+	"time"
+)
+
+// Директивы ocserv, которые не применяются по SIGHUP и требуют полного перезапуска
+var restartDirectives = map[string]bool{
+	"auth":              true,
+	"enable-auth":       true,
+	"tcp-port":          true,
+	"udp-port":          true,
+	"listen-host":       true,
+	"device":            true,
+	"socket-file":       true,
+	"run-as-user":       true,
+	"run-as-group":      true,
+	"chroot-dir":        true,
+	"isolate-workers":   true,
+	"pid-file":          true,
+	"use-occtl":         true,
+	"occtl-socket-file": true,
+	"connect-script":    true,
+}
+
+// ReloadEvent описывает применённое изменение конфигурации
+type ReloadEvent struct {
+	ChangedKeys []string  // Изменившиеся директивы ocserv.conf
+	Restart     bool      // Потребовался ли полный перезапуск
+	Time        time.Time // Время применения
+	Err         error     // Ошибка применения, если была
+}
+
+// OnReload устанавливает обработчик событий перезагрузки конфигурации
+func (m *Manager) OnReload(handler func(ReloadEvent)) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.onReload = handler
+}
+
+// LastReload возвращает последнее примененное изменение ocserv.conf; нулевое Time - изменений не было
+func (m *Manager) LastReload() ReloadEvent {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.lastReload
+}
+
+// Apply применяет новую конфигурацию: перегенерирует ocserv.conf и
+// перезагружает ocserv по SIGHUP или перезапускает его, если это необходимо
+func (m *Manager) Apply(ocConfig structures.OpenConnectConfig) (ReloadEvent, error) {
+	m.mutex.Lock()
+	oldContent := generateOCservConfig(m.config)
+	newContent := generateOCservConfig(ocConfig)
+
+	changed := diffOCservConfig(oldContent, newContent)
+	if len(changed) == 0 {
+		m.config = ocConfig
+		m.mutex.Unlock()
+		return ReloadEvent{}, nil
+	}
+
+	event := ReloadEvent{
+		ChangedKeys: changed,
+		Restart:     needsRestart(changed),
+		Time:        time.Now(),
+	}
+
+	_, err := WriteOCconfig(m.configPath, newContent)
+	if err != nil {
+		event.Err = err
+		m.lastReload = event
+		m.mutex.Unlock()
+		return event, err
+	}
+	m.config = ocConfig
+	running := m.running
+	m.mutex.Unlock()
+
+	// Применяем изменения к запущенному процессу
+	if running {
+		if event.Restart {
+			err = m.Restart()
+		} else {
+			err = m.Signal(syscall.SIGHUP)
+		}
+	}
+	event.Err = err
+
+	m.mutex.Lock()
+	m.lastReload = event
+	handler := m.onReload
+	m.mutex.Unlock()
+	if handler != nil {
+		handler(event)
+	}
+
+	return event, err
+}
+
+// diffOCservConfig возвращает отсортированный список директив, значения которых различаются
+func diffOCservConfig(oldContent, newContent string) []string {
+	oldDoc, err := ParseOCconfig(oldContent)
+	if err != nil {
+		oldDoc = &OCDocument{}
+	}
+	newDoc, err := ParseOCconfig(newContent)
+	if err != nil {
+		newDoc = &OCDocument{}
+	}
+
+	seen := make(map[string]bool)
+	for _, mismatch := range CompareOCconfig(newDoc, oldDoc) {
+		seen[mismatch.Key] = true
+	}
+	for _, mismatch := range CompareOCconfig(oldDoc, newDoc) {
+		seen[mismatch.Key] = true
+	}
+
+	changed := make([]string, 0, len(seen))
+	for key := range seen {
+		changed = append(changed, key)
+	}
+
+	sort.Strings(changed)
+	return changed
+}
+
+// needsRestart проверяет, есть ли среди изменений директивы, требующие перезапуска
+func needsRestart(changed []string) bool {
+	for _, key := range changed {
+		if restartDirectives[key] {
+			return true
+		}
+	}
+	return false
+}
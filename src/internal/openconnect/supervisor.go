@@ -0,0 +1,244 @@
+package openconnect
+
+import (
+	"context"
+	"eidolonVPN/internal/config/structures"
+	"eidolonVPN/internal/errors"
+	stderrors "errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Политики перезапуска
+const (
+	RestartAlways    = "always"
+	RestartOnFailure = "on-failure"
+	RestartNever     = "never"
+)
+
+// Значения по умолчанию для супервизора
+const (
+	defaultInitialBackoff = time.Second
+	defaultMaxBackoff     = time.Minute
+	defaultMaxRestarts    = 5
+	defaultRestartWindow  = 5 * time.Minute
+)
+
+// Supervisor следит за процессом ocserv и перезапускает его согласно политике
+type Supervisor struct {
+	manager     *Manager
+	ctx         context.Context
+	mutex       sync.Mutex
+	restarts    int         // Общее число перезапусков
+	consecutive int         // Перезапусков подряд без стабильной работы
+	crashes     []time.Time // Падения в текущем окне
+	lastExit    *ExitStatus
+	gaveUp      bool
+	onCrash     func(SupervisorStatus)
+}
+
+// SupervisorStatus содержит состояние супервизора
+type SupervisorStatus struct {
+	Running    bool
+	Restarts   int
+	LastExit   *ExitStatus
+	LastReason string
+	GaveUp     bool // Перезапуски остановлены из-за crash loop
+}
+
+// NewSupervisor создает супервизор для менеджера
+func NewSupervisor(manager *Manager) *Supervisor {
+	return &Supervisor{manager: manager}
+}
+
+// Run запускает ocserv и следит за ним до отмены контекста
+func (s *Supervisor) Run(ctx context.Context) error {
+	s.mutex.Lock()
+	s.ctx = ctx
+	s.mutex.Unlock()
+
+	s.manager.OnExit(s.handleExit)
+	s.manager.onStarted(s.handleStart)
+
+	return s.manager.Start()
+}
+
+// OnCrash устанавливает обработчик незапрошенного завершения ocserv.
+// Вызывается после решения о перезапуске, поэтому видит GaveUp
+func (s *Supervisor) OnCrash(handler func(SupervisorStatus)) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.onCrash = handler
+}
+
+// Status возвращает текущее состояние супервизора
+func (s *Supervisor) Status() SupervisorStatus {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	status := SupervisorStatus{
+		Running:  s.manager.IsRunning(),
+		Restarts: s.restarts,
+		GaveUp:   s.gaveUp,
+	}
+	if s.lastExit != nil {
+		exit := *s.lastExit
+		status.LastExit = &exit
+		status.LastReason = exitReason(exit)
+	}
+	return status
+}
+
+// handleExit решает, нужно ли перезапускать завершившийся процесс
+func (s *Supervisor) handleExit(status ExitStatus) {
+	policy := withSupervisorDefaults(s.manager.supervisorConfig())
+
+	s.mutex.Lock()
+	s.lastExit = &status
+
+	if status.Requested || s.ctx == nil || s.ctx.Err() != nil {
+		s.mutex.Unlock()
+		return
+	}
+
+	// Обработчик вызывается после всех снятий блокировки
+	if handler := s.onCrash; handler != nil {
+		defer func() { handler(s.Status()) }()
+	}
+
+	if !shouldRestart(policy.RestartPolicy, status) {
+		s.mutex.Unlock()
+		return
+	}
+
+	// Процесс проработал дольше окна - считаем его стабильным
+	if status.Time.Sub(status.StartedAt) > policy.RestartWindow {
+		s.consecutive = 0
+	}
+
+	// Оставляем только падения внутри окна
+	s.crashes = append(s.crashes, status.Time)
+	cutoff := status.Time.Add(-policy.RestartWindow)
+	for len(s.crashes) > 0 && s.crashes[0].Before(cutoff) {
+		s.crashes = s.crashes[1:]
+	}
+	if len(s.crashes) > policy.MaxRestarts {
+		s.gaveUp = true
+		s.mutex.Unlock()
+		fmt.Printf("OpenConnect crash loop detected: %d exits in %v, giving up\n", len(s.crashes), policy.RestartWindow)
+		return
+	}
+
+	delay := backoff(policy, s.consecutive)
+	s.consecutive++
+	ctx := s.ctx
+	s.mutex.Unlock()
+
+	go s.restartAfter(ctx, delay)
+}
+
+// handleStart сбрасывает счетчики падений, если ocserv запущен не супервизором,
+// например командой /restart после остановки перезапусков
+func (s *Supervisor) handleStart(supervised bool) {
+	if supervised {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.gaveUp = false
+	s.consecutive = 0
+	s.crashes = nil
+}
+
+// restartAfter перезапускает процесс после задержки
+func (s *Supervisor) restartAfter(ctx context.Context, delay time.Duration) {
+	fmt.Printf("Restarting OpenConnect in %v\n", delay)
+
+	select {
+	case <-ctx.Done():
+		return
+	case <-time.After(delay):
+	}
+
+	err := s.manager.start(true)
+	if stderrors.Is(err, ErrAlreadyRunning) {
+		// Процесс уже запущен вручную, пока шла задержка
+		fmt.Println("OpenConnect is already running, restart skipped")
+		return
+	}
+	if err != nil {
+		fmt.Printf("Failed to restart OpenConnect: %v\n", err)
+		// Неудачный запуск считаем падением
+		now := time.Now()
+		s.handleExit(ExitStatus{Err: err, Code: -1, StartedAt: now, Time: now})
+		return
+	}
+
+	s.mutex.Lock()
+	s.restarts++
+	s.mutex.Unlock()
+}
+
+// shouldRestart применяет политику перезапуска
+func shouldRestart(policy string, status ExitStatus) bool {
+	switch policy {
+	case RestartAlways:
+		return true
+	case RestartOnFailure:
+		return status.Failed()
+	default:
+		return false
+	}
+}
+
+// backoff вычисляет экспоненциальную задержку с джиттером
+func backoff(policy structures.SupervisorConfig, attempt int) time.Duration {
+	delay := policy.InitialBackoff
+	for i := 0; i < attempt && delay < policy.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > policy.MaxBackoff {
+		delay = policy.MaxBackoff
+	}
+
+	// Джиттер до половины задержки
+	jitter := time.Duration(rand.Int63n(int64(delay)/2 + 1))
+	return delay/2 + jitter
+}
+
+// withSupervisorDefaults заполняет незаданные параметры значениями по умолчанию
+func withSupervisorDefaults(cfg structures.SupervisorConfig) structures.SupervisorConfig {
+	if cfg.RestartPolicy == "" {
+		cfg.RestartPolicy = RestartOnFailure
+	}
+	if cfg.InitialBackoff <= 0 {
+		cfg.InitialBackoff = defaultInitialBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = defaultMaxBackoff
+	}
+	if cfg.MaxRestarts <= 0 {
+		cfg.MaxRestarts = defaultMaxRestarts
+	}
+	if cfg.RestartWindow <= 0 {
+		cfg.RestartWindow = defaultRestartWindow
+	}
+	return cfg
+}
+
+// exitReason формирует человекочитаемую причину завершения
+func exitReason(status ExitStatus) string {
+	switch {
+	case status.Requested:
+		return "stopped on request"
+	case status.Err != nil:
+		return errors.CallOpenConnectError(fmt.Sprintf("exited with code %d", status.Code), status.Err).Error()
+	default:
+		return "exited normally"
+	}
+}
@@ -0,0 +1,109 @@
+package openconnect
+
+import (
+	"context"
+	"eidolonVPN/internal/config/structures"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	policy := structures.SupervisorConfig{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	tests := []struct {
+		attempt int
+		full    time.Duration // Задержка до джиттера
+	}{
+		{0, 100 * time.Millisecond},
+		{1, 200 * time.Millisecond},
+		{3, 800 * time.Millisecond},
+		{4, time.Second},
+		{20, time.Second},
+	}
+
+	for _, tt := range tests {
+		for i := 0; i < 50; i++ {
+			delay := backoff(policy, tt.attempt)
+			if delay < tt.full/2 || delay > tt.full {
+				t.Fatalf("backoff(attempt %d) = %v, want within [%v, %v]", tt.attempt, delay, tt.full/2, tt.full)
+			}
+		}
+	}
+}
+
+func TestShouldRestart(t *testing.T) {
+	failed := ExitStatus{Err: context.DeadlineExceeded, Code: 1}
+	clean := ExitStatus{}
+
+	tests := []struct {
+		policy string
+		status ExitStatus
+		want   bool
+	}{
+		{RestartAlways, clean, true},
+		{RestartOnFailure, failed, true},
+		{RestartOnFailure, clean, false},
+		{RestartNever, failed, false},
+	}
+	for _, tt := range tests {
+		if got := shouldRestart(tt.policy, tt.status); got != tt.want {
+			t.Errorf("shouldRestart(%s, failed=%v) = %v, want %v", tt.policy, tt.status.Failed(), got, tt.want)
+		}
+	}
+}
+
+func TestSupervisorGivesUpAfterMaxRestarts(t *testing.T) {
+	m, log := fakeOcserv(t, "crash")
+	m.config.Supervisor = structures.SupervisorConfig{
+		RestartPolicy:  RestartOnFailure,
+		InitialBackoff: 10 * time.Millisecond,
+		MaxBackoff:     40 * time.Millisecond,
+		MaxRestarts:    2,
+		RestartWindow:  time.Minute,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	supervisor := NewSupervisor(m)
+	gaveUp := make(chan SupervisorStatus, 1)
+	supervisor.OnCrash(func(status SupervisorStatus) {
+		if status.GaveUp {
+			gaveUp <- status
+		}
+	})
+	if err := supervisor.Run(ctx); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	select {
+	case status := <-gaveUp:
+		if status.LastExit == nil || !status.LastExit.Failed() {
+			t.Errorf("last exit not recorded: %+v", status)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("supervisor did not give up, log: %q", logLines(t, log))
+	}
+
+	// Первый запуск и два перезапуска, после отказа новых запусков нет
+	time.Sleep(200 * time.Millisecond)
+	if starts := countPrefix(logLines(t, log), "start"); starts != 3 {
+		t.Errorf("ocserv started %d times, want 3", starts)
+	}
+	status := supervisor.Status()
+	if !status.GaveUp || status.Restarts != 2 || status.Running {
+		t.Errorf("status after giving up: %+v", status)
+	}
+
+	// Ручной запуск сбрасывает отказ супервизора
+	if err := m.Start(); err != nil {
+		t.Fatalf("manual Start: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for supervisor.Status().GaveUp {
+		if time.Now().After(deadline) {
+			t.Fatal("manual start did not reset supervisor")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+}
@@ -0,0 +1,89 @@
+#!/bin/sh
+# Имитация occtl для тестов OcctlClient: пишет аргументы в $FAKE_OCCTL_LOG
+# и отдает ответы в формате occtl --json
+[ -n "$FAKE_OCCTL_LOG" ] && echo "$*" >> "$FAKE_OCCTL_LOG"
+
+# Пропускаем --json и --socket-file <path>
+while [ $# -gt 0 ]; do
+	case "$1" in
+	--json) shift ;;
+	--socket-file) shift 2 ;;
+	*) break ;;
+	esac
+done
+
+case "$*" in
+"show users")
+	cat <<'JSON'
+[
+  {
+    "ID": 42,
+    "Username": "alice",
+    "Groupname": "devs",
+    "State": "connected",
+    "vhost": "default",
+    "Device": "vpns0",
+    "MTU": "1400",
+    "Remote IP": "203.0.113.10",
+    "IPv4": "10.20.30.5",
+    "User-Agent": "OpenConnect",
+    "RX": "1024",
+    "TX": 2048,
+    "raw_connected_at": 1700000000,
+    "DNS": ["8.8.8.8", "8.8.4.4"],
+    "Routes": "defaultroute",
+    "No-routes": []
+  },
+  {
+    "ID": 43,
+    "Username": "bob",
+    "Remote IP": "198.51.100.7",
+    "RX": "",
+    "TX": "0",
+    "DNS": "1.1.1.1",
+    "Routes": ["10.0.0.0/255.0.0.0"],
+    "No-routes": "192.168.0.0/255.255.0.0"
+  }
+]
+JSON
+	;;
+"show id 42")
+	cat <<'JSON'
+[
+  {
+    "ID": 42,
+    "Username": "alice",
+    "Remote IP": "203.0.113.10",
+    "Routes": "defaultroute",
+    "DNS": null
+  }
+]
+JSON
+	;;
+"show id 404")
+	echo "[]"
+	;;
+"show status")
+	cat <<'JSON'
+{
+  "Status": "online",
+  "Server PID": 100,
+  "Sec-mod PID": "101",
+  "raw_up_since": 1700000000,
+  "Active sessions": 2,
+  "IPs in ban list": "1"
+}
+JSON
+	;;
+"disconnect user alice" | "disconnect id 42")
+	echo '{"status": "ok"}'
+	;;
+"disconnect user"*|"disconnect id"*)
+	echo "user or id not found" >&2
+	exit 1
+	;;
+*)
+	echo "unknown command: $*" >&2
+	exit 1
+	;;
+esac
@@ -0,0 +1,25 @@
+#!/bin/sh
+# Имитация ocserv для тестов Manager и Supervisor: пишет запуски и сигналы в $FAKE_OCSERV_LOG.
+# FAKE_OCSERV_MODE: crash - сразу завершиться с ошибкой, stubborn - игнорировать SIGTERM
+log() {
+	[ -n "$FAKE_OCSERV_LOG" ] && echo "$1" >> "$FAKE_OCSERV_LOG"
+}
+
+case "$FAKE_OCSERV_MODE" in
+crash)
+	log "start $$"
+	exit 1
+	;;
+stubborn)
+	trap 'log term-ignored' TERM
+	;;
+*)
+	trap 'log term; exit 0' TERM
+	;;
+esac
+trap 'log hup' HUP
+log "start $$"
+
+while :; do
+	sleep 0.05
+done
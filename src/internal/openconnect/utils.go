@@ -1,40 +1,39 @@
 package openconnect
 
 import (
-	"crypto/rand"
-	"crypto/rsa"
-	"crypto/x509"
-	"crypto/x509/pkix"
-	"eidolonVPN/internal/config"
 	"eidolonVPN/internal/config/structures"
 	"eidolonVPN/internal/errors"
 	"eidolonVPN/internal/errors/handlers"
-	"encoding/pem"
+	"eidolonVPN/internal/pki"
 	"fmt"
-	"math/big"
-	"net"
 	"os"
 	"path/filepath"
 	"strings"
-	"time"
 )
 
 // GenerateOCconfig генерирует файл конфигурации ocserv на основе YAML
-func GenerateOCconfig(sourcePath string, targetPath string) error {
-	var ocConfig structures.OpenConnectConfig
-
-	// Используем LoadConfig
-	// Передаем название файла и путь для поиска
-	err := config.LoadConfig("openconnect", []string{sourcePath}, &ocConfig)
-	if err != nil {
-		return handlers.OpenConnectYamlErrHandler(sourcePath, err)
-	}
-
+func GenerateOCconfig(ocConfig structures.OpenConnectConfig, targetPath string) error {
 	// Формируем содержимое файла ocserv
 	configContent := generateOCservConfig(ocConfig)
 
-	// Записываем файл
-	return os.WriteFile(targetPath, []byte(configContent), 0644)
+	// Записываем файл, сохраняя директивы, добавленные оператором
+	_, err := WriteOCconfig(targetPath, configContent)
+	return err
+}
+
+// Директивы, которыми управляет eidolon. При слиянии с конфигом оператора
+// их прежние значения удаляются, даже если новый конфиг их не содержит
+var managedDirectives = []string{
+	"auth", "enable-auth", "tcp-port", "udp-port", "device", "socket-file",
+	"use-occtl", "occtl-socket-file", "listen-clear-file", "isolate-workers",
+	"tls-priorities", "server-cert", "server-key", "ca-cert", "cert-user-oid", "crl",
+	"max-ban-score", "ban-points-wrong-password", "ban-points-connection", "ban-reset-time", "connect-script",
+	"max-clients", "max-same-clients", "rate-limit-ms",
+	"keepalive", "dpd", "mobile-dpd", "idle-timeout", "mobile-idle-timeout",
+	"default-domain", "ipv4-network", "ipv4-netmask", "ipv6-network", "mtu",
+	"dns", "tunnel-all-dns", "compression", "cisco-client-compat",
+	"route", "no-route", "config-per-user", "config-per-group", "default-group-config",
+	"log-level", "log-file",
 }
 
 // Генерация конфигурации ocserv.conf
@@ -50,6 +49,18 @@ func generateOCservConfig(config structures.OpenConnectConfig) string {
 		content += fmt.Sprintf("auth = \"%s\"\n", config.Security.Auth)
 	}
 
+	// Аутентификация по клиентским сертификатам
+	switch config.Security.CertAuth {
+	case "required":
+		content += "auth = \"certificate\"\n"
+	case "optional":
+		content += "enable-auth = \"certificate\"\n"
+	}
+	if config.Security.CertAuth != "" {
+		// Имя пользователя берется из CN сертификата
+		content += "cert-user-oid = 2.5.4.3\n"
+	}
+
 	content += fmt.Sprintf("tcp-port = %d\n", config.Port)
 
 	if config.Protocol == "udp" {
@@ -62,7 +73,20 @@ func generateOCservConfig(config structures.OpenConnectConfig) string {
 	// Сокет
 	content += fmt.Sprintf("socket-file = \"%s\"\n", config.Socket)
 
+	// Управляющий интерфейс occtl
+	if config.OcctlSocket != "" {
+		content += "use-occtl = true\n"
+		content += fmt.Sprintf("occtl-socket-file = \"%s\"\n", config.OcctlSocket)
+	}
+
+	// Открытый сокет без TLS для тестирования за reverse proxy
+	if config.Debug.NoHTTPS {
+		content += fmt.Sprintf("listen-clear-file = \"%s.clear\"\n", config.Socket)
+	}
+
 	// Безопасность
+	content += fmt.Sprintf("isolate-workers = %t\n", config.Security.IsolateWorkers)
+
 	if len(config.Security.AllowedCiphers) > 0 {
 		content += fmt.Sprintf("tls-priorities = %s\n",
 			strings.Join(config.Security.AllowedCiphers, ":"))
@@ -75,28 +99,102 @@ func generateOCservConfig(config structures.OpenConnectConfig) string {
 		content += fmt.Sprintf("server-key = %s%s\n", config.Security.CAPath, config.Security.CAKey)
 	}
 
+	// Проверка клиентских сертификатов
+	if config.Security.ClientCA != "" && !config.Security.NoCertCheck {
+		content += fmt.Sprintf("ca-cert = %s\n", config.Security.ClientCA)
+		if config.Security.CRL != "" {
+			content += fmt.Sprintf("crl = %s\n", config.Security.CRL)
+		}
+	}
+
+	// Блокировка IP
+	if config.Security.Ban.MaxScore > 0 {
+		content += fmt.Sprintf("max-ban-score = %d\n", config.Security.Ban.MaxScore)
+		content += fmt.Sprintf("ban-points-wrong-password = %d\n", config.Security.Ban.WrongPassword)
+		content += fmt.Sprintf("ban-points-connection = %d\n", config.Security.Ban.Connection)
+		if config.Security.Ban.ResetTime > 0 {
+			content += fmt.Sprintf("ban-reset-time = %d\n", config.Security.Ban.ResetTime)
+		}
+	}
+	// Блокировки /ban проверяются при подключении
+	if config.Security.Ban.List != "" {
+		content += fmt.Sprintf("connect-script = %s\n", BanScriptPath(config.Security.Ban.List))
+	}
+
+	// Ограничения
+	content += fmt.Sprintf("max-clients = %d\n", config.Limits.MaxClients)
+	content += fmt.Sprintf("max-same-clients = %d\n", config.Limits.MaxSameClients)
+	if config.Limits.RateLimitMs > 0 {
+		content += fmt.Sprintf("rate-limit-ms = %d\n", config.Limits.RateLimitMs)
+	}
+
+	// Сессии
+	if config.Session.Keepalive > 0 {
+		content += fmt.Sprintf("keepalive = %d\n", config.Session.Keepalive)
+	}
+	if config.Session.DPD > 0 {
+		content += fmt.Sprintf("dpd = %d\n", config.Session.DPD)
+	}
+	if config.Session.MobileDPD > 0 {
+		content += fmt.Sprintf("mobile-dpd = %d\n", config.Session.MobileDPD)
+	}
+	if config.Session.IdleTimeout > 0 {
+		content += fmt.Sprintf("idle-timeout = %d\n", config.Session.IdleTimeout)
+	}
+	if config.Session.MobileIdleTimeout > 0 {
+		content += fmt.Sprintf("mobile-idle-timeout = %d\n", config.Session.MobileIdleTimeout)
+	}
+
 	// Сетевые настройки
-	content += fmt.Sprintf("default-domain = \"%s\"\n", config.Server)
+	if len(config.Network.SearchDomains) > 0 {
+		content += fmt.Sprintf("default-domain = \"%s\"\n", strings.Join(config.Network.SearchDomains, " "))
+	} else {
+		content += fmt.Sprintf("default-domain = \"%s\"\n", config.Server)
+	}
 
 	content += fmt.Sprintf("ipv4-network = %s\n", config.Network.LAN)
 	content += fmt.Sprintf("ipv4-netmask = %s\n", config.Network.LANMask)
 
+	if config.Network.IPv6Network != "" && !config.Security.DisableIPv6 {
+		content += fmt.Sprintf("ipv6-network = %s\n", config.Network.IPv6Network)
+	}
+
 	content += fmt.Sprintf("mtu = %d\n", config.Network.MTU)
 
-	if len(config.Network.DNSServers) > 0 {
-		content += fmt.Sprintf("dns = %s\n",
-			strings.Join(config.Network.DNSServers, ", "))
+	for _, dns := range config.Network.DNSServers {
+		content += fmt.Sprintf("dns = %s\n", dns)
 	}
 
+	content += fmt.Sprintf("tunnel-all-dns = %t\n", config.Network.TunnelAllDNS)
+	content += fmt.Sprintf("compression = %t\n", config.Network.Compression)
+	content += fmt.Sprintf("cisco-client-compat = %t\n", config.CiscoClientCompat)
+
 	// Маршруты
-	for _, route := range config.Network.Routes {
-		content += fmt.Sprintf("route = %s\n", route)
+	if config.Network.DefaultRoute {
+		content += "route = default\n"
+	} else {
+		for _, route := range config.Network.Routes {
+			content += fmt.Sprintf("route = %s\n", route)
+		}
 	}
 
 	for _, exclude := range config.Network.ExcludeRoutes {
 		content += fmt.Sprintf("no-route = %s\n", exclude)
 	}
 
+	// Персональные маршруты: ocserv читает файл пользователя при каждом подключении
+	if config.Network.ConfigPerUser != "" {
+		content += fmt.Sprintf("config-per-user = %s/\n", strings.TrimSuffix(config.Network.ConfigPerUser, "/"))
+	}
+
+	// Политики групп: группа берется из поля group файла паролей
+	if config.Network.ConfigPerGroup != "" {
+		content += fmt.Sprintf("config-per-group = %s/\n", strings.TrimSuffix(config.Network.ConfigPerGroup, "/"))
+	}
+	if config.Network.DefaultGroupConfig != "" {
+		content += fmt.Sprintf("default-group-config = %s\n", config.Network.DefaultGroupConfig)
+	}
+
 	// Отладка
 	content += fmt.Sprintf("log-level = %d\n", config.Debug.Verbose)
 	if config.Debug.LogFile != "" {
@@ -107,7 +205,7 @@ func generateOCservConfig(config structures.OpenConnectConfig) string {
 }
 
 // CheckOCconfig проверяет существование и валидность конфигурации
-func CheckOCconfig(configPath string) (bool, error) {
+func CheckOCconfig(ocConfig structures.OpenConnectConfig, configPath string) (bool, error) {
 	// Проверяем существование файла
 	_, err := os.Stat(configPath)
 	if os.IsNotExist(err) {
@@ -119,35 +217,25 @@ func CheckOCconfig(configPath string) (bool, error) {
 	if err != nil {
 		return false, handlers.OpenConnectFileErrHandler(configPath, err)
 	}
-	content := string(data)
-
-	// Загружаем эталонную YAML конфигурацию
-	var ocConfig structures.OpenConnectConfig
-	err = config.LoadConfig("openconnect", []string{"/eidolon/service/config"}, &ocConfig)
+	actual, err := ParseOCconfig(string(data))
 	if err != nil {
 		return false, err
 	}
 
 	// Генерируем эталонный конфиг из загруженной конфигурации
-	expectedConfig := generateOCservConfig(ocConfig)
-
-	// Сравниваем каждую значимую строку
-	expectedLines := strings.Split(expectedConfig, "\n")
-	for _, line := range expectedLines {
-		if line == "" {
-			continue
-		}
+	expected, err := ParseOCconfig(generateOCservConfig(ocConfig))
+	if err != nil {
+		return false, err
+	}
 
-		// Проверяем наличие этой строки в текущем конфиге
-		if !strings.Contains(content, line) {
-			parts := strings.SplitN(line, "=", 2)
-			if len(parts) == 2 {
-				param := strings.TrimSpace(parts[0])
-				return false, errors.CallOpenConnectError(fmt.Sprintf("parameter %s has incorrect value in config", param), nil)
-			} else {
-				return false, errors.CallOpenConnectError(fmt.Sprintf("required line '%s' not found in config", line), nil)
-			}
+	// Сравниваем директивы и собираем все расхождения
+	mismatches := CompareOCconfig(expected, actual)
+	if len(mismatches) > 0 {
+		messages := make([]string, 0, len(mismatches))
+		for _, mismatch := range mismatches {
+			messages = append(messages, mismatch.String())
 		}
+		return false, errors.CallOpenConnectError(fmt.Sprintf("config mismatch: %s", strings.Join(messages, "; ")), nil)
 	}
 
 	return true, nil
@@ -180,14 +268,7 @@ func SearchOCconfig(searchPath string) (string, error) {
 }
 
 // Генерация ssl сертификата
-func GenerateSSLcert(path string) (string, string, error) {
-	// Загрузка конфигурации
-	var ocConfig structures.OpenConnectConfig
-	err := config.LoadConfig("openconnect", []string{"/eidolon/service/config"}, &ocConfig)
-	if err != nil {
-		return "", "", err
-	}
-
+func GenerateSSLcert(ocConfig structures.OpenConnectConfig, path string) (string, string, error) {
 	// Проверяем, существуют ли уже сертификаты
 	certPath := filepath.Join(path, ocConfig.Security.CACert)
 	keyPath := filepath.Join(path, ocConfig.Security.CAKey)
@@ -198,84 +279,11 @@ func GenerateSSLcert(path string) (string, string, error) {
 		}
 	}
 
-	// Создаем директорию, если она не существует
-	if err := os.MkdirAll(path, 0755); err != nil {
-		return "", "", err
-	}
-
-	// Генерируем приватный ключ RSA
-	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
-	if err != nil {
-		return "", "", err
-	}
-
-	// Сериализуем приватный ключ в PEM
-	keyOut, err := os.OpenFile(keyPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
-	if err != nil {
-		return "", "", err
-	}
-	defer keyOut.Close()
-
-	err = pem.Encode(keyOut, &pem.Block{
-		Type:  "RSA PRIVATE KEY",
-		Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
-	})
-	if err != nil {
-		return "", "", err
-	}
-
-	// Создаем шаблон сертификата
-	notBefore := time.Now()
-	notAfter := notBefore.Add(10 * 365 * 24 * time.Hour) // 10 лет
-
-	serialNumberLimit := new(big.Int).Lsh(big.NewInt(1), 128)
-	serialNumber, err := rand.Int(rand.Reader, serialNumberLimit)
-	if err != nil {
-		return "", "", err
-	}
-
-	template := x509.Certificate{
-		SerialNumber: serialNumber,
-		Subject: pkix.Name{
-			Organization: []string{ocConfig.Name},
-			CommonName:   ocConfig.Server,
-		},
-		NotBefore: notBefore,
-		NotAfter:  notAfter,
-
-		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
-		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
-		BasicConstraintsValid: true,
-		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
-		DNSNames:              []string{"localhost", ocConfig.Server},
-	}
-
 	// Самоподписанный сертификат (CA и серверный сертификат в одном)
-	derBytes, err := x509.CreateCertificate(rand.Reader, &template, &template, &privateKey.PublicKey, privateKey)
-	if err != nil {
-		return "", "", err
-	}
-
-	// Сериализуем сертификат в PEM
-	certOut, err := os.Create(certPath)
-	if err != nil {
-		return "", "", err
-	}
-	defer certOut.Close()
-
-	err = pem.Encode(certOut, &pem.Block{Type: "CERTIFICATE", Bytes: derBytes})
+	err := pki.SelfSignedIssuer{}.IssueServerCert(ocConfig, certPath, keyPath)
 	if err != nil {
 		return "", "", err
 	}
 
-	// Установка прав доступа
-	if err := os.Chmod(keyPath, 0600); err != nil {
-		return "", "", err
-	}
-
-	if err := os.Chmod(certPath, 0644); err != nil {
-		return "", "", err
-	}
-
 	return certPath, keyPath, nil
 }
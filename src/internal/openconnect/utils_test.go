@@ -0,0 +1,129 @@
+package openconnect
+
+import (
+	"eidolonVPN/internal/config/structures"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// go test ./internal/openconnect -run TestGenerateOCservConfig -update перезаписывает эталоны
+var update = flag.Bool("update", false, "rewrite testdata/*.golden")
+
+// baseConfig - минимальная конфигурация, от которой отталкиваются варианты
+func baseConfig() structures.OpenConnectConfig {
+	return structures.OpenConnectConfig{
+		Name:        "Eidolon VPN",
+		Server:      "vpn.example.com",
+		Port:        443,
+		Protocol:    "tcp",
+		Interface:   "eidolon0",
+		Socket:      "/run/ocserv.socket",
+		OcctlSocket: "/run/occtl.socket",
+		Security: structures.SecurityConfig{
+			Auth:           "plain[passwd=/etc/ocserv/passwd]",
+			CAPath:         "/certs/",
+			CACert:         "server-cert.pem",
+			CAKey:          "server-key.pem",
+			IsolateWorkers: true,
+		},
+		Network: structures.NetworkConfig{
+			MTU:     1400,
+			LAN:     "10.20.30.0",
+			LANMask: "255.255.255.0",
+		},
+		Limits: structures.LimitsConfig{MaxClients: 128, MaxSameClients: 2},
+		Debug:  structures.DebugConfig{Verbose: 1},
+	}
+}
+
+func TestGenerateOCservConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(c *structures.OpenConnectConfig)
+	}{
+		{"base", func(c *structures.OpenConnectConfig) {}},
+		{"udp_pam", func(c *structures.OpenConnectConfig) {
+			c.Protocol = "udp"
+			c.Security.Auth = "pam"
+		}},
+		{"cert_required", func(c *structures.OpenConnectConfig) {
+			c.Security.CertAuth = "required"
+			c.Security.ClientCA = "/certs/ca-cert.pem"
+			c.Security.CRL = "/certs/crl.pem"
+			c.Security.AllowedCiphers = []string{"AES256-SHA256", "AES128-SHA256"}
+		}},
+		{"cert_optional_no_check", func(c *structures.OpenConnectConfig) {
+			c.Security.CertAuth = "optional"
+			c.Security.ClientCA = "/certs/ca-cert.pem"
+			c.Security.CRL = "/certs/crl.pem"
+			c.Security.NoCertCheck = true
+		}},
+		{"ban", func(c *structures.OpenConnectConfig) {
+			c.Security.Ban = structures.BanConfig{MaxScore: 80, WrongPassword: 10, Connection: 1, ResetTime: 1200}
+			c.Limits.RateLimitMs = 100
+		}},
+		{"ipv6", func(c *structures.OpenConnectConfig) {
+			c.Network.IPv6Network = "fda9:4efe:7e3b:3ea::/48"
+			c.Network.DNSServers = []string{"2001:4860:4860::8888", "8.8.8.8"}
+		}},
+		{"ipv6_disabled", func(c *structures.OpenConnectConfig) {
+			c.Network.IPv6Network = "fda9:4efe:7e3b:3ea::/48"
+			c.Security.DisableIPv6 = true
+		}},
+		{"routes", func(c *structures.OpenConnectConfig) {
+			c.Network.Routes = []string{"10.20.30.0/24", "192.168.1.0/255.255.255.0"}
+			c.Network.ExcludeRoutes = []string{"127.0.0.0/8"}
+			c.Network.SearchDomains = []string{"internal.example.com", "corp.example.com"}
+			c.Network.TunnelAllDNS = true
+		}},
+		{"default_route", func(c *structures.OpenConnectConfig) {
+			c.Network.DefaultRoute = true
+			c.Network.Routes = []string{"10.20.30.0/24"}
+			c.Network.ExcludeRoutes = []string{"127.0.0.0/8"}
+		}},
+		{"config_per_user_group", func(c *structures.OpenConnectConfig) {
+			c.Network.ConfigPerUser = "/ocserv/config-per-user/"
+			c.Network.ConfigPerGroup = "/ocserv/config-per-group"
+			c.Network.DefaultGroupConfig = "/ocserv/default-group.conf"
+		}},
+		{"session", func(c *structures.OpenConnectConfig) {
+			c.Session = structures.SessionConfig{Keepalive: 32400, DPD: 90, MobileDPD: 1800, IdleTimeout: 1200, MobileIdleTimeout: 1800}
+			c.Network.Compression = true
+			c.CiscoClientCompat = true
+		}},
+		{"debug", func(c *structures.OpenConnectConfig) {
+			c.Debug = structures.DebugConfig{Verbose: 3, LogFile: "/data/logs/openconnect.log", Timestamp: true, NoHTTPS: true}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := baseConfig()
+			tt.modify(&cfg)
+			got := generateOCservConfig(cfg)
+
+			golden := filepath.Join("testdata", tt.name+".golden")
+			if *update {
+				err := os.WriteFile(golden, []byte(got), 0644)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("missing golden file, run with -update: %v", err)
+			}
+			if got != string(want) {
+				t.Errorf("generated config differs from %s:\n--- got\n%s\n--- want\n%s", golden, got, want)
+			}
+
+			// Сгенерированный конфиг должен разбираться собственным парсером
+			_, err = ParseOCconfig(got)
+			if err != nil {
+				t.Errorf("generated config does not parse: %v", err)
+			}
+		})
+	}
+}
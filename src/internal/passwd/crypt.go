@@ -0,0 +1,172 @@
+package passwd
+
+import (
+	"crypto/rand"
+	"crypto/sha512"
+	"crypto/subtle"
+	"eidolonVPN/internal/errors"
+	"strconv"
+	"strings"
+)
+
+// Параметры SHA-512 crypt ($6$), как у glibc crypt(3) и ocpasswd
+const (
+	sha512Prefix  = "$6$"
+	roundsPrefix  = "rounds="
+	defaultRounds = 5000
+	minRounds     = 1000
+	maxRounds     = 999999999
+	saltLength    = 16
+	cryptAlphabet = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+)
+
+// HashPassword возвращает SHA-512 crypt хэш пароля со случайной солью
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, saltLength)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return "", errors.CallPasswdError("Failed to generate salt", err)
+	}
+	for i := range salt {
+		salt[i] = cryptAlphabet[int(salt[i])%len(cryptAlphabet)]
+	}
+	return sha512Crypt([]byte(password), salt, defaultRounds, false), nil
+}
+
+// VerifyPassword проверяет пароль по SHA-512 crypt хэшу
+func VerifyPassword(hash, password string) bool {
+	if !strings.HasPrefix(hash, sha512Prefix) {
+		return false
+	}
+	settings := strings.TrimPrefix(hash, sha512Prefix)
+
+	rounds, custom := defaultRounds, false
+	if strings.HasPrefix(settings, roundsPrefix) {
+		value, rest, ok := strings.Cut(strings.TrimPrefix(settings, roundsPrefix), "$")
+		if !ok {
+			return false
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return false
+		}
+		rounds, custom, settings = n, true, rest
+	}
+
+	salt, _, ok := strings.Cut(settings, "$")
+	if !ok {
+		return false
+	}
+	expected := sha512Crypt([]byte(password), []byte(salt), rounds, custom)
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(hash)) == 1
+}
+
+// sha512Crypt реализует алгоритм SHA-crypt (Ulrich Drepper) для SHA-512
+func sha512Crypt(password, salt []byte, rounds int, customRounds bool) string {
+	if len(salt) > saltLength {
+		salt = salt[:saltLength]
+	}
+	if rounds < minRounds {
+		rounds = minRounds
+	}
+	if rounds > maxRounds {
+		rounds = maxRounds
+	}
+
+	// Дайджест B: пароль, соль, пароль
+	h := sha512.New()
+	h.Write(password)
+	h.Write(salt)
+	h.Write(password)
+	altSum := h.Sum(nil)
+
+	// Дайджест A
+	h = sha512.New()
+	h.Write(password)
+	h.Write(salt)
+	for n := len(password); n > 0; n -= sha512.Size {
+		h.Write(altSum[:min(n, sha512.Size)])
+	}
+	for n := len(password); n > 0; n >>= 1 {
+		if n&1 != 0 {
+			h.Write(altSum)
+		} else {
+			h.Write(password)
+		}
+	}
+	sum := h.Sum(nil)
+
+	// Последовательность P
+	h = sha512.New()
+	for range len(password) {
+		h.Write(password)
+	}
+	pBytes := repeatTo(h.Sum(nil), len(password))
+
+	// Последовательность S
+	h = sha512.New()
+	for range 16 + int(sum[0]) {
+		h.Write(salt)
+	}
+	sBytes := repeatTo(h.Sum(nil), len(salt))
+
+	// Основной цикл растяжения
+	for i := range rounds {
+		h = sha512.New()
+		if i&1 != 0 {
+			h.Write(pBytes)
+		} else {
+			h.Write(sum)
+		}
+		if i%3 != 0 {
+			h.Write(sBytes)
+		}
+		if i%7 != 0 {
+			h.Write(pBytes)
+		}
+		if i&1 != 0 {
+			h.Write(sum)
+		} else {
+			h.Write(pBytes)
+		}
+		sum = h.Sum(nil)
+	}
+
+	var out strings.Builder
+	out.WriteString(sha512Prefix)
+	if customRounds {
+		out.WriteString(roundsPrefix + strconv.Itoa(rounds) + "$")
+	}
+	out.Write(salt)
+	out.WriteByte('$')
+
+	// Перестановка байтов SHA-512 crypt при кодировании
+	order := [...][3]int{
+		{0, 21, 42}, {22, 43, 1}, {44, 2, 23}, {3, 24, 45}, {25, 46, 4}, {47, 5, 26}, {6, 27, 48},
+		{28, 49, 7}, {50, 8, 29}, {9, 30, 51}, {31, 52, 10}, {53, 11, 32}, {12, 33, 54}, {34, 55, 13},
+		{56, 14, 35}, {15, 36, 57}, {37, 58, 16}, {59, 17, 38}, {18, 39, 60}, {40, 61, 19}, {62, 20, 41},
+	}
+	for _, o := range order {
+		encode24(&out, sum[o[0]], sum[o[1]], sum[o[2]], 4)
+	}
+	encode24(&out, 0, 0, sum[63], 2)
+	return out.String()
+}
+
+// repeatTo повторяет дайджест до нужной длины
+func repeatTo(digest []byte, length int) []byte {
+	out := make([]byte, 0, length)
+	for len(out) < length {
+		out = append(out, digest[:min(length-len(out), len(digest))]...)
+	}
+	return out
+}
+
+// encode24 кодирует 24 бита алфавитом crypt
+func encode24(out *strings.Builder, b2, b1, b0 byte, n int) {
+	w := uint(b2)<<16 | uint(b1)<<8 | uint(b0)
+	for range n {
+		out.WriteByte(cryptAlphabet[w&0x3f])
+		w >>= 6
+	}
+}
@@ -0,0 +1,63 @@
+package passwd
+
+import "testing"
+
+// Контрольные значения из спецификации SHA-crypt (Ulrich Drepper)
+func TestSHA512CryptVectors(t *testing.T) {
+	tests := []struct {
+		salt     string
+		rounds   int // 0 - по умолчанию, без rounds= в хэше
+		password string
+		want     string
+	}{
+		{"saltstring", 0, "Hello world!",
+			"$6$saltstring$svn8UoSVapNtMuq1ukKS4tPQd8iKwSMHWjl/O817G3uBnIFNjnQJuesI68u4OTLiBFdcbYEdFCoEOfaS35inz1"},
+		{"saltstringsaltstring", 10000, "Hello world!",
+			"$6$rounds=10000$saltstringsaltst$OW1/O6BYHV6BcXZu8QVeXbDWra3Oeqh0sbHbbMCVNSnCM/UrjmM0Dp8vOuZeHBy/YTBmSK6H9qs/y3RnOaw5v."},
+		{"toolongsaltstring", 5000, "This is just a test",
+			"$6$rounds=5000$toolongsaltstrin$lQ8jolhgVRVhY4b5pZKaysCLi0QBxGoNeKQzQ3glMhwllF7oGDZxUhx1yxdYcz/e1JSbq3y6JMxxl8audkUEm0"},
+		{"anotherlongsaltstring", 1400, "a very much longer text to encrypt.  This one even stretches over morethan one line.",
+			"$6$rounds=1400$anotherlongsalts$POfYwTEok97VWcjxIiSOjiykti.o/pQs.wPvMxQ6Fm7I6IoYN3CmLs66x9t0oSwbtEW7o7UmJEiDwGqd8p4ur1"},
+		{"short", 77777, "we have a short salt string but not a short password",
+			"$6$rounds=77777$short$WuQyW2YR.hBNpjjRhpYD/ifIw05xdfeEyQoMxIXbkvr0gge1a1x3yRULJ5CCaUeOxFmtlcGZelFl5CxtgfiAc0"},
+		{"asaltof16chars..", 123456, "a short string",
+			"$6$rounds=123456$asaltof16chars..$BtCwjqMJGx5hrJhZywWvt0RLE8uZ4oPwcelCjmw2kSYu.Ec6ycULevoBK25fs2xXgMNrCzIMVcgEJAstJeonj1"},
+		// rounds ниже минимума поднимается до 1000
+		{"roundstoolow", 10, "the minimum number is still observed",
+			"$6$rounds=1000$roundstoolow$kUMsbe306n21p9R.FRkW3IGn.S9NPN0x50YhH1xhLsPuWGsUSklZt58jaTfF4ZEQpyUNGc0dqbpBYYBaHHrsX."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.salt, func(t *testing.T) {
+			rounds := tt.rounds
+			if rounds == 0 {
+				rounds = defaultRounds
+			}
+			if got := sha512Crypt([]byte(tt.password), []byte(tt.salt), rounds, tt.rounds != 0); got != tt.want {
+				t.Errorf("sha512Crypt = %s\nwant %s", got, tt.want)
+			}
+			if !VerifyPassword(tt.want, tt.password) {
+				t.Errorf("VerifyPassword(%s) = false", tt.want)
+			}
+			if VerifyPassword(tt.want, tt.password+"x") {
+				t.Error("wrong password accepted")
+			}
+		})
+	}
+}
+
+func TestHashPassword(t *testing.T) {
+	hash, err := HashPassword("s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hash) != len("$6$")+saltLength+1+86 {
+		t.Errorf("unexpected hash length: %s", hash)
+	}
+	if !VerifyPassword(hash, "s3cret") || VerifyPassword(hash, "S3cret") {
+		t.Errorf("hash %s does not verify", hash)
+	}
+	if other, _ := HashPassword("s3cret"); other == hash {
+		t.Error("two hashes share a salt")
+	}
+}
@@ -0,0 +1,182 @@
+package passwd
+
+import (
+	"bufio"
+	"bytes"
+	"eidolonVPN/internal/errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Формат ocpasswd: user:group:hash
+const (
+	noGroup      = "*" // Пользователь без группы
+	lockedPrefix = "!" // ocpasswd -l блокирует пользователя, добавляя ! к хэшу
+)
+
+// Имя и группа не могут содержать разделители полей и групп
+var fieldPattern = regexp.MustCompile(`^[^:,\s]+$`)
+
+// Entry - строка файла паролей ocserv
+type Entry struct {
+	Username string
+	Groups   []string // Пусто - без группы
+	Hash     string   // SHA-512 crypt без префикса блокировки
+	Locked   bool
+}
+
+// String возвращает строку в формате ocpasswd
+func (e Entry) String() string {
+	group := noGroup
+	if len(e.Groups) > 0 {
+		group = strings.Join(e.Groups, ",")
+	}
+	hash := e.Hash
+	if e.Locked {
+		hash = lockedPrefix + hash
+	}
+	return e.Username + ":" + group + ":" + hash
+}
+
+// File - файл паролей ocserv (auth = "plain[passwd=...]").
+// Записывает файл только Syncer: пароли, блокировки и группы меняются в базе
+type File struct {
+	path  string
+	mutex sync.Mutex
+}
+
+// NewFile создает обертку над файлом паролей
+func NewFile(path string) *File {
+	return &File{path: path}
+}
+
+// PathFromAuth извлекает путь к файлу паролей из директивы auth ocserv
+func PathFromAuth(auth string) (string, bool) {
+	if !strings.HasPrefix(auth, "plain[") || !strings.HasSuffix(auth, "]") {
+		return "", false
+	}
+	options := strings.TrimSuffix(strings.TrimPrefix(auth, "plain["), "]")
+	for _, option := range strings.Split(options, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(option), "=")
+		if ok && key == "passwd" && value != "" {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// Path возвращает путь к файлу
+func (f *File) Path() string {
+	return f.path
+}
+
+// Load читает все записи; отсутствующий файл - пустой список
+func (f *File) Load() ([]Entry, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.read()
+}
+
+// read разбирает файл
+func (f *File) read() ([]Entry, error) {
+	data, err := os.ReadFile(f.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.CallPasswdError(fmt.Sprintf("Failed to read %s", f.path), err)
+	}
+	return Parse(data)
+}
+
+// write атомарно записывает файл: ocserv читает его при каждой аутентификации
+func (f *File) write(entries []Entry) error {
+	dir := filepath.Dir(f.path)
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return errors.CallPasswdError(fmt.Sprintf("Failed to create %s", dir), err)
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(f.path)+"-*")
+	if err != nil {
+		return errors.CallPasswdError(fmt.Sprintf("Failed to create temp file in %s", dir), err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(Format(entries))
+	if err == nil {
+		err = tmp.Chmod(0600)
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	closeErr := tmp.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return errors.CallPasswdError(fmt.Sprintf("Failed to write %s", tmp.Name()), err)
+	}
+
+	err = os.Rename(tmp.Name(), f.path)
+	if err != nil {
+		return errors.CallPasswdError(fmt.Sprintf("Failed to replace %s", f.path), err)
+	}
+	return nil
+}
+
+// Parse разбирает содержимое файла в формате ocpasswd
+func Parse(data []byte) ([]Entry, error) {
+	var entries []Entry
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 {
+			return nil, errors.CallPasswdError(fmt.Sprintf("Line %d: expected user:group:hash", n), nil)
+		}
+
+		entry := Entry{Username: parts[0], Hash: parts[2]}
+		if parts[1] != noGroup && parts[1] != "" {
+			entry.Groups = strings.Split(parts[1], ",")
+		}
+		if strings.HasPrefix(entry.Hash, lockedPrefix) {
+			entry.Locked = true
+			entry.Hash = strings.TrimPrefix(entry.Hash, lockedPrefix)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.CallPasswdError("Failed to parse passwd file", err)
+	}
+	return entries, nil
+}
+
+// Format сериализует записи в формат ocpasswd
+func Format(entries []Entry) []byte {
+	var buf bytes.Buffer
+	for _, e := range entries {
+		buf.WriteString(e.String())
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+// validateFields проверяет, что имя и группы не ломают формат файла
+func validateFields(fields ...string) error {
+	for _, field := range fields {
+		if !fieldPattern.MatchString(field) {
+			return errors.CallPasswdError(fmt.Sprintf("Invalid passwd field %q", field), nil)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package passwd
+
+import (
+	"bytes"
+	"context"
+	"eidolonVPN/internal/errors"
+	"eidolonVPN/internal/storage"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Как часто сверять файл с базой на случай изменений в обход Sync
+const syncInterval = time.Minute
+
+// Syncer поддерживает файл паролей в соответствии с пользователями из базы.
+// Файл целиком принадлежит eidolon: записи, которых нет в базе, удаляются.
+// При первой синхронизации с базой без паролей записи файла переносятся в базу
+type Syncer struct {
+	db       *storage.DB
+	file     *File
+	mutex    sync.Mutex
+	onChange func()
+	imported bool // Первая синхронизация уже проверила файл на импорт
+}
+
+// NewSyncer создает синхронизатор файла паролей
+func NewSyncer(db *storage.DB, file *File) *Syncer {
+	return &Syncer{db: db, file: file}
+}
+
+// OnChange устанавливает обработчик изменения файла, например SIGHUP для ocserv
+func (s *Syncer) OnChange(handler func()) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.onChange = handler
+}
+
+// File возвращает управляемый файл паролей
+func (s *Syncer) File() *File {
+	return s.file
+}
+
+// Sync перезаписывает файл, если он расходится с базой. Возвращает true при изменении
+func (s *Syncer) Sync(ctx context.Context) (bool, error) {
+	s.mutex.Lock()
+	first := !s.imported
+	s.mutex.Unlock()
+	if first {
+		_, err := s.Import(ctx)
+		if err != nil {
+			return false, err
+		}
+		s.mutex.Lock()
+		s.imported = true
+		s.mutex.Unlock()
+	}
+
+	users, err := s.db.Users().List(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	var entries []Entry
+	for _, user := range users {
+		// Пользователи только с сертификатом в файл паролей не попадают
+		if user.PasswordHash == "" {
+			continue
+		}
+		err = validateFields(user.Username)
+		if err == nil && user.Group != "" {
+			err = validateFields(user.Group)
+		}
+		if err != nil {
+			fmt.Printf("Skipping user %s in passwd file: %v\n", user.Username, err)
+			continue
+		}
+		entry := Entry{
+			Username: user.Username,
+			Hash:     user.PasswordHash,
+			Locked:   user.Status != storage.UserActive,
+		}
+		if user.Group != "" {
+			entry.Groups = []string{user.Group}
+		}
+		entries = append(entries, entry)
+	}
+
+	s.file.mutex.Lock()
+	current, err := os.ReadFile(s.file.path)
+	if err != nil && !os.IsNotExist(err) {
+		s.file.mutex.Unlock()
+		return false, errors.CallPasswdError(fmt.Sprintf("Failed to read %s", s.file.path), err)
+	}
+	if err == nil && bytes.Equal(current, Format(entries)) {
+		s.file.mutex.Unlock()
+		return false, nil
+	}
+	err = s.file.write(entries)
+	s.file.mutex.Unlock()
+	if err != nil {
+		return false, err
+	}
+
+	s.mutex.Lock()
+	handler := s.onChange
+	s.mutex.Unlock()
+	if handler != nil {
+		handler()
+	}
+	return true, nil
+}
+
+// Import переносит записи файла паролей в базу, если в ней еще нет пользователей с паролем,
+// например при переходе на eidolon с ocpasswd. Иначе Sync удалил бы эти записи.
+// В базе у пользователя одна группа, из нескольких групп записи берется первая
+func (s *Syncer) Import(ctx context.Context) (int, error) {
+	entries, err := s.file.Load()
+	if err != nil || len(entries) == 0 {
+		return 0, err
+	}
+
+	imported := 0
+	err = s.db.InTx(ctx, func(tx *storage.Tx) error {
+		users, err := tx.Users().List(ctx)
+		if err != nil {
+			return err
+		}
+		existing := make(map[string]*storage.User, len(users))
+		for i := range users {
+			if users[i].PasswordHash != "" {
+				return nil // База уже ведет пароли
+			}
+			existing[users[i].Username] = &users[i]
+		}
+
+		for _, entry := range entries {
+			status := storage.UserActive
+			if entry.Locked {
+				status = storage.UserLocked
+			}
+			user := existing[entry.Username]
+			if user == nil {
+				user = &storage.User{Username: entry.Username, Status: status}
+			} else if user.Status == storage.UserActive {
+				user.Status = status
+			}
+			user.PasswordHash = entry.Hash
+
+			if len(entry.Groups) > 0 {
+				group, err := importGroup(ctx, tx, entry.Groups[0])
+				if err != nil {
+					return err
+				}
+				user.GroupID = &group.ID
+			}
+
+			if user.ID == 0 {
+				err = tx.Users().Create(ctx, user)
+			} else {
+				err = tx.Users().Update(ctx, user)
+			}
+			if err != nil {
+				return err
+			}
+			err = tx.Audit().Record(ctx, &storage.AuditEvent{
+				Actor:  "system",
+				Action: "passwd.import",
+				Target: user.Username,
+			})
+			if err != nil {
+				return err
+			}
+			imported++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, errors.CallPasswdError(fmt.Sprintf("Failed to import %s", s.file.path), err)
+	}
+	if imported > 0 {
+		fmt.Printf("Imported %d users from %s\n", imported, s.file.path)
+	}
+	return imported, nil
+}
+
+// importGroup возвращает группу по имени, создавая ее при отсутствии
+func importGroup(ctx context.Context, tx *storage.Tx, name string) (*storage.Group, error) {
+	group, err := tx.Groups().GetByName(ctx, name)
+	if err != storage.ErrNotFound {
+		return group, err
+	}
+	group = &storage.Group{Name: name}
+	err = tx.Groups().Create(ctx, group)
+	if err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+// Run сверяет файл с базой сразу и затем периодически до отмены контекста
+func (s *Syncer) Run(ctx context.Context) {
+	for {
+		_, err := s.Sync(ctx)
+		if err != nil {
+			fmt.Printf("Passwd sync failed: %v\n", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(syncInterval):
+		}
+	}
+}
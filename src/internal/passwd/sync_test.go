@@ -0,0 +1,167 @@
+package passwd
+
+import (
+	"context"
+	"eidolonVPN/internal/storage"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Записи, оставленные ocpasswd до перехода на eidolon
+const legacyPasswd = `alice:staff:$6$salt$alicehash
+bob:*:!$6$salt$bobhash
+carol:ops,staff:$6$salt$carolhash
+`
+
+func newTestSyncer(t *testing.T, content string) (*Syncer, *storage.DB, string) {
+	t.Helper()
+	dir := t.TempDir()
+
+	db, err := storage.Open(filepath.Join(dir, "eidolon.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	path := filepath.Join(dir, "ocpasswd")
+	if content != "" {
+		err = os.WriteFile(path, []byte(content), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return NewSyncer(db, NewFile(path)), db, path
+}
+
+func TestSyncImportsExistingFile(t *testing.T) {
+	syncer, db, path := newTestSyncer(t, legacyPasswd)
+	ctx := context.Background()
+
+	changed, err := syncer.Sync(ctx)
+	if err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	// Записи сохранились; у carol в базе осталась только первая группа
+	if !changed {
+		t.Error("Sync did not reduce carol to a single group")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := strings.Replace(legacyPasswd, "ops,staff", "ops", 1); string(data) != want {
+		t.Errorf("passwd file after import:\n%s\nwant:\n%s", data, want)
+	}
+
+	alice, err := db.Users().GetByUsername(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alice.PasswordHash != "$6$salt$alicehash" || alice.Group != "staff" || alice.Status != storage.UserActive {
+		t.Errorf("alice: %+v", alice)
+	}
+	bob, err := db.Users().GetByUsername(ctx, "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bob.Status != storage.UserLocked || bob.GroupID != nil || bob.PasswordHash != "$6$salt$bobhash" {
+		t.Errorf("bob: %+v", bob)
+	}
+	carol, err := db.Users().GetByUsername(ctx, "carol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if carol.Group != "ops" {
+		t.Errorf("carol group = %q, want first group ops", carol.Group)
+	}
+}
+
+func TestSyncSkipsImportWithManagedPasswords(t *testing.T) {
+	syncer, db, path := newTestSyncer(t, "")
+	ctx := context.Background()
+
+	// База уже ведет пароли: посторонняя запись удаляется, а не импортируется
+	hash, err := HashPassword("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Users().Create(ctx, &storage.User{Username: "alice", PasswordHash: hash})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(path, []byte("mallory:*:$6$salt$malloryhash\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changed, err := syncer.Sync(ctx)
+	if err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if !changed {
+		t.Error("Sync kept an unmanaged entry")
+	}
+	if _, err := db.Users().GetByUsername(ctx, "mallory"); err != storage.ErrNotFound {
+		t.Errorf("unmanaged entry imported into non-empty database: %v", err)
+	}
+
+	entries, err := syncer.File().Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Username != "alice" || !VerifyPassword(entries[0].Hash, "secret") {
+		t.Errorf("passwd entries: %+v", entries)
+	}
+}
+
+func TestSyncFollowsDatabase(t *testing.T) {
+	syncer, db, _ := newTestSyncer(t, "")
+	ctx := context.Background()
+
+	hash, err := HashPassword("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := &storage.User{Username: "alice", PasswordHash: hash}
+	if err := db.Users().Create(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	// Пользователь только с сертификатом и имя, ломающее формат файла, пропускаются
+	if err := db.Users().Create(ctx, &storage.User{Username: "certonly"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Users().Create(ctx, &storage.User{Username: "bad:name", PasswordHash: hash}); err != nil {
+		t.Fatal(err)
+	}
+
+	notified := 0
+	syncer.OnChange(func() { notified++ })
+	if _, err := syncer.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Users().SetStatus(ctx, user.ID, storage.UserLocked); err != nil {
+		t.Fatal(err)
+	}
+	changed, err := syncer.Sync(ctx)
+	if err != nil || !changed {
+		t.Fatalf("Sync after lock: changed=%v err=%v", changed, err)
+	}
+	changed, err = syncer.Sync(ctx)
+	if err != nil || changed {
+		t.Errorf("repeated Sync: changed=%v err=%v", changed, err)
+	}
+	if notified != 2 {
+		t.Errorf("OnChange called %d times, want 2", notified)
+	}
+
+	entries, err := syncer.File().Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Username != "alice" || !entries[0].Locked {
+		t.Errorf("passwd entries: %+v", entries)
+	}
+}
@@ -0,0 +1,311 @@
+package pki
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"eidolonVPN/internal/config/structures"
+	"eidolonVPN/internal/errors"
+	"eidolonVPN/internal/errors/handlers"
+	stderrors "errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"golang.org/x/crypto/acme"
+)
+
+// Типы ACME проверок
+const (
+	ChallengeHTTP01    = "http-01"
+	ChallengeTLSALPN01 = "tls-alpn-01"
+)
+
+// Время на получение сертификата целиком
+const acmeTimeout = 5 * time.Minute
+
+// ACMEIssuer получает серверный сертификат у ACME CA (Let's Encrypt, Pebble и т.п.)
+type ACMEIssuer struct {
+	config structures.ACMEConfig
+}
+
+// NewACMEIssuer создает эмитента по настройкам ACME
+func NewACMEIssuer(cfg structures.ACMEConfig) *ACMEIssuer {
+	return &ACMEIssuer{config: cfg}
+}
+
+// RequiresTrusted сообщает, что самоподписанный сертификат нужно заменить
+func (i *ACMEIssuer) RequiresTrusted() bool {
+	return true
+}
+
+// IssueServerCert проходит ACME проверку для OpenConnectConfig.Server и сохраняет цепочку сертификатов
+func (i *ACMEIssuer) IssueServerCert(ocConfig structures.OpenConnectConfig, certPath, keyPath string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), acmeTimeout)
+	defer cancel()
+
+	domain := ocConfig.Server
+	if domain == "" || net.ParseIP(domain) != nil {
+		return errors.CallPKIError(fmt.Sprintf("ACME requires a DNS name, got %q", domain), nil)
+	}
+
+	client, err := i.client(ctx)
+	if err != nil {
+		return err
+	}
+
+	order, err := client.AuthorizeOrder(ctx, acme.DomainIDs(domain))
+	if err != nil {
+		return errors.CallPKIError("ACME order failed", err)
+	}
+
+	for _, authzURL := range order.AuthzURLs {
+		err = i.authorize(ctx, client, authzURL, domain)
+		if err != nil {
+			return err
+		}
+	}
+
+	order, err = client.WaitOrder(ctx, order.URI)
+	if err != nil {
+		return errors.CallPKIError("ACME order was not ready", err)
+	}
+
+	// Ключ сервера и CSR
+	key, err := generateKey(ocConfig.Security.ServerKey)
+	if err != nil {
+		return err
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{DNSNames: []string{domain}}, key)
+	if err != nil {
+		return errors.CallPKIError("Failed to create CSR", err)
+	}
+
+	chain, _, err := client.CreateOrderCert(ctx, order.FinalizeURL, csr, true)
+	if err != nil {
+		return errors.CallPKIError("ACME finalize failed", err)
+	}
+	if len(chain) == 0 {
+		return errors.CallPKIError("ACME returned empty certificate chain", nil)
+	}
+
+	// Записываем ключ и всю цепочку: ocserv отдает ее клиентам
+	keyPEM, err := encodeKey(key)
+	if err != nil {
+		return err
+	}
+	var certPEM []byte
+	for _, der := range chain {
+		certPEM = append(certPEM, encodeCert(der)...)
+	}
+
+	err = writeFile(keyPath, keyPEM, 0600)
+	if err != nil {
+		return err
+	}
+	return writeFile(certPath, certPEM, 0644)
+}
+
+// client создает ACME клиента и регистрирует аккаунт
+func (i *ACMEIssuer) client(ctx context.Context) (*acme.Client, error) {
+	accountKey, err := loadOrCreateAccountKey(i.config.AccountKey)
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient := http.DefaultClient
+	if i.config.DirectoryCA != "" {
+		// Для локального тестового CA (Pebble) доверяем его корню
+		data, err := os.ReadFile(i.config.DirectoryCA)
+		if err != nil {
+			return nil, handlers.PKIFileErrHandler(i.config.DirectoryCA, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, errors.CallPKIError(fmt.Sprintf("No certificates in %s", i.config.DirectoryCA), nil)
+		}
+		httpClient = &http.Client{
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}},
+			Timeout:   30 * time.Second,
+		}
+	}
+
+	directory := i.config.DirectoryURL
+	if directory == "" {
+		directory = acme.LetsEncryptURL
+	}
+
+	client := &acme.Client{
+		Key:          accountKey,
+		DirectoryURL: directory,
+		HTTPClient:   httpClient,
+	}
+
+	account := &acme.Account{}
+	if i.config.Email != "" {
+		account.Contact = []string{"mailto:" + i.config.Email}
+	}
+	_, err = client.Register(ctx, account, acme.AcceptTOS)
+	if err != nil && !stderrors.Is(err, acme.ErrAccountAlreadyExists) {
+		return nil, errors.CallPKIError(fmt.Sprintf("ACME registration at %s failed", directory), err)
+	}
+
+	return client, nil
+}
+
+// authorize проходит проверку владения доменом
+func (i *ACMEIssuer) authorize(ctx context.Context, client *acme.Client, authzURL, domain string) error {
+	authz, err := client.GetAuthorization(ctx, authzURL)
+	if err != nil {
+		return errors.CallPKIError("Failed to get ACME authorization", err)
+	}
+	if authz.Status == acme.StatusValid {
+		return nil
+	}
+
+	challengeType := i.config.Challenge
+	if challengeType == "" {
+		challengeType = ChallengeHTTP01
+	}
+
+	var challenge *acme.Challenge
+	for _, c := range authz.Challenges {
+		if c.Type == challengeType {
+			challenge = c
+			break
+		}
+	}
+	if challenge == nil {
+		return errors.CallPKIError(fmt.Sprintf("ACME server does not offer %s for %s", challengeType, domain), nil)
+	}
+
+	// Поднимаем временный сервер для ответа на проверку
+	var stop func()
+	switch challengeType {
+	case ChallengeHTTP01:
+		stop, err = i.serveHTTP01(client, challenge.Token)
+	case ChallengeTLSALPN01:
+		stop, err = i.serveTLSALPN01(client, challenge.Token, domain)
+	default:
+		err = errors.CallPKIError(fmt.Sprintf("Unsupported challenge %s", challengeType), nil)
+	}
+	if err != nil {
+		return err
+	}
+	defer stop()
+
+	_, err = client.Accept(ctx, challenge)
+	if err != nil {
+		return errors.CallPKIError("ACME challenge was not accepted", err)
+	}
+	_, err = client.WaitAuthorization(ctx, authz.URI)
+	if err != nil {
+		return errors.CallPKIError(fmt.Sprintf("ACME authorization for %s failed", domain), err)
+	}
+	return nil
+}
+
+// serveHTTP01 отвечает на http-01 проверку
+func (i *ACMEIssuer) serveHTTP01(client *acme.Client, token string) (func(), error) {
+	response, err := client.HTTP01ChallengeResponse(token)
+	if err != nil {
+		return nil, errors.CallPKIError("Failed to build http-01 response", err)
+	}
+	path := client.HTTP01ChallengePath(token)
+
+	listener, err := net.Listen("tcp", i.listenAddr(":80"))
+	if err != nil {
+		return nil, errors.CallPKIError("Failed to listen for http-01 challenge", err)
+	}
+
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != path {
+				http.NotFound(w, r)
+				return
+			}
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte(response))
+		}),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go server.Serve(listener)
+
+	return func() { server.Close() }, nil
+}
+
+// serveTLSALPN01 отвечает на tls-alpn-01 проверку. Порт 443 занят ocserv, поэтому
+// валидация конфига требует listen_addr, на который пробрасывается внешний 443
+func (i *ACMEIssuer) serveTLSALPN01(client *acme.Client, token, domain string) (func(), error) {
+	cert, err := client.TLSALPN01ChallengeCert(token, domain)
+	if err != nil {
+		return nil, errors.CallPKIError("Failed to build tls-alpn-01 certificate", err)
+	}
+
+	listener, err := tls.Listen("tcp", i.listenAddr(":443"), &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		NextProtos:   []string{acme.ALPNProto},
+	})
+	if err != nil {
+		return nil, errors.CallPKIError("Failed to listen for tls-alpn-01 challenge", err)
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				conn.SetDeadline(time.Now().Add(10 * time.Second))
+				conn.(*tls.Conn).Handshake()
+			}()
+		}
+	}()
+
+	return func() { listener.Close() }, nil
+}
+
+// listenAddr возвращает адрес для сервера проверки
+func (i *ACMEIssuer) listenAddr(fallback string) string {
+	if i.config.ListenAddr != "" {
+		return i.config.ListenAddr
+	}
+	return fallback
+}
+
+// loadOrCreateAccountKey загружает ключ ACME аккаунта или создает новый
+func loadOrCreateAccountKey(path string) (crypto.Signer, error) {
+	if path == "" {
+		return nil, errors.CallPKIError("ACME account key path is not set", nil)
+	}
+
+	key, err := readKey(path)
+	if err == nil {
+		return key, nil
+	}
+	if _, statErr := os.Stat(path); !os.IsNotExist(statErr) {
+		return nil, err
+	}
+
+	// Ключа нет - создаем новый
+	key, err = generateKey(structures.KeyConfig{Type: KeyECDSA, Size: 256})
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, err := encodeKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	err = writeFile(path, keyPEM, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
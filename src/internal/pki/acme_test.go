@@ -0,0 +1,322 @@
+package pki
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"eidolonVPN/internal/config/structures"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stubACME - минимальный ACME сервер (RFC 8555) для офлайн проверки выпуска.
+// Подписи JWS не проверяются; http-01 проверка выполняется настоящим запросом к эмитенту
+type stubACME struct {
+	t          *testing.T
+	server     *httptest.Server
+	domain     string
+	listenAddr string // Адрес сервера http-01 проверки eidolon
+
+	issuer    *x509.Certificate
+	issuerKey *ecdsa.PrivateKey
+
+	mutex      sync.Mutex
+	registered bool
+	authorized bool
+	chain      []byte
+	requests   []string
+}
+
+func newStubACME(t *testing.T, domain, listenAddr string) *stubACME {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Stub ACME CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &stubACME{t: t, domain: domain, listenAddr: listenAddr, issuer: issuer, issuerKey: key}
+	s.server = httptest.NewTLSServer(http.HandlerFunc(s.handle))
+	t.Cleanup(s.server.Close)
+	return s
+}
+
+// caFile сохраняет корень TLS стаба для directory_ca
+func (s *stubACME) caFile() string {
+	path := filepath.Join(s.t.TempDir(), "stub-ca.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.server.Certificate().Raw})
+	err := os.WriteFile(path, data, 0644)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return path
+}
+
+func (s *stubACME) handle(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+	w.Header().Set("Replay-Nonce", "nonce")
+	url := s.server.URL
+
+	switch r.URL.Path {
+	case "/directory":
+		s.reply(w, http.StatusOK, map[string]any{
+			"newNonce":   url + "/nonce",
+			"newAccount": url + "/account",
+			"newOrder":   url + "/order",
+			"revokeCert": url + "/revoke",
+			"keyChange":  url + "/key-change",
+		})
+	case "/nonce":
+		w.WriteHeader(http.StatusOK)
+	case "/account":
+		s.registered = true
+		w.Header().Set("Location", url+"/account/1")
+		s.reply(w, http.StatusCreated, map[string]any{"status": "valid"})
+	case "/order":
+		w.Header().Set("Location", url+"/order/1")
+		s.reply(w, http.StatusCreated, s.order())
+	case "/order/1":
+		s.reply(w, http.StatusOK, s.order())
+	case "/authz/1":
+		status := "pending"
+		if s.authorized {
+			status = "valid"
+		}
+		s.reply(w, http.StatusOK, map[string]any{
+			"status":     status,
+			"identifier": map[string]string{"type": "dns", "value": s.domain},
+			"challenges": []map[string]string{s.challenge()},
+		})
+	case "/challenge/1":
+		s.authorized = s.checkHTTP01()
+		s.reply(w, http.StatusOK, s.challenge())
+	case "/finalize/1":
+		s.chain = s.issue(r)
+		w.Header().Set("Location", url+"/order/1")
+		s.reply(w, http.StatusOK, s.order())
+	case "/cert/1":
+		w.Header().Set("Content-Type", "application/pem-certificate-chain")
+		w.Write(s.chain)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *stubACME) reply(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func (s *stubACME) order() map[string]any {
+	order := map[string]any{
+		"status":         "pending",
+		"identifiers":    []map[string]string{{"type": "dns", "value": s.domain}},
+		"authorizations": []string{s.server.URL + "/authz/1"},
+		"finalize":       s.server.URL + "/finalize/1",
+	}
+	switch {
+	case s.chain != nil:
+		order["status"] = "valid"
+		order["certificate"] = s.server.URL + "/cert/1"
+	case s.authorized:
+		order["status"] = "ready"
+	}
+	return order
+}
+
+func (s *stubACME) challenge() map[string]string {
+	status := "pending"
+	if s.authorized {
+		status = "valid"
+	}
+	return map[string]string{"type": ChallengeHTTP01, "url": s.server.URL + "/challenge/1", "token": "token1", "status": status}
+}
+
+// checkHTTP01 запрашивает ответ на проверку у сервера, поднятого эмитентом
+func (s *stubACME) checkHTTP01() bool {
+	res, err := http.Get("http://" + s.listenAddr + "/.well-known/acme-challenge/token1")
+	if err != nil {
+		s.t.Errorf("http-01 request: %v", err)
+		return false
+	}
+	defer res.Body.Close()
+	body, _ := io.ReadAll(res.Body)
+	if res.StatusCode != http.StatusOK || !strings.HasPrefix(string(body), "token1.") {
+		s.t.Errorf("http-01 response: %d %q", res.StatusCode, body)
+		return false
+	}
+	return true
+}
+
+// issue подписывает CSR из finalize и возвращает цепочку leaf + корень стаба
+func (s *stubACME) issue(r *http.Request) []byte {
+	var jws struct {
+		Payload string `json:"payload"`
+	}
+	var finalize struct {
+		CSR string `json:"csr"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&jws)
+	if err != nil {
+		s.t.Fatalf("finalize JWS: %v", err)
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(jws.Payload)
+	if err != nil {
+		s.t.Fatalf("finalize payload: %v", err)
+	}
+	err = json.Unmarshal(payload, &finalize)
+	if err != nil {
+		s.t.Fatalf("finalize request: %v", err)
+	}
+	der, err := base64.RawURLEncoding.DecodeString(finalize.CSR)
+	if err != nil {
+		s.t.Fatalf("finalize CSR: %v", err)
+	}
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		s.t.Fatalf("parse CSR: %v", err)
+	}
+
+	leaf, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: s.domain},
+		DNSNames:     csr.DNSNames,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(90 * 24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, s.issuer, csr.PublicKey, s.issuerKey)
+	if err != nil {
+		s.t.Fatalf("sign leaf: %v", err)
+	}
+	return append(encodeCert(leaf), encodeCert(s.issuer.Raw)...)
+}
+
+// freeAddr возвращает свободный локальный адрес для сервера проверки
+func freeAddr(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	return listener.Addr().String()
+}
+
+func TestACMEIssuerCustomDirectory(t *testing.T) {
+	const domain = "vpn.example.com"
+	stub := newStubACME(t, domain, freeAddr(t))
+	dir := t.TempDir()
+
+	issuer := NewACMEIssuer(structures.ACMEConfig{
+		Enabled:      true,
+		DirectoryURL: stub.server.URL + "/directory",
+		DirectoryCA:  stub.caFile(),
+		Email:        "admin@example.com",
+		Challenge:    ChallengeHTTP01,
+		ListenAddr:   stub.listenAddr,
+		AccountKey:   filepath.Join(dir, "account.key"),
+	})
+	ocConfig := structures.OpenConnectConfig{
+		Server:   domain,
+		Security: structures.SecurityConfig{ServerKey: structures.KeyConfig{Type: KeyECDSA, Size: 256}},
+	}
+	certPath, keyPath := filepath.Join(dir, "server-cert.pem"), filepath.Join(dir, "server-key.pem")
+
+	err := issuer.IssueServerCert(ocConfig, certPath, keyPath)
+	if err != nil {
+		t.Fatalf("IssueServerCert: %v\nrequests: %v", err, stub.requests)
+	}
+
+	// Записана вся цепочка, и ключ соответствует сертификату
+	_, err = tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		t.Fatalf("issued key pair: %v", err)
+	}
+	data, err := os.ReadFile(certPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(data), "BEGIN CERTIFICATE"); n != 2 {
+		t.Errorf("chain has %d certificates, want 2", n)
+	}
+	leaf, err := readCert(certPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(leaf.DNSNames) != 1 || leaf.DNSNames[0] != domain {
+		t.Errorf("leaf DNS names = %v", leaf.DNSNames)
+	}
+	if !stub.registered || !stub.authorized {
+		t.Errorf("registered=%v authorized=%v", stub.registered, stub.authorized)
+	}
+
+	// Ключ аккаунта сохраняется для следующих выпусков
+	if _, err := os.Stat(filepath.Join(dir, "account.key")); err != nil {
+		t.Errorf("account key not saved: %v", err)
+	}
+}
+
+func TestACMEIssuerUntrustedDirectory(t *testing.T) {
+	stub := newStubACME(t, "vpn.example.com", freeAddr(t))
+	dir := t.TempDir()
+
+	// Без directory_ca корень стаба не доверен
+	issuer := NewACMEIssuer(structures.ACMEConfig{
+		DirectoryURL: stub.server.URL + "/directory",
+		AccountKey:   filepath.Join(dir, "account.key"),
+	})
+	err := issuer.IssueServerCert(structures.OpenConnectConfig{Server: "vpn.example.com"},
+		filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"))
+	if err == nil {
+		t.Fatal("IssueServerCert trusted an unknown directory CA")
+	}
+	if stub.registered {
+		t.Error("account registered over untrusted TLS")
+	}
+}
+
+func TestACMEIssuerRejectsIP(t *testing.T) {
+	issuer := NewACMEIssuer(structures.ACMEConfig{AccountKey: filepath.Join(t.TempDir(), "account.key")})
+	err := issuer.IssueServerCert(structures.OpenConnectConfig{Server: "203.0.113.1"}, "cert.pem", "key.pem")
+	if err == nil || !strings.Contains(err.Error(), "DNS name") {
+		t.Errorf("got %v, want DNS name error", err)
+	}
+}
@@ -0,0 +1,253 @@
+package pki
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"eidolonVPN/internal/config/structures"
+	"eidolonVPN/internal/errors"
+	"encoding/base64"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// ClientBundle - файлы для импорта клиентского сертификата на устройство
+type ClientBundle struct {
+	UserID           string
+	PKCS12           []byte
+	PKCS12Path       string
+	MobileConfig     []byte
+	MobileConfigPath string
+	ServerPin        string // pin-sha256:... серверного сертификата
+	Command          string // Готовая команда openconnect
+}
+
+// ServerPin вычисляет pin-sha256 серверного сертификата для --servercert
+func ServerPin(certPath string) (string, error) {
+	cert, err := readCert(certPath)
+	if err != nil {
+		return "", err
+	}
+	return publicKeyPin(cert), nil
+}
+
+// publicKeyPin возвращает SHA-256 от SubjectPublicKeyInfo в формате openconnect
+func publicKeyPin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return "pin-sha256:" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// OpenConnectCommand формирует команду подключения openconnect с закрепленным сертификатом сервера
+func OpenConnectCommand(ocConfig structures.OpenConnectConfig, pin, p12File string) string {
+	server := ocConfig.Server
+	if ocConfig.Port != 0 && ocConfig.Port != 443 {
+		server += ":" + strconv.Itoa(ocConfig.Port)
+	}
+
+	args := []string{"openconnect", "--protocol=anyconnect"}
+	if pin != "" {
+		args = append(args, "--servercert", pin)
+	}
+	if p12File != "" {
+		args = append(args, "--certificate", p12File)
+	}
+	return strings.Join(append(args, server), " ")
+}
+
+// ExportPKCS12 упаковывает сертификат и ключ пользователя вместе с CA в защищенный паролем .p12
+func (a *Authority) ExportPKCS12(userID, password string) ([]byte, error) {
+	if !userIDPattern.MatchString(userID) {
+		return nil, errors.CallPKIError(fmt.Sprintf("Invalid user id %q", userID), nil)
+	}
+	if password == "" {
+		return nil, errors.CallPKIError("PKCS#12 password must not be empty", nil)
+	}
+
+	dir := a.UserCertsDir(userID)
+	cert, err := readCert(filepath.Join(dir, "cert.pem"))
+	if err != nil {
+		return nil, err
+	}
+	key, err := readKey(filepath.Join(dir, "key.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	// LegacyDES (3DES + SHA-1 MAC) импортируют iOS, Android и AnyConnect;
+	// Modern (AES-256) поддерживается не всеми мобильными клиентами
+	data, err := pkcs12.LegacyDES.Encode(key, cert, []*x509.Certificate{a.cert}, password)
+	if err != nil {
+		return nil, errors.CallPKIError(fmt.Sprintf("Failed to encode PKCS#12 for %s", userID), err)
+	}
+	return data, nil
+}
+
+// ExportBundle готовит .p12, .mobileconfig и команду openconnect для пользователя
+// и сохраняет файлы рядом с его сертификатом
+func (a *Authority) ExportBundle(ocConfig structures.OpenConnectConfig, userID, password string) (*ClientBundle, error) {
+	p12, err := a.ExportPKCS12(userID, password)
+	if err != nil {
+		return nil, err
+	}
+
+	certPath, _ := ServerCertPaths(ocConfig)
+	pin, err := ServerPin(certPath)
+	if err != nil {
+		return nil, err
+	}
+
+	profile, err := MobileConfig(ocConfig, userID, p12)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := a.UserCertsDir(userID)
+	bundle := &ClientBundle{
+		UserID:           userID,
+		PKCS12:           p12,
+		PKCS12Path:       filepath.Join(dir, userID+".p12"),
+		MobileConfig:     profile,
+		MobileConfigPath: filepath.Join(dir, userID+".mobileconfig"),
+		ServerPin:        pin,
+		Command:          OpenConnectCommand(ocConfig, pin, userID+".p12"),
+	}
+
+	err = writeFile(bundle.PKCS12Path, p12, 0600)
+	if err != nil {
+		return nil, err
+	}
+	err = writeFile(bundle.MobileConfigPath, profile, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return bundle, nil
+}
+
+// Профиль Apple: сертификат PKCS#12 и VPN AnyConnect, ссылающийся на него
+var mobileConfigTemplate = template.Must(template.New("mobileconfig").Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>PayloadContent</key>
+	<array>
+		<dict>
+			<key>PayloadType</key>
+			<string>com.apple.security.pkcs12</string>
+			<key>PayloadVersion</key>
+			<integer>1</integer>
+			<key>PayloadIdentifier</key>
+			<string>{{.Identifier}}.certificate</string>
+			<key>PayloadUUID</key>
+			<string>{{.CertUUID}}</string>
+			<key>PayloadDisplayName</key>
+			<string>{{.UserID}}</string>
+			<key>PayloadCertificateFileName</key>
+			<string>{{.UserID}}.p12</string>
+			<key>PayloadContent</key>
+			<data>{{.PKCS12}}</data>
+		</dict>
+		<dict>
+			<key>PayloadType</key>
+			<string>com.apple.vpn.managed</string>
+			<key>PayloadVersion</key>
+			<integer>1</integer>
+			<key>PayloadIdentifier</key>
+			<string>{{.Identifier}}.vpn</string>
+			<key>PayloadUUID</key>
+			<string>{{.VPNUUID}}</string>
+			<key>PayloadDisplayName</key>
+			<string>{{.Name}}</string>
+			<key>UserDefinedName</key>
+			<string>{{.Name}}</string>
+			<key>VPNType</key>
+			<string>VPN</string>
+			<key>VPNSubType</key>
+			<string>com.cisco.anyconnect</string>
+			<key>VendorConfig</key>
+			<dict/>
+			<key>VPN</key>
+			<dict>
+				<key>RemoteAddress</key>
+				<string>{{.Server}}</string>
+				<key>AuthName</key>
+				<string>{{.UserID}}</string>
+				<key>AuthenticationMethod</key>
+				<string>Certificate</string>
+				<key>PayloadCertificateUUID</key>
+				<string>{{.CertUUID}}</string>
+			</dict>
+		</dict>
+	</array>
+	<key>PayloadType</key>
+	<string>Configuration</string>
+	<key>PayloadVersion</key>
+	<integer>1</integer>
+	<key>PayloadIdentifier</key>
+	<string>{{.Identifier}}</string>
+	<key>PayloadUUID</key>
+	<string>{{.ProfileUUID}}</string>
+	<key>PayloadDisplayName</key>
+	<string>{{.Name}} ({{.UserID}})</string>
+</dict>
+</plist>
+`))
+
+// MobileConfig формирует профиль .mobileconfig для iOS/macOS. Пароль .p12 в профиль не пишется:
+// профиль уходит вместе с .p12, и iOS запросит пароль при установке
+func MobileConfig(ocConfig structures.OpenConnectConfig, userID string, p12 []byte) ([]byte, error) {
+	server := ocConfig.Server
+	if ocConfig.Port != 0 && ocConfig.Port != 443 {
+		server += ":" + strconv.Itoa(ocConfig.Port)
+	}
+
+	uuids := make([]string, 3)
+	for i := range uuids {
+		id, err := newUUID()
+		if err != nil {
+			return nil, err
+		}
+		uuids[i] = id
+	}
+
+	data := map[string]string{
+		"Identifier":  "vpn.eidolon." + userID,
+		"Name":        ocConfig.Name,
+		"Server":      server,
+		"UserID":      userID,
+		"PKCS12":      base64.StdEncoding.EncodeToString(p12),
+		"CertUUID":    uuids[0],
+		"VPNUUID":     uuids[1],
+		"ProfileUUID": uuids[2],
+	}
+	// Значения попадают в XML и должны быть экранированы
+	for key, value := range data {
+		var escaped bytes.Buffer
+		template.HTMLEscape(&escaped, []byte(value))
+		data[key] = escaped.String()
+	}
+
+	var out bytes.Buffer
+	err := mobileConfigTemplate.Execute(&out, data)
+	if err != nil {
+		return nil, errors.CallPKIError("Failed to render mobileconfig", err)
+	}
+	return out.Bytes(), nil
+}
+
+// newUUID генерирует случайный UUID версии 4
+func newUUID() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", errors.CallPKIError("Failed to generate UUID", err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%X-%X-%X-%X-%X", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
@@ -0,0 +1,192 @@
+package pki
+
+import (
+	"bytes"
+	"crypto"
+	"eidolonVPN/internal/config/structures"
+	"encoding/base64"
+	"encoding/xml"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// pin-sha256 testdata/server-cert.pem, посчитан openssl:
+// openssl x509 -pubkey -noout | openssl pkey -pubin -outform der | openssl dgst -sha256 -binary | base64
+const testServerPin = "pin-sha256:eL4aIElnv7nkGQpFFGXQGQXdoMzAYqWCVnqE9B744xk="
+
+// plistNode - элемент XML plist
+type plistNode struct {
+	XMLName xml.Name
+	Text    string      `xml:",chardata"`
+	Nodes   []plistNode `xml:",any"`
+}
+
+// value переводит элемент plist в map, срез, строку или байты
+func (n plistNode) value(t *testing.T) any {
+	t.Helper()
+
+	switch n.XMLName.Local {
+	case "dict":
+		dict := map[string]any{}
+		for i := 0; i+1 < len(n.Nodes); i += 2 {
+			if n.Nodes[i].XMLName.Local != "key" {
+				t.Fatalf("dict entry %d is %s, want key", i, n.Nodes[i].XMLName.Local)
+			}
+			dict[n.Nodes[i].Text] = n.Nodes[i+1].value(t)
+		}
+		return dict
+	case "array":
+		list := make([]any, 0, len(n.Nodes))
+		for _, node := range n.Nodes {
+			list = append(list, node.value(t))
+		}
+		return list
+	case "data":
+		data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(n.Text))
+		if err != nil {
+			t.Fatalf("bad plist data: %v", err)
+		}
+		return data
+	default:
+		return n.Text
+	}
+}
+
+// parsePlist разбирает профиль в словарь верхнего уровня
+func parsePlist(t *testing.T, data []byte) map[string]any {
+	t.Helper()
+
+	var plist plistNode
+	err := xml.Unmarshal(data, &plist)
+	if err != nil {
+		t.Fatalf("profile is not valid XML: %v", err)
+	}
+	if plist.XMLName.Local != "plist" || len(plist.Nodes) != 1 {
+		t.Fatalf("unexpected plist root: %s", plist.XMLName.Local)
+	}
+	dict, ok := plist.Nodes[0].value(t).(map[string]any)
+	if !ok {
+		t.Fatal("plist root is not a dict")
+	}
+	return dict
+}
+
+func TestExportPKCS12(t *testing.T) {
+	authority, _, _ := testAuthority(t)
+	issued, err := authority.IssueClientCert("alice", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := authority.ExportPKCS12("alice", "s3cret")
+	if err != nil {
+		t.Fatalf("ExportPKCS12: %v", err)
+	}
+	key, cert, cas, err := pkcs12.DecodeChain(data, "s3cret")
+	if err != nil {
+		t.Fatalf("DecodeChain: %v", err)
+	}
+	if cert.Subject.CommonName != "alice" || cert.SerialNumber.Text(16) != issued.Serial {
+		t.Errorf("unexpected certificate: CN=%s serial=%s", cert.Subject.CommonName, cert.SerialNumber.Text(16))
+	}
+	if !cert.PublicKey.(interface{ Equal(crypto.PublicKey) bool }).Equal(key.(crypto.Signer).Public()) {
+		t.Error("key does not match certificate")
+	}
+	if len(cas) != 1 || !cas[0].Equal(authority.Certificate()) {
+		t.Errorf("chain does not contain the CA: %d certificates", len(cas))
+	}
+
+	if _, _, _, err := pkcs12.DecodeChain(data, "wrong"); err == nil {
+		t.Error("PKCS#12 opened with a wrong password")
+	}
+	if _, err := authority.ExportPKCS12("alice", ""); err == nil {
+		t.Error("exported PKCS#12 without password")
+	}
+	if _, err := authority.ExportPKCS12("../alice", "s3cret"); err == nil {
+		t.Error("exported PKCS#12 for invalid user id")
+	}
+}
+
+func TestMobileConfig(t *testing.T) {
+	p12 := []byte("fake p12 payload")
+	ocConfig := structures.OpenConnectConfig{Name: "Eidolon <VPN> & Co", Server: "vpn.example.com", Port: 8443}
+
+	data, err := MobileConfig(ocConfig, "alice", p12)
+	if err != nil {
+		t.Fatalf("MobileConfig: %v", err)
+	}
+	profile := parsePlist(t, data)
+	if profile["PayloadType"] != "Configuration" || profile["PayloadDisplayName"] != "Eidolon <VPN> & Co (alice)" {
+		t.Errorf("unexpected profile: %v", profile["PayloadDisplayName"])
+	}
+
+	payloads, _ := profile["PayloadContent"].([]any)
+	if len(payloads) != 2 {
+		t.Fatalf("got %d payloads, want 2", len(payloads))
+	}
+	certPayload, _ := payloads[0].(map[string]any)
+	vpnPayload, _ := payloads[1].(map[string]any)
+
+	if certPayload["PayloadType"] != "com.apple.security.pkcs12" {
+		t.Errorf("first payload is %v", certPayload["PayloadType"])
+	}
+	if _, ok := certPayload["Password"]; ok {
+		t.Error("profile embeds the PKCS#12 password")
+	}
+	if content, _ := certPayload["PayloadContent"].([]byte); !bytes.Equal(content, p12) {
+		t.Error("certificate payload does not carry the PKCS#12")
+	}
+
+	vpn, _ := vpnPayload["VPN"].(map[string]any)
+	if vpn["RemoteAddress"] != "vpn.example.com:8443" || vpn["AuthenticationMethod"] != "Certificate" {
+		t.Errorf("unexpected VPN payload: %v", vpn)
+	}
+	if vpn["PayloadCertificateUUID"] != certPayload["PayloadUUID"] {
+		t.Error("VPN payload does not reference the certificate payload")
+	}
+	if certPayload["PayloadUUID"] == vpnPayload["PayloadUUID"] || certPayload["PayloadUUID"] == profile["PayloadUUID"] {
+		t.Error("payload UUIDs are not unique")
+	}
+}
+
+func TestServerPin(t *testing.T) {
+	pin, err := ServerPin(filepath.Join("testdata", "server-cert.pem"))
+	if err != nil {
+		t.Fatalf("ServerPin: %v", err)
+	}
+	if pin != testServerPin {
+		t.Errorf("pin %s, want %s", pin, testServerPin)
+	}
+
+	if _, err := ServerPin(filepath.Join("testdata", "missing.pem")); err == nil {
+		t.Error("pin computed for missing certificate")
+	}
+}
+
+func TestOpenConnectCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		port    int
+		pin     string
+		p12File string
+		want    string
+	}{
+		{"default port", 443, testServerPin, "alice.p12",
+			"openconnect --protocol=anyconnect --servercert " + testServerPin + " --certificate alice.p12 vpn.example.com"},
+		{"custom port", 8443, testServerPin, "",
+			"openconnect --protocol=anyconnect --servercert " + testServerPin + " vpn.example.com:8443"},
+		{"no pin", 0, "", "", "openconnect --protocol=anyconnect vpn.example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ocConfig := structures.OpenConnectConfig{Server: "vpn.example.com", Port: tt.port}
+			if got := OpenConnectCommand(ocConfig, tt.pin, tt.p12File); got != tt.want {
+				t.Errorf("got %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,264 @@
+package pki
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"eidolonVPN/internal/config/structures"
+	"eidolonVPN/internal/errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// Сроки действия сертификатов по умолчанию
+const (
+	caValidity     = 20 * 365 * 24 * time.Hour // 20 лет
+	serverValidity = 2 * 365 * 24 * time.Hour  // 2 года
+	DefaultUserTTL = 365 * 24 * time.Hour      // 1 год
+)
+
+// Допустимые идентификаторы пользователей: используются как имя каталога и CN
+var userIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._@-]{0,63}$`)
+
+// Authority - корневой CA eidolon, подписывающий серверный и клиентские сертификаты
+type Authority struct {
+	certPath string
+	keyPath  string
+	usersDir string               // Каталог data/users
+	keyType  structures.KeyConfig // Ключи CA и клиентских сертификатов
+	cert     *x509.Certificate
+	key      crypto.Signer
+	mutex    sync.Mutex
+
+	crlPath       string
+	revocations   RevocationStore
+	crlNextUpdate time.Time // NextUpdate последнего выпущенного CRL
+}
+
+// ClientCert описывает выпущенный клиентский сертификат
+type ClientCert struct {
+	UserID   string
+	Serial   string // Серийный номер в hex
+	CertPath string
+	KeyPath  string
+	NotAfter time.Time
+}
+
+// LoadOrCreateAuthority загружает корневой CA или создает новый, если файлов нет.
+// keyType задает алгоритм ключей нового CA и клиентских сертификатов
+func LoadOrCreateAuthority(certPath, keyPath, usersDir, organization string, keyType structures.KeyConfig) (*Authority, error) {
+	a := &Authority{
+		certPath: certPath,
+		keyPath:  keyPath,
+		usersDir: usersDir,
+		keyType:  keyType,
+	}
+
+	_, certErr := os.Stat(certPath)
+	_, keyErr := os.Stat(keyPath)
+	if os.IsNotExist(certErr) && os.IsNotExist(keyErr) {
+		return a, a.create(organization)
+	}
+
+	cert, err := readCert(certPath)
+	if err != nil {
+		return nil, err
+	}
+	key, err := readKey(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	if !cert.IsCA {
+		return nil, errors.CallPKIError(fmt.Sprintf("Certificate %s is not a CA", certPath), nil)
+	}
+
+	a.cert = cert
+	a.key = key
+	return a, nil
+}
+
+// CertPath возвращает путь к сертификату CA
+func (a *Authority) CertPath() string {
+	return a.certPath
+}
+
+// Certificate возвращает сертификат CA
+func (a *Authority) Certificate() *x509.Certificate {
+	return a.cert
+}
+
+// UserCertsDir возвращает каталог сертификатов пользователя
+func (a *Authority) UserCertsDir(userID string) string {
+	return filepath.Join(a.usersDir, userID, "certs")
+}
+
+// IssueServerCert выпускает серверный сертификат ocserv, подписанный CA
+func (a *Authority) IssueServerCert(ocConfig structures.OpenConnectConfig, certPath, keyPath string) error {
+	key, err := generateKey(ocConfig.Security.ServerKey)
+	if err != nil {
+		return err
+	}
+	serial, err := newSerial()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			Organization: []string{ocConfig.Name},
+			CommonName:   ocConfig.Server,
+		},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(serverValidity),
+		KeyUsage:              keyUsage(key),
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	addServerSAN(template, ocConfig.Server)
+
+	der, err := a.sign(template, key)
+	if err != nil {
+		return err
+	}
+	return writeKeyPair(certPath, keyPath, der, key)
+}
+
+// addServerSAN добавляет адрес сервера в SAN: IP - в IPAddresses, имя - в DNSNames.
+// VerifyHostname сверяет IP только с IPAddresses
+func addServerSAN(template *x509.Certificate, server string) {
+	if ip := net.ParseIP(server); ip != nil {
+		template.IPAddresses = append(template.IPAddresses, ip)
+	} else if server != "" {
+		template.DNSNames = append(template.DNSNames, server)
+	}
+}
+
+// EnsureServerCert выпускает серверный сертификат, если его еще нет
+func (a *Authority) EnsureServerCert(ocConfig structures.OpenConnectConfig, certPath, keyPath string) error {
+	if _, err := os.Stat(certPath); err == nil {
+		if _, err := os.Stat(keyPath); err == nil {
+			return nil
+		}
+	}
+	return a.IssueServerCert(ocConfig, certPath, keyPath)
+}
+
+// IssueClientCert выпускает клиентский сертификат и сохраняет его в data/users/{user_id}/certs
+func (a *Authority) IssueClientCert(userID string, ttl time.Duration) (*ClientCert, error) {
+	if !userIDPattern.MatchString(userID) {
+		return nil, errors.CallPKIError(fmt.Sprintf("Invalid user id %q", userID), nil)
+	}
+	if ttl <= 0 {
+		ttl = DefaultUserTTL
+	}
+
+	key, err := generateKey(a.keyType)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := newSerial()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			Organization: a.cert.Subject.Organization,
+			CommonName:   userID, // ocserv берет имя пользователя из CN (cert-user-oid)
+		},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(ttl),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+	}
+
+	der, err := a.sign(template, key)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := a.UserCertsDir(userID)
+	issued := &ClientCert{
+		UserID:   userID,
+		Serial:   serial.Text(16),
+		CertPath: filepath.Join(dir, "cert.pem"),
+		KeyPath:  filepath.Join(dir, "key.pem"),
+		NotAfter: template.NotAfter,
+	}
+
+	err = writeKeyPair(issued.CertPath, issued.KeyPath, der, key)
+	if err != nil {
+		return nil, err
+	}
+	return issued, nil
+}
+
+// create генерирует новый корневой CA
+func (a *Authority) create(organization string) error {
+	key, err := generateKey(a.keyType)
+	if err != nil {
+		return err
+	}
+	serial, err := newSerial()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			Organization: []string{organization},
+			CommonName:   organization + " Root CA",
+		},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(caValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return errors.CallPKIError("Failed to create CA certificate", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return errors.CallPKIError("Failed to parse CA certificate", err)
+	}
+
+	err = writeKeyPair(a.certPath, a.keyPath, der, key)
+	if err != nil {
+		return err
+	}
+
+	a.cert = cert
+	a.key = key
+	return nil
+}
+
+// sign подписывает шаблон ключом CA
+func (a *Authority) sign(template *x509.Certificate, key crypto.Signer) ([]byte, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	der, err := x509.CreateCertificate(rand.Reader, template, a.cert, key.Public(), a.key)
+	if err != nil {
+		return nil, errors.CallPKIError(fmt.Sprintf("Failed to sign certificate for %s", template.Subject.CommonName), err)
+	}
+	return der, nil
+}
@@ -0,0 +1,242 @@
+package pki
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/x509"
+	"eidolonVPN/internal/errors"
+	"eidolonVPN/internal/errors/handlers"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Срок действия CRL; список перевыпускается при каждом отзыве, запуске
+// и заранее до истечения: с просроченным CRL ocserv отклоняет все клиентские сертификаты
+const (
+	crlValidity      = 30 * 24 * time.Hour
+	crlRefreshBefore = 7 * 24 * time.Hour // Перевыпуск, когда до NextUpdate осталось меньше
+	crlCheckInterval = time.Hour
+)
+
+// Коды причин отзыва по RFC 5280
+const (
+	ReasonUnspecified   = 0
+	ReasonKeyCompromise = 1
+	ReasonSuperseded    = 4
+	ReasonCessation     = 5
+)
+
+// RevokedCert описывает отозванный сертификат
+type RevokedCert struct {
+	Serial    string    `json:"serial"` // Серийный номер в hex
+	UserID    string    `json:"user_id"`
+	RevokedAt time.Time `json:"revoked_at"`
+	Reason    int       `json:"reason"`
+}
+
+// RevocationStore хранит список отозванных сертификатов
+type RevocationStore interface {
+	AddRevoked(revoked RevokedCert) error
+	ListRevoked() ([]RevokedCert, error)
+}
+
+// FileRevocationStore хранит отозванные сертификаты в JSON файле.
+// Используется только для переноса прежних отзывов в базу, см. DBRevocationStore
+type FileRevocationStore struct {
+	path  string
+	mutex sync.Mutex
+}
+
+// NewFileRevocationStore создает файловое хранилище отзывов
+func NewFileRevocationStore(path string) *FileRevocationStore {
+	return &FileRevocationStore{path: path}
+}
+
+// AddRevoked добавляет сертификат в список отозванных
+func (s *FileRevocationStore) AddRevoked(revoked RevokedCert) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	list, err := s.read()
+	if err != nil {
+		return err
+	}
+	for _, existing := range list {
+		if existing.Serial == revoked.Serial {
+			return nil
+		}
+	}
+	list = append(list, revoked)
+
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return errors.CallPKIError("Failed to encode revocation list", err)
+	}
+	return writeFile(s.path, data, 0600)
+}
+
+// ListRevoked возвращает все отозванные сертификаты
+func (s *FileRevocationStore) ListRevoked() ([]RevokedCert, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.read()
+}
+
+// read читает список из файла
+func (s *FileRevocationStore) read() ([]RevokedCert, error) {
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, handlers.PKIFileErrHandler(s.path, err)
+	}
+
+	var list []RevokedCert
+	err = json.Unmarshal(data, &list)
+	if err != nil {
+		return nil, errors.CallPKIError(fmt.Sprintf("Failed to parse %s", s.path), err)
+	}
+	return list, nil
+}
+
+// EnableCRL включает ведение CRL и сразу перевыпускает список
+func (a *Authority) EnableCRL(crlPath string, store RevocationStore) error {
+	a.mutex.Lock()
+	a.crlPath = crlPath
+	a.revocations = store
+	a.mutex.Unlock()
+
+	return a.WriteCRL()
+}
+
+// CRLPath возвращает путь к CRL
+func (a *Authority) CRLPath() string {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	return a.crlPath
+}
+
+// Revoke отзывает текущий сертификат пользователя и перевыпускает CRL.
+// Возвращает серийный номер отозванного сертификата
+func (a *Authority) Revoke(userID string, reason int) (string, error) {
+	if !userIDPattern.MatchString(userID) {
+		return "", errors.CallPKIError(fmt.Sprintf("Invalid user id %q", userID), nil)
+	}
+
+	cert, err := readCert(filepath.Join(a.UserCertsDir(userID), "cert.pem"))
+	if err != nil {
+		return "", err
+	}
+
+	serial := cert.SerialNumber.Text(16)
+	return serial, a.RevokeSerial(userID, serial, reason)
+}
+
+// RevokeSerial отзывает сертификат по серийному номеру и перевыпускает CRL
+func (a *Authority) RevokeSerial(userID, serial string, reason int) error {
+	a.mutex.Lock()
+	store := a.revocations
+	a.mutex.Unlock()
+
+	if store == nil {
+		return errors.CallPKIError("CRL is not enabled", nil)
+	}
+
+	err := store.AddRevoked(RevokedCert{
+		Serial:    serial,
+		UserID:    userID,
+		RevokedAt: time.Now(),
+		Reason:    reason,
+	})
+	if err != nil {
+		return err
+	}
+
+	return a.WriteCRL()
+}
+
+// WriteCRL подписывает и записывает актуальный CRL
+func (a *Authority) WriteCRL() error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if a.revocations == nil || a.crlPath == "" {
+		return errors.CallPKIError("CRL is not enabled", nil)
+	}
+
+	revoked, err := a.revocations.ListRevoked()
+	if err != nil {
+		return err
+	}
+
+	entries := make([]x509.RevocationListEntry, 0, len(revoked))
+	for _, r := range revoked {
+		serial, ok := new(big.Int).SetString(r.Serial, 16)
+		if !ok {
+			return errors.CallPKIError(fmt.Sprintf("Invalid serial %q in revocation list", r.Serial), nil)
+		}
+		entries = append(entries, x509.RevocationListEntry{
+			SerialNumber:   serial,
+			RevocationTime: r.RevokedAt,
+			ReasonCode:     r.Reason,
+		})
+	}
+
+	// Номер CRL монотонно растет вместе со временем выпуска
+	now := time.Now()
+	template := &x509.RevocationList{
+		Number:                    big.NewInt(now.Unix()),
+		ThisUpdate:                now,
+		NextUpdate:                now.Add(crlValidity),
+		RevokedCertificateEntries: entries,
+	}
+
+	der, err := x509.CreateRevocationList(rand.Reader, template, a.cert, a.key)
+	if err != nil {
+		return errors.CallPKIError("Failed to create CRL", err)
+	}
+
+	err = writeFile(a.crlPath, pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der}), 0644)
+	if err != nil {
+		return err
+	}
+	a.crlNextUpdate = template.NextUpdate
+	return nil
+}
+
+// RefreshCRL перевыпускает CRL до истечения NextUpdate, проверяя срок раз в crlCheckInterval.
+// onRefresh вызывается после перевыпуска, например для SIGHUP ocserv
+func (a *Authority) RefreshCRL(ctx context.Context, onRefresh func()) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(crlCheckInterval):
+		}
+
+		a.mutex.Lock()
+		due := a.revocations != nil && time.Until(a.crlNextUpdate) < crlRefreshBefore
+		a.mutex.Unlock()
+		if !due {
+			continue
+		}
+
+		err := a.WriteCRL()
+		if err != nil {
+			fmt.Printf("Failed to refresh CRL: %v\n", err)
+			continue
+		}
+		if onRefresh != nil {
+			onRefresh()
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package pki
+
+import (
+	"context"
+	"crypto/x509"
+	"eidolonVPN/internal/config/structures"
+	"eidolonVPN/internal/storage"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testAuthority создает CA с ECDSA ключами и CRL поверх временной базы
+func testAuthority(t *testing.T) (*Authority, *storage.DB, *DBRevocationStore) {
+	t.Helper()
+	dir := t.TempDir()
+
+	db, err := storage.Open(filepath.Join(dir, "eidolon.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	authority, err := LoadOrCreateAuthority(filepath.Join(dir, "ca-cert.pem"), filepath.Join(dir, "ca-key.pem"),
+		filepath.Join(dir, "users"), "Eidolon Test", structures.KeyConfig{Type: KeyECDSA, Size: 256})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := NewDBRevocationStore(db)
+	err = authority.EnableCRL(filepath.Join(dir, "crl.pem"), store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return authority, db, store
+}
+
+// readCRL разбирает CRL и проверяет подпись CA
+func readCRL(t *testing.T, authority *Authority) *x509.RevocationList {
+	t.Helper()
+
+	data, err := os.ReadFile(authority.CRLPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		t.Fatal("CRL is not PEM")
+	}
+	crl, err := x509.ParseRevocationList(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = crl.CheckSignatureFrom(authority.Certificate())
+	if err != nil {
+		t.Fatalf("CRL signature: %v", err)
+	}
+	return crl
+}
+
+func TestRevokeSerialStoresInDatabase(t *testing.T) {
+	authority, db, _ := testAuthority(t)
+	ctx := context.Background()
+
+	user := &storage.User{Username: "alice", Status: storage.UserActive}
+	if err := db.Users().Create(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	cert, err := authority.IssueClientCert("alice", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Certs().Add(ctx, &storage.IssuedCert{UserID: user.ID, Serial: cert.Serial, NotAfter: cert.NotAfter})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if crl := readCRL(t, authority); len(crl.RevokedCertificateEntries) != 0 {
+		t.Fatalf("fresh CRL has %d entries", len(crl.RevokedCertificateEntries))
+	}
+
+	err = authority.RevokeSerial("alice", cert.Serial, ReasonKeyCompromise)
+	if err != nil {
+		t.Fatalf("RevokeSerial: %v", err)
+	}
+
+	stored, err := db.Certs().GetBySerial(ctx, cert.Serial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.RevokedAt == nil || stored.Reason != ReasonKeyCompromise {
+		t.Errorf("cert not revoked in database: %+v", stored)
+	}
+
+	crl := readCRL(t, authority)
+	if len(crl.RevokedCertificateEntries) != 1 {
+		t.Fatalf("CRL has %d entries, want 1", len(crl.RevokedCertificateEntries))
+	}
+	entry := crl.RevokedCertificateEntries[0]
+	if entry.SerialNumber.Text(16) != cert.Serial || entry.ReasonCode != ReasonKeyCompromise {
+		t.Errorf("unexpected CRL entry: serial=%s reason=%d", entry.SerialNumber.Text(16), entry.ReasonCode)
+	}
+	if time.Until(crl.NextUpdate) < crlValidity-time.Hour {
+		t.Errorf("CRL NextUpdate %s is too close", crl.NextUpdate)
+	}
+
+	// Повторный отзыв не меняет запись
+	err = authority.RevokeSerial("alice", cert.Serial, ReasonSuperseded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if crl := readCRL(t, authority); len(crl.RevokedCertificateEntries) != 1 {
+		t.Errorf("repeated revoke: CRL has %d entries", len(crl.RevokedCertificateEntries))
+	}
+}
+
+func TestImportFileRevocations(t *testing.T) {
+	authority, db, store := testAuthority(t)
+	ctx := context.Background()
+
+	legacy := filepath.Join(t.TempDir(), "revoked.json")
+	file := NewFileRevocationStore(legacy)
+	revokedAt := time.Now().Add(-24 * time.Hour).Truncate(time.Second)
+	for _, serial := range []string{"1a2b", "3c4d"} {
+		err := file.AddRevoked(RevokedCert{Serial: serial, UserID: "bob", RevokedAt: revokedAt, Reason: ReasonCessation})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	imported, err := store.ImportFileRevocations(legacy)
+	if err != nil {
+		t.Fatalf("ImportFileRevocations: %v", err)
+	}
+	if imported != 2 {
+		t.Errorf("imported %d, want 2", imported)
+	}
+	if _, err := os.Stat(legacy + ".imported"); err != nil {
+		t.Errorf("legacy file not renamed: %v", err)
+	}
+
+	// Владелец без учетной записи создается заблокированным
+	user, err := db.Users().GetByUsername(ctx, "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Status != storage.UserLocked {
+		t.Errorf("placeholder user status = %s, want %s", user.Status, storage.UserLocked)
+	}
+
+	list, err := store.ListRevoked()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].UserID != "bob" || !list[0].RevokedAt.Equal(revokedAt) {
+		t.Errorf("unexpected revocations: %+v", list)
+	}
+
+	err = authority.WriteCRL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if crl := readCRL(t, authority); len(crl.RevokedCertificateEntries) != 2 {
+		t.Errorf("CRL has %d entries, want 2", len(crl.RevokedCertificateEntries))
+	}
+
+	// Повторный запуск без файла ничего не делает
+	imported, err = store.ImportFileRevocations(legacy)
+	if err != nil || imported != 0 {
+		t.Errorf("second import: %d, %v", imported, err)
+	}
+}
@@ -0,0 +1,194 @@
+package pki
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"eidolonVPN/internal/config/structures"
+	"eidolonVPN/internal/errors"
+	"eidolonVPN/internal/errors/handlers"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+)
+
+// Поддерживаемые типы ключей
+const (
+	KeyRSA     = "rsa"
+	KeyECDSA   = "ecdsa"
+	KeyEd25519 = "ed25519"
+)
+
+// generateKey создает приватный ключ для нового сертификата по настройкам KeyConfig
+func generateKey(cfg structures.KeyConfig) (crypto.Signer, error) {
+	var (
+		key crypto.Signer
+		err error
+	)
+
+	switch cfg.Type {
+	case "", KeyRSA:
+		size := cfg.Size
+		if size == 0 {
+			size = 2048
+		}
+		if size != 2048 && size != 3072 && size != 4096 {
+			return nil, errors.CallPKIError(fmt.Sprintf("Unsupported RSA key size %d", size), nil)
+		}
+		key, err = rsa.GenerateKey(rand.Reader, size)
+	case KeyECDSA:
+		var curve elliptic.Curve
+		switch cfg.Size {
+		case 0, 256:
+			curve = elliptic.P256()
+		case 384:
+			curve = elliptic.P384()
+		default:
+			return nil, errors.CallPKIError(fmt.Sprintf("Unsupported ECDSA curve size %d", cfg.Size), nil)
+		}
+		key, err = ecdsa.GenerateKey(curve, rand.Reader)
+	case KeyEd25519:
+		_, key, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, errors.CallPKIError(fmt.Sprintf("Unsupported key type %q", cfg.Type), nil)
+	}
+
+	if err != nil {
+		return nil, errors.CallPKIError("Failed to generate private key", err)
+	}
+	return key, nil
+}
+
+// keyUsage возвращает KeyUsage для ключа: шифрование ключа допустимо только для RSA
+func keyUsage(key crypto.Signer) x509.KeyUsage {
+	if _, ok := key.(*rsa.PrivateKey); ok {
+		return x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature
+	}
+	return x509.KeyUsageDigitalSignature
+}
+
+// newSerial генерирует случайный 128-битный серийный номер
+func newSerial() (*big.Int, error) {
+	limit := new(big.Int).Lsh(big.NewInt(1), 128)
+	serial, err := rand.Int(rand.Reader, limit)
+	if err != nil {
+		return nil, errors.CallPKIError("Failed to generate serial number", err)
+	}
+	return serial, nil
+}
+
+// encodeKey сериализует приватный ключ в PEM PKCS#8
+func encodeKey(key crypto.Signer) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, errors.CallPKIError(fmt.Sprintf("Unsupported key type %T", key), err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// encodeCert сериализует сертификат в PEM
+func encodeCert(der []byte) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+// readCert читает PEM сертификат из файла
+func readCert(path string) (*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, handlers.PKIFileErrHandler(path, err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, errors.CallPKIError(fmt.Sprintf("No PEM certificate in %s", path), nil)
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, errors.CallPKIError(fmt.Sprintf("Failed to parse certificate %s", path), err)
+	}
+	return cert, nil
+}
+
+// readKey читает PEM приватный ключ из файла
+func readKey(path string) (crypto.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, handlers.PKIFileErrHandler(path, err)
+	}
+
+	key, err := ParsePrivateKey(data)
+	if err != nil {
+		return nil, errors.CallPKIError(fmt.Sprintf("Failed to parse private key %s", path), err)
+	}
+	return key, nil
+}
+
+// ParsePrivateKey разбирает PEM ключ в формате PKCS#8, PKCS#1 (RSA) или SEC1 (EC).
+// Блоки вроде "EC PARAMETERS", которые добавляет openssl, пропускаются
+func ParsePrivateKey(data []byte) (crypto.Signer, error) {
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, errors.CallPKIError("No PEM private key found", nil)
+		}
+
+		switch block.Type {
+		case "PRIVATE KEY":
+			key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			signer, ok := key.(crypto.Signer)
+			if !ok {
+				return nil, errors.CallPKIError(fmt.Sprintf("Unsupported key type %T", key), nil)
+			}
+			return signer, nil
+		case "RSA PRIVATE KEY":
+			return x509.ParsePKCS1PrivateKey(block.Bytes)
+		case "EC PRIVATE KEY":
+			return x509.ParseECPrivateKey(block.Bytes)
+		}
+	}
+}
+
+// writeFile записывает файл, создавая каталог при необходимости
+func writeFile(path string, data []byte, perm os.FileMode) error {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return handlers.PKIFileErrHandler(filepath.Dir(path), err)
+	}
+
+	err = os.WriteFile(path, data, perm)
+	if err != nil {
+		return handlers.PKIFileErrHandler(path, err)
+	}
+
+	// WriteFile не меняет права существующего файла
+	err = os.Chmod(path, perm)
+	if err != nil {
+		return handlers.PKIFileErrHandler(path, err)
+	}
+	return nil
+}
+
+// writeKeyPair записывает сертификат и ключ
+func writeKeyPair(certPath, keyPath string, der []byte, key crypto.Signer) error {
+	keyPEM, err := encodeKey(key)
+	if err != nil {
+		return err
+	}
+
+	err = writeFile(keyPath, keyPEM, 0600)
+	if err != nil {
+		return err
+	}
+	return writeFile(certPath, encodeCert(der), 0644)
+}
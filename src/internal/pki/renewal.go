@@ -0,0 +1,239 @@
+package pki
+
+import (
+	"context"
+	"crypto"
+	"eidolonVPN/internal/config"
+	"eidolonVPN/internal/config/structures"
+	"eidolonVPN/internal/errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Значения по умолчанию для продления
+const (
+	defaultRenewBefore   = 30 * 24 * time.Hour
+	defaultCheckInterval = 12 * time.Hour
+)
+
+// CertStatus описывает состояние серверного сертификата
+type CertStatus struct {
+	CertPath     string
+	NotAfter     time.Time
+	DaysLeft     int  // Дней до истечения, отрицательно для истекшего
+	KeyMatches   bool // Ключ соответствует сертификату
+	CoversServer bool // SAN покрывают OpenConnectConfig.Server
+	SelfSigned   bool // Сертификат подписан сам собой
+	CheckedAt    time.Time
+	RenewedAt    time.Time // Время последнего перевыпуска, если был
+	Err          error     // Ошибка последней проверки
+}
+
+// NeedsRenewal сообщает, нужно ли перевыпустить сертификат
+func (s CertStatus) NeedsRenewal(threshold time.Duration) bool {
+	return !s.KeyMatches || !s.CoversServer || time.Until(s.NotAfter) < threshold
+}
+
+// InspectServerCert разбирает сертификат и проверяет его пригодность для сервера
+func InspectServerCert(certPath, keyPath, server string) (CertStatus, error) {
+	status := CertStatus{CertPath: certPath, CheckedAt: time.Now()}
+
+	cert, err := readCert(certPath)
+	if err != nil {
+		return status, err
+	}
+	key, err := readKey(keyPath)
+	if err != nil {
+		return status, err
+	}
+
+	status.NotAfter = cert.NotAfter
+	status.DaysLeft = int(time.Until(cert.NotAfter).Hours() / 24)
+
+	if pub, ok := cert.PublicKey.(interface{ Equal(crypto.PublicKey) bool }); ok {
+		status.KeyMatches = pub.Equal(key.Public())
+	}
+	status.CoversServer = server == "" || cert.VerifyHostname(server) == nil
+	// CheckSignatureFrom требует от родителя признака CA, которого у самоподписанной заглушки нет
+	status.SelfSigned = cert.Subject.String() == cert.Issuer.String() &&
+		cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature) == nil
+
+	return status, nil
+}
+
+// trustedIssuer - эмитент, чьи сертификаты должны заменять самоподписанную заглушку
+type trustedIssuer interface {
+	RequiresTrusted() bool
+}
+
+// Renewer следит за серверным сертификатом и перевыпускает его до истечения
+type Renewer struct {
+	registry   *config.Registry
+	issuer     ServerCertIssuer
+	mutex      sync.Mutex
+	status     CertStatus
+	onRenew    func(CertStatus)
+	onFailure  func(CertStatus)
+	onExpiring func(CertStatus)
+}
+
+// NewRenewer создает компонент продления серверного сертификата
+func NewRenewer(registry *config.Registry, issuer ServerCertIssuer) *Renewer {
+	return &Renewer{registry: registry, issuer: issuer}
+}
+
+// OnRenew устанавливает обработчик перевыпуска, например перезагрузку ocserv
+func (r *Renewer) OnRenew(handler func(CertStatus)) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.onRenew = handler
+}
+
+// OnFailure устанавливает обработчик неудачного перевыпуска; в статусе - срок текущего сертификата
+func (r *Renewer) OnFailure(handler func(CertStatus)) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.onFailure = handler
+}
+
+// OnExpiring устанавливает обработчик сертификата, до истечения которого осталось меньше
+// renewal.before. Вызывается до попытки перевыпуска, с состоянием текущего сертификата
+func (r *Renewer) OnExpiring(handler func(CertStatus)) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.onExpiring = handler
+}
+
+// Status возвращает результат последней проверки
+func (r *Renewer) Status() CertStatus {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.status
+}
+
+// Run проверяет сертификат сразу и затем периодически до отмены контекста
+func (r *Renewer) Run(ctx context.Context) {
+	for {
+		_, err := r.Check()
+		if err != nil {
+			fmt.Printf("Server certificate check failed: %v\n", err)
+		}
+
+		interval := r.registry.Current().OpenConnect.Security.Renewal.CheckInterval
+		if interval <= 0 {
+			interval = defaultCheckInterval
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// Check проверяет сертификат и перевыпускает его при необходимости
+func (r *Renewer) Check() (CertStatus, error) {
+	ocConfig := r.registry.Current().OpenConnect
+	certPath, keyPath := ServerCertPaths(ocConfig)
+
+	threshold := ocConfig.Security.Renewal.Before
+	if threshold <= 0 {
+		threshold = defaultRenewBefore
+	}
+
+	status, err := InspectServerCert(certPath, keyPath, ocConfig.Server)
+	replaceSelfSigned := false
+	if trusted, ok := r.issuer.(trustedIssuer); ok && trusted.RequiresTrusted() {
+		replaceSelfSigned = status.SelfSigned
+	}
+	if err == nil && !status.NeedsRenewal(threshold) && !replaceSelfSigned {
+		r.setStatus(status)
+		return status, nil
+	}
+
+	// Сертификат отсутствует, поврежден, истекает или не подходит серверу
+	reason := "unreadable"
+	if err == nil {
+		reason = fmt.Sprintf("expires in %d days, key match %t, covers %s %t, self-signed %t",
+			status.DaysLeft, status.KeyMatches, ocConfig.Server, status.CoversServer, status.SelfSigned)
+	}
+	fmt.Printf("Renewing server certificate %s: %s\n", certPath, reason)
+
+	if err == nil && time.Until(status.NotAfter) < threshold {
+		r.mutex.Lock()
+		handler := r.onExpiring
+		r.mutex.Unlock()
+		if handler != nil {
+			handler(status)
+		}
+	}
+
+	// При ошибке текущий сертификат остается на месте как запасной
+	err = r.issuer.IssueServerCert(ocConfig, certPath, keyPath)
+	if err != nil {
+		status.Err = errors.CallPKIError("Failed to renew server certificate", err)
+		r.fail(status)
+		return status, status.Err
+	}
+
+	status, err = InspectServerCert(certPath, keyPath, ocConfig.Server)
+	if err != nil {
+		status.Err = err
+		r.fail(status)
+		return status, err
+	}
+	// Негодный новый сертификат перевыпускался бы при каждой проверке с перезагрузкой ocserv
+	if status.NeedsRenewal(threshold) {
+		status.Err = errors.CallPKIError(fmt.Sprintf("Renewed server certificate is still unusable: expires in %d days, key match %t, covers %s %t",
+			status.DaysLeft, status.KeyMatches, ocConfig.Server, status.CoversServer), nil)
+		r.fail(status)
+		return status, status.Err
+	}
+	status.RenewedAt = time.Now()
+	r.setStatus(status)
+
+	r.mutex.Lock()
+	handler := r.onRenew
+	r.mutex.Unlock()
+	if handler != nil {
+		handler(status)
+	}
+
+	return status, nil
+}
+
+// fail сохраняет статус неудачного перевыпуска и вызывает обработчик
+func (r *Renewer) fail(status CertStatus) {
+	r.setStatus(status)
+
+	r.mutex.Lock()
+	handler := r.onFailure
+	r.mutex.Unlock()
+	if handler != nil {
+		handler(status)
+	}
+}
+
+// setStatus сохраняет результат проверки
+func (r *Renewer) setStatus(status CertStatus) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if status.RenewedAt.IsZero() {
+		status.RenewedAt = r.status.RenewedAt
+	}
+	r.status = status
+}
+
+// ServerCertPaths возвращает пути серверного сертификата и ключа из конфига
+func ServerCertPaths(ocConfig structures.OpenConnectConfig) (string, string) {
+	return filepath.Join(ocConfig.Security.CAPath, ocConfig.Security.CACert),
+		filepath.Join(ocConfig.Security.CAPath, ocConfig.Security.CAKey)
+}
@@ -0,0 +1,248 @@
+package pki
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"eidolonVPN/internal/config"
+	"eidolonVPN/internal/config/structures"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testRegistry создает реестр с сервером server и порогом продления before;
+// серверный сертификат лежит в dir/server-cert.pem
+func testRegistry(t *testing.T, server string, before time.Duration) (*config.Registry, string) {
+	t.Helper()
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"main.yaml": `logging: {level: info, format: text}
+storage: {database_path: /tmp/eidolon.db, data_dir: /tmp}
+`,
+		"openconnect.yaml": fmt.Sprintf(`server: %q
+port: 443
+protocol: tcp
+interface: eidolon0
+socket: /run/ocserv.socket
+security:
+  auth: "plain[passwd=/etc/ocserv/passwd]"
+  ca_path: %q
+  ca_cert: server-cert.pem
+  ca_key: server-key.pem
+  renewal: {before: %s}
+network: {mtu: 1400, lan: 10.20.30.0, lan_mask: 255.255.255.0}
+`, server, dir, before),
+	}
+	for name, content := range files {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	registry, err := config.NewRegistry([]string{dir})
+	if err != nil {
+		t.Fatalf("NewRegistry: %v", err)
+	}
+	return registry, dir
+}
+
+// writeTestCert записывает самоподписанный сертификат для server со сроком validity
+func writeTestCert(t *testing.T, certPath, keyPath, server string, validity time.Duration) {
+	t.Helper()
+
+	key, err := generateKey(structures.KeyConfig{Type: "ecdsa"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, err := newSerial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: server},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(validity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	addServerSAN(template, server)
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = writeKeyPair(certPath, keyPath, der, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// stubIssuer выпускает самоподписанный сертификат, считая вызовы
+type stubIssuer struct {
+	calls int
+}
+
+func (s *stubIssuer) IssueServerCert(ocConfig structures.OpenConnectConfig, certPath, keyPath string) error {
+	s.calls++
+	return SelfSignedIssuer{}.IssueServerCert(ocConfig, certPath, keyPath)
+}
+
+func TestInspectServerCert(t *testing.T) {
+	dir := t.TempDir()
+	issue := func(name, server string) (string, string) {
+		certPath, keyPath := filepath.Join(dir, name+".pem"), filepath.Join(dir, name+".key")
+		err := SelfSignedIssuer{}.IssueServerCert(structures.OpenConnectConfig{Name: "test", Server: server}, certPath, keyPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return certPath, keyPath
+	}
+	dnsCert, dnsKey := issue("dns", "vpn.example.com")
+	ipCert, ipKey := issue("ip", "203.0.113.5")
+
+	tests := []struct {
+		name       string
+		cert, key  string
+		server     string
+		keyMatches bool
+		covers     bool
+	}{
+		{"dns name", dnsCert, dnsKey, "vpn.example.com", true, true},
+		{"other dns name", dnsCert, dnsKey, "other.example.com", true, false},
+		{"ip", ipCert, ipKey, "203.0.113.5", true, true},
+		{"other ip", ipCert, ipKey, "203.0.113.6", true, false},
+		{"dns cert for ip server", dnsCert, dnsKey, "203.0.113.5", true, false},
+		{"foreign key", dnsCert, ipKey, "vpn.example.com", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, err := InspectServerCert(tt.cert, tt.key, tt.server)
+			if err != nil {
+				t.Fatalf("InspectServerCert: %v", err)
+			}
+			if status.KeyMatches != tt.keyMatches || status.CoversServer != tt.covers {
+				t.Errorf("key match %t, covers %t; want %t, %t", status.KeyMatches, status.CoversServer, tt.keyMatches, tt.covers)
+			}
+			if !status.SelfSigned || status.DaysLeft < 3600 {
+				t.Errorf("self-signed %t, days left %d", status.SelfSigned, status.DaysLeft)
+			}
+		})
+	}
+
+	_, err := InspectServerCert(filepath.Join(dir, "missing.pem"), dnsKey, "vpn.example.com")
+	if err == nil {
+		t.Error("missing certificate inspected without error")
+	}
+}
+
+func TestNeedsRenewal(t *testing.T) {
+	threshold := 30 * 24 * time.Hour
+	good := CertStatus{NotAfter: time.Now().Add(60 * 24 * time.Hour), KeyMatches: true, CoversServer: true}
+
+	tests := []struct {
+		name   string
+		change func(s *CertStatus)
+		renew  bool
+	}{
+		{"valid", func(s *CertStatus) {}, false},
+		{"key mismatch", func(s *CertStatus) { s.KeyMatches = false }, true},
+		{"wrong server", func(s *CertStatus) { s.CoversServer = false }, true},
+		{"within threshold", func(s *CertStatus) { s.NotAfter = time.Now().Add(29 * 24 * time.Hour) }, true},
+		{"expired", func(s *CertStatus) { s.NotAfter = time.Now().Add(-time.Hour) }, true},
+	}
+	for _, tt := range tests {
+		status := good
+		tt.change(&status)
+		if got := status.NeedsRenewal(threshold); got != tt.renew {
+			t.Errorf("%s: NeedsRenewal = %t, want %t", tt.name, got, tt.renew)
+		}
+	}
+}
+
+func TestRenewerCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		server   string
+		before   time.Duration
+		prepare  func(certPath, keyPath string)
+		issues   int  // Ожидаемое число выпусков за две проверки
+		expiring int  // Ожидаемое число вызовов OnExpiring
+		fails    bool // Перевыпуск не дает годного сертификата
+	}{
+		{"missing ip cert", "203.0.113.5", 30 * 24 * time.Hour, nil, 1, 0, false},
+		{"missing dns cert", "vpn.example.com", 30 * 24 * time.Hour, nil, 1, 0, false},
+		{"valid cert", "vpn.example.com", 30 * 24 * time.Hour, func(certPath, keyPath string) {
+			writeTestCert(t, certPath, keyPath, "vpn.example.com", 90*24*time.Hour)
+		}, 0, 0, false},
+		{"expiring cert", "vpn.example.com", 30 * 24 * time.Hour, func(certPath, keyPath string) {
+			writeTestCert(t, certPath, keyPath, "vpn.example.com", 10*24*time.Hour)
+		}, 1, 1, false},
+		{"cert for other ip", "203.0.113.5", 30 * 24 * time.Hour, func(certPath, keyPath string) {
+			writeTestCert(t, certPath, keyPath, "203.0.113.6", 90*24*time.Hour)
+		}, 1, 0, false},
+		// Порог больше срока нового сертификата: перевыпуск не помогает,
+		// при второй проверке выпущенный сертификат уже истекает
+		{"threshold beyond validity", "vpn.example.com", 20 * 365 * 24 * time.Hour, nil, 2, 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry, dir := testRegistry(t, tt.server, tt.before)
+			certPath, keyPath := filepath.Join(dir, "server-cert.pem"), filepath.Join(dir, "server-key.pem")
+			if tt.prepare != nil {
+				tt.prepare(certPath, keyPath)
+			}
+
+			issuer := &stubIssuer{}
+			renewer := NewRenewer(registry, issuer)
+			renewed, failed, expiring := 0, 0, 0
+			renewer.OnRenew(func(CertStatus) { renewed++ })
+			renewer.OnFailure(func(CertStatus) { failed++ })
+			renewer.OnExpiring(func(status CertStatus) {
+				expiring++
+				if status.NotAfter.IsZero() || status.Err != nil {
+					t.Errorf("OnExpiring with status %+v", status)
+				}
+			})
+
+			for i := 0; i < 2; i++ {
+				status, err := renewer.Check()
+				if tt.fails {
+					if err == nil || status.Err == nil {
+						t.Errorf("check %d: unusable renewal reported as success", i)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatalf("check %d: %v", i, err)
+				}
+				if !status.KeyMatches || !status.CoversServer || status.NeedsRenewal(tt.before) {
+					t.Errorf("check %d: unusable certificate: %+v", i, status)
+				}
+			}
+
+			if issuer.calls != tt.issues {
+				t.Errorf("issued %d times, want %d", issuer.calls, tt.issues)
+			}
+			wantRenewed, wantFailed := tt.issues, 0
+			if tt.fails {
+				wantRenewed, wantFailed = 0, tt.issues
+			}
+			if renewed != wantRenewed || failed != wantFailed {
+				t.Errorf("OnRenew %d, OnFailure %d; want %d, %d", renewed, failed, wantRenewed, wantFailed)
+			}
+			if expiring != tt.expiring {
+				t.Errorf("OnExpiring %d, want %d", expiring, tt.expiring)
+			}
+			if status := renewer.Status(); status.CheckedAt.IsZero() || (status.Err != nil) != tt.fails {
+				t.Errorf("Status() = %+v", status)
+			}
+		})
+	}
+}
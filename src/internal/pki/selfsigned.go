@@ -0,0 +1,58 @@
+package pki
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"eidolonVPN/internal/config/structures"
+	"eidolonVPN/internal/errors"
+	"net"
+	"time"
+)
+
+// Срок действия самоподписанного серверного сертификата
+const selfSignedValidity = 10 * 365 * 24 * time.Hour // 10 лет
+
+// ServerCertIssuer выпускает серверный сертификат ocserv
+type ServerCertIssuer interface {
+	IssueServerCert(ocConfig structures.OpenConnectConfig, certPath, keyPath string) error
+}
+
+// SelfSignedIssuer выпускает самоподписанный серверный сертификат без CA
+type SelfSignedIssuer struct{}
+
+// IssueServerCert выпускает самоподписанный сертификат (CA и серверный сертификат в одном)
+func (SelfSignedIssuer) IssueServerCert(ocConfig structures.OpenConnectConfig, certPath, keyPath string) error {
+	key, err := generateKey(ocConfig.Security.ServerKey)
+	if err != nil {
+		return err
+	}
+	serial, err := newSerial()
+	if err != nil {
+		return err
+	}
+
+	notBefore := time.Now()
+	template := x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			Organization: []string{ocConfig.Name},
+			CommonName:   ocConfig.Server,
+		},
+		NotBefore: notBefore,
+		NotAfter:  notBefore.Add(selfSignedValidity),
+
+		KeyUsage:              keyUsage(key),
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		DNSNames:              []string{"localhost"},
+	}
+	addServerSAN(&template, ocConfig.Server)
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, key.Public(), key)
+	if err != nil {
+		return errors.CallPKIError("Failed to create self-signed certificate", err)
+	}
+	return writeKeyPair(certPath, keyPath, der, key)
+}
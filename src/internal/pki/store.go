@@ -0,0 +1,111 @@
+package pki
+
+import (
+	"context"
+	"eidolonVPN/internal/errors"
+	"eidolonVPN/internal/storage"
+	"fmt"
+	"os"
+)
+
+// DBRevocationStore хранит отзывы в таблице certificates базы eidolon:
+// отозванным считается сертификат с заполненным revoked_at
+type DBRevocationStore struct {
+	db *storage.DB
+}
+
+// NewDBRevocationStore создает хранилище отзывов поверх базы
+func NewDBRevocationStore(db *storage.DB) *DBRevocationStore {
+	return &DBRevocationStore{db: db}
+}
+
+// AddRevoked отмечает сертификат отозванным. Сертификат, выпущенный до появления
+// базы, добавляется в нее сразу отозванным
+func (s *DBRevocationStore) AddRevoked(revoked RevokedCert) error {
+	ctx := context.Background()
+	return s.db.InTx(ctx, func(tx *storage.Tx) error {
+		existing, err := tx.Certs().GetBySerial(ctx, revoked.Serial)
+		if err == nil {
+			if existing.RevokedAt != nil {
+				return nil
+			}
+			return tx.Certs().Revoke(ctx, revoked.Serial, revoked.Reason)
+		}
+		if err != storage.ErrNotFound {
+			return err
+		}
+
+		user, err := legacyUser(ctx, tx, revoked.UserID)
+		if err != nil {
+			return err
+		}
+		revokedAt := revoked.RevokedAt
+		// Срок действия неизвестен: запись нужна только для CRL
+		return tx.Certs().Add(ctx, &storage.IssuedCert{
+			UserID:    user.ID,
+			Serial:    revoked.Serial,
+			NotAfter:  revoked.RevokedAt,
+			IssuedAt:  revoked.RevokedAt,
+			RevokedAt: &revokedAt,
+			Reason:    revoked.Reason,
+		})
+	})
+}
+
+// ListRevoked возвращает все отозванные сертификаты
+func (s *DBRevocationStore) ListRevoked() ([]RevokedCert, error) {
+	certs, err := s.db.Certs().ListRevoked(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]RevokedCert, 0, len(certs))
+	for _, cert := range certs {
+		list = append(list, RevokedCert{
+			Serial:    cert.Serial,
+			UserID:    cert.Username,
+			RevokedAt: *cert.RevokedAt,
+			Reason:    cert.Reason,
+		})
+	}
+	return list, nil
+}
+
+// ImportFileRevocations переносит отзывы из прежнего revoked.json в базу.
+// После успешного переноса файл переименовывается в *.imported
+func (s *DBRevocationStore) ImportFileRevocations(path string) (int, error) {
+	legacy, err := NewFileRevocationStore(path).ListRevoked()
+	if err != nil || len(legacy) == 0 {
+		return 0, err
+	}
+
+	for _, revoked := range legacy {
+		err = s.AddRevoked(revoked)
+		if err != nil {
+			return 0, errors.CallPKIError(fmt.Sprintf("Failed to import revocation of %s", revoked.Serial), err)
+		}
+	}
+
+	err = os.Rename(path, path+".imported")
+	if err != nil {
+		return len(legacy), errors.CallPKIError(fmt.Sprintf("Failed to rename %s", path), err)
+	}
+	return len(legacy), nil
+}
+
+// legacyUser возвращает пользователя по имени, создавая заблокированную запись для
+// владельцев сертификатов, выпущенных до появления базы. Без пароля такой
+// пользователь не попадает в файл паролей
+func legacyUser(ctx context.Context, tx *storage.Tx, username string) (*storage.User, error) {
+	user, err := tx.Users().GetByUsername(ctx, username)
+	if err != storage.ErrNotFound {
+		return user, err
+	}
+
+	user = &storage.User{Username: username, Status: storage.UserLocked}
+	err = tx.Users().Create(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
@@ -0,0 +1,212 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"eidolonVPN/internal/config"
+	"eidolonVPN/internal/errors"
+	"eidolonVPN/internal/storage"
+	"eidolonVPN/internal/utils"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Как часто сверять каталог с базой на случай изменений в обход Sync
+const syncInterval = time.Minute
+
+// Service хранит персональные маршруты пользователей и раскладывает их
+// в каталог config-per-user ocserv. Файлы, сгенерированные для пользователей
+// без настроек, удаляются; файлы оператора не трогаются. ocserv читает файл пользователя
+// при каждом подключении, поэтому изменения применяются без перезапуска
+type Service struct {
+	registry *config.Registry
+	db       *storage.DB
+	mutex    sync.Mutex
+}
+
+// NewService создает сервис персональных маршрутов
+func NewService(registry *config.Registry, db *storage.DB) *Service {
+	return &Service{registry: registry, db: db}
+}
+
+// Enabled сообщает, задан ли каталог config-per-user
+func (s *Service) Enabled() bool {
+	return s.dir() != ""
+}
+
+// Get возвращает настройки пользователя; при их отсутствии - пустые
+func (s *Service) Get(ctx context.Context, username string) (*storage.UserRoutes, error) {
+	user, err := s.db.Users().GetByUsername(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	routes, err := s.db.Routes().Get(ctx, user.ID)
+	if err == storage.ErrNotFound {
+		return &storage.UserRoutes{UserID: user.ID, Username: user.Username}, nil
+	}
+	return routes, err
+}
+
+// List возвращает настройки всех пользователей, у которых они есть
+func (s *Service) List(ctx context.Context) ([]storage.UserRoutes, error) {
+	return s.db.Routes().List(ctx)
+}
+
+// Set заменяет настройки пользователя, пишет журнал и обновляет файл пользователя
+func (s *Service) Set(ctx context.Context, username string, routes storage.UserRoutes, actor string) (*storage.UserRoutes, error) {
+	err := Validate(routes)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.InTx(ctx, func(tx *storage.Tx) error {
+		user, err := tx.Users().GetByUsername(ctx, username)
+		if err != nil {
+			return err
+		}
+		routes.UserID, routes.Username = user.ID, user.Username
+		err = tx.Routes().Set(ctx, &routes)
+		if err != nil {
+			return err
+		}
+		return tx.Audit().Record(ctx, &storage.AuditEvent{
+			Actor:   actor,
+			Action:  "routes.set",
+			Target:  user.Username,
+			Details: Summary(routes),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.Sync(ctx)
+	return &routes, err
+}
+
+// Update меняет настройки пользователя функцией change поверх текущих
+func (s *Service) Update(ctx context.Context, username, actor string, change func(r *storage.UserRoutes)) (*storage.UserRoutes, error) {
+	routes, err := s.Get(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	change(routes)
+	return s.Set(ctx, username, *routes, actor)
+}
+
+// Clear удаляет настройки пользователя
+func (s *Service) Clear(ctx context.Context, username, actor string) error {
+	_, err := s.Set(ctx, username, storage.UserRoutes{}, actor)
+	return err
+}
+
+// Sync приводит каталог config-per-user в соответствие с базой.
+// Возвращает true, если какой-либо файл был изменен
+func (s *Service) Sync(ctx context.Context) (bool, error) {
+	dir := s.dir()
+	if dir == "" {
+		return false, nil
+	}
+
+	list, err := s.db.Routes().List(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	files := map[string][]byte{}
+	for _, routes := range list {
+		if !utils.ValidFileName(routes.Username) {
+			fmt.Printf("Skipping routes of user %q: name is not usable as file name\n", routes.Username)
+			continue
+		}
+		files[routes.Username] = Render(routes)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	changed, err := utils.SyncDir(dir, files)
+	if err != nil {
+		return changed, errors.CallRoutesError("Failed to sync config-per-user", err)
+	}
+	return changed, nil
+}
+
+// Run сверяет каталог с базой сразу и затем периодически до отмены контекста
+func (s *Service) Run(ctx context.Context) {
+	for {
+		_, err := s.Sync(ctx)
+		if err != nil {
+			fmt.Printf("Routes sync failed: %v\n", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(syncInterval):
+		}
+	}
+}
+
+// dir - каталог config-per-user из текущего снимка конфигов
+func (s *Service) dir() string {
+	return s.registry.Current().OpenConnect.Network.ConfigPerUser
+}
+
+// Validate проверяет значения перед сохранением
+func Validate(routes storage.UserRoutes) error {
+	for _, route := range append(append([]string(nil), routes.Routes...), routes.NoRoutes...) {
+		if !config.ValidRoute(route) {
+			return errors.CallRoutesError(fmt.Sprintf("%q is not a valid route", route), nil)
+		}
+	}
+	for _, route := range routes.NoRoutes {
+		if route == "default" {
+			return errors.CallRoutesError("no-route cannot be default", nil)
+		}
+	}
+	for _, server := range routes.DNS {
+		if net.ParseIP(server) == nil {
+			return errors.CallRoutesError(fmt.Sprintf("%q is not a valid DNS server", server), nil)
+		}
+	}
+	if routes.IPv4Network != "" {
+		ip, _, err := net.ParseCIDR(routes.IPv4Network)
+		if err != nil || ip.To4() == nil {
+			return errors.CallRoutesError(fmt.Sprintf("%q is not a valid IPv4 network", routes.IPv4Network), nil)
+		}
+	}
+	return nil
+}
+
+// Render возвращает содержимое файла config-per-user
+func Render(routes storage.UserRoutes) []byte {
+	var b bytes.Buffer
+	b.WriteString(utils.GeneratedHeader)
+	if routes.IPv4Network != "" {
+		b.WriteString(fmt.Sprintf("ipv4-network = %s\n", routes.IPv4Network))
+	}
+	for _, server := range routes.DNS {
+		b.WriteString(fmt.Sprintf("dns = %s\n", server))
+	}
+	for _, route := range routes.Routes {
+		b.WriteString(fmt.Sprintf("route = %s\n", route))
+	}
+	for _, route := range routes.NoRoutes {
+		b.WriteString(fmt.Sprintf("no-route = %s\n", route))
+	}
+	return b.Bytes()
+}
+
+// Summary - краткое описание настроек для журнала
+func Summary(routes storage.UserRoutes) string {
+	if routes.Empty() {
+		return "cleared"
+	}
+	return fmt.Sprintf("route=%s no-route=%s dns=%s ipv4-network=%s",
+		strings.Join(routes.Routes, ","), strings.Join(routes.NoRoutes, ","),
+		strings.Join(routes.DNS, ","), routes.IPv4Network)
+}
@@ -0,0 +1,33 @@
+package routes
+
+import (
+	"eidolonVPN/internal/storage"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		routes storage.UserRoutes
+		valid  bool
+	}{
+		{"empty", storage.UserRoutes{}, true},
+		{"full", storage.UserRoutes{
+			Routes: []string{"default", "10.0.0.0/8"}, NoRoutes: []string{"10.1.0.0/16"},
+			DNS: []string{"1.1.1.1", "2606:4700::1111"}, IPv4Network: "192.168.50.0/24",
+		}, true},
+		{"bad route", storage.UserRoutes{Routes: []string{"10.0.0.0/33"}}, false},
+		{"default no-route", storage.UserRoutes{NoRoutes: []string{"default"}}, false},
+		{"bad dns", storage.UserRoutes{DNS: []string{"dns.example.com"}}, false},
+		{"ipv6 network", storage.UserRoutes{IPv4Network: "fd00::/64"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.routes)
+			if (err == nil) != tt.valid {
+				t.Errorf("Validate = %v, valid %v", err, tt.valid)
+			}
+		})
+	}
+}
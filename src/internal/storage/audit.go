@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"context"
+	"eidolonVPN/internal/errors"
+	"time"
+)
+
+// AuditEvent - запись журнала действий
+type AuditEvent struct {
+	ID      int64
+	Time    time.Time
+	Actor   string // Кто выполнил: admin, telegram:<id>, system
+	Action  string // Например user.create, cert.revoke
+	Target  string
+	Details string
+}
+
+// AuditRepo - репозиторий журнала действий
+type AuditRepo struct {
+	q querier
+}
+
+// Record добавляет событие в журнал
+func (r *AuditRepo) Record(ctx context.Context, event *AuditEvent) error {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	res, err := r.q.ExecContext(ctx, `
+INSERT INTO audit_events (time, actor, action, target, details) VALUES (?, ?, ?, ?, ?)`,
+		toUnix(event.Time), event.Actor, event.Action, event.Target, event.Details)
+	if err != nil {
+		return errors.CallStorageError("Failed to record audit event "+event.Action, err)
+	}
+
+	event.ID, err = res.LastInsertId()
+	if err != nil {
+		return errors.CallStorageError("Failed to read audit event id", err)
+	}
+	return nil
+}
+
+// List возвращает последние события, новые первыми
+func (r *AuditRepo) List(ctx context.Context, limit int) ([]AuditEvent, error) {
+	rows, err := r.q.QueryContext(ctx, `
+SELECT id, time, actor, action, target, details FROM audit_events
+ORDER BY time DESC, id DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, errors.CallStorageError("Failed to list audit events", err)
+	}
+	defer rows.Close()
+
+	var events []AuditEvent
+	for rows.Next() {
+		var (
+			e  AuditEvent
+			at int64
+		)
+		err := rows.Scan(&e.ID, &at, &e.Actor, &e.Action, &e.Target, &e.Details)
+		if err != nil {
+			return nil, queryError("Failed to read audit event", err)
+		}
+		e.Time = fromUnix(at)
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.CallStorageError("Failed to list audit events", err)
+	}
+	return events, nil
+}
@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"context"
+	"eidolonVPN/internal/errors"
+	"fmt"
+	"time"
+)
+
+// IPBan - адрес, с которого запрещено подключаться
+type IPBan struct {
+	IP        string
+	Reason    string
+	CreatedBy string // Автор в формате журнала: telegram:<id>, admin
+	CreatedAt time.Time
+}
+
+// BanRepo - репозиторий заблокированных адресов
+type BanRepo struct {
+	q querier
+}
+
+// Add блокирует адрес; повторная блокировка обновляет причину
+func (r *BanRepo) Add(ctx context.Context, ban *IPBan) error {
+	if ban.CreatedAt.IsZero() {
+		ban.CreatedAt = time.Now()
+	}
+	_, err := r.q.ExecContext(ctx, `
+INSERT INTO ip_bans (ip, reason, created_by, created_at) VALUES (?, ?, ?, ?)
+ON CONFLICT (ip) DO UPDATE SET reason = excluded.reason`,
+		ban.IP, ban.Reason, ban.CreatedBy, toUnix(ban.CreatedAt))
+	if err != nil {
+		return errors.CallStorageError(fmt.Sprintf("Failed to ban %s", ban.IP), err)
+	}
+	return nil
+}
+
+// Remove снимает блокировку; ErrNotFound, если адрес не был заблокирован
+func (r *BanRepo) Remove(ctx context.Context, ip string) error {
+	res, err := r.q.ExecContext(ctx, `DELETE FROM ip_bans WHERE ip = ?`, ip)
+	if err != nil {
+		return errors.CallStorageError(fmt.Sprintf("Failed to unban %s", ip), err)
+	}
+	return expectRow(res)
+}
+
+// List возвращает заблокированные адреса, новые первыми
+func (r *BanRepo) List(ctx context.Context) ([]IPBan, error) {
+	rows, err := r.q.QueryContext(ctx,
+		`SELECT ip, reason, created_by, created_at FROM ip_bans ORDER BY created_at DESC, ip`)
+	if err != nil {
+		return nil, errors.CallStorageError("Failed to list bans", err)
+	}
+	defer rows.Close()
+
+	var bans []IPBan
+	for rows.Next() {
+		var (
+			ban     IPBan
+			created int64
+		)
+		err := rows.Scan(&ban.IP, &ban.Reason, &ban.CreatedBy, &created)
+		if err != nil {
+			return nil, queryError("Failed to read ban", err)
+		}
+		ban.CreatedAt = fromUnix(created)
+		bans = append(bans, ban)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.CallStorageError("Failed to list bans", err)
+	}
+	return bans, nil
+}
@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"eidolonVPN/internal/errors"
+	"fmt"
+	"time"
+)
+
+// IssuedCert - выпущенный клиентский сертификат
+type IssuedCert struct {
+	ID        int64
+	UserID    int64
+	Username  string // Заполняется при чтении
+	Serial    string // Серийный номер в hex
+	NotAfter  time.Time
+	IssuedAt  time.Time
+	RevokedAt *time.Time
+	Reason    int // Код причины отзыва RFC 5280
+}
+
+// CertRepo - репозиторий выпущенных сертификатов
+type CertRepo struct {
+	q querier
+}
+
+const certColumns = `c.id, c.user_id, u.username, c.serial, c.not_after, c.issued_at, c.revoked_at, c.reason`
+
+const certFrom = ` FROM certificates c JOIN users u ON u.id = c.user_id`
+
+// Add сохраняет выпущенный сертификат
+func (r *CertRepo) Add(ctx context.Context, cert *IssuedCert) error {
+	if cert.IssuedAt.IsZero() {
+		cert.IssuedAt = time.Now()
+	}
+	res, err := r.q.ExecContext(ctx, `
+INSERT INTO certificates (user_id, serial, not_after, issued_at, revoked_at, reason)
+VALUES (?, ?, ?, ?, ?, ?)`,
+		cert.UserID, cert.Serial, toUnix(cert.NotAfter), toUnix(cert.IssuedAt),
+		toNullUnix(cert.RevokedAt), cert.Reason)
+	if err != nil {
+		return errors.CallStorageError(fmt.Sprintf("Failed to add certificate %s", cert.Serial), err)
+	}
+
+	cert.ID, err = res.LastInsertId()
+	if err != nil {
+		return errors.CallStorageError("Failed to read certificate id", err)
+	}
+	return nil
+}
+
+// GetBySerial возвращает сертификат по серийному номеру
+func (r *CertRepo) GetBySerial(ctx context.Context, serial string) (*IssuedCert, error) {
+	row := r.q.QueryRowContext(ctx, `SELECT `+certColumns+certFrom+` WHERE c.serial = ?`, serial)
+	return scanCert(row)
+}
+
+// ListByUser возвращает сертификаты пользователя, новые первыми
+func (r *CertRepo) ListByUser(ctx context.Context, userID int64) ([]IssuedCert, error) {
+	return r.list(ctx, `WHERE c.user_id = ? ORDER BY c.issued_at DESC, c.id DESC`, userID)
+}
+
+// ListRevoked возвращает все отозванные сертификаты
+func (r *CertRepo) ListRevoked(ctx context.Context) ([]IssuedCert, error) {
+	return r.list(ctx, `WHERE c.revoked_at IS NOT NULL ORDER BY c.revoked_at`)
+}
+
+// ListExpiring возвращает действующие сертификаты, истекающие до указанного времени
+func (r *CertRepo) ListExpiring(ctx context.Context, before time.Time) ([]IssuedCert, error) {
+	return r.list(ctx, `WHERE c.revoked_at IS NULL AND c.not_after < ? ORDER BY c.not_after`, toUnix(before))
+}
+
+// Revoke отмечает сертификат отозванным
+func (r *CertRepo) Revoke(ctx context.Context, serial string, reason int) error {
+	res, err := r.q.ExecContext(ctx,
+		`UPDATE certificates SET revoked_at = ?, reason = ? WHERE serial = ? AND revoked_at IS NULL`,
+		toUnix(time.Now()), reason, serial)
+	if err != nil {
+		return errors.CallStorageError(fmt.Sprintf("Failed to revoke certificate %s", serial), err)
+	}
+	return expectRow(res)
+}
+
+// list читает сертификаты по условию
+func (r *CertRepo) list(ctx context.Context, where string, args ...any) ([]IssuedCert, error) {
+	rows, err := r.q.QueryContext(ctx, `SELECT `+certColumns+certFrom+` `+where, args...)
+	if err != nil {
+		return nil, errors.CallStorageError("Failed to list certificates", err)
+	}
+	defer rows.Close()
+
+	var certs []IssuedCert
+	for rows.Next() {
+		cert, err := scanCert(rows)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, *cert)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.CallStorageError("Failed to list certificates", err)
+	}
+	return certs, nil
+}
+
+// scanCert разбирает строку сертификата
+func scanCert(row scanner) (*IssuedCert, error) {
+	var (
+		cert             IssuedCert
+		notAfter, issued int64
+		revoked          sql.NullInt64
+	)
+	err := row.Scan(&cert.ID, &cert.UserID, &cert.Username, &cert.Serial, &notAfter, &issued, &revoked, &cert.Reason)
+	if err != nil {
+		return nil, queryError("Failed to read certificate", err)
+	}
+	cert.NotAfter = fromUnix(notAfter)
+	cert.IssuedAt = fromUnix(issued)
+	cert.RevokedAt = fromNullUnix(revoked)
+	return &cert, nil
+}
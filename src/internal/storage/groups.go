@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"context"
+	"eidolonVPN/internal/errors"
+	"fmt"
+	"time"
+)
+
+// Group - группа пользователей (поле group в ocpasswd, config-per-group в ocserv)
+type Group struct {
+	ID          int64
+	Name        string
+	Description string
+	CreatedAt   time.Time
+}
+
+// GroupRepo - репозиторий групп
+type GroupRepo struct {
+	q querier
+}
+
+// Create добавляет группу
+func (r *GroupRepo) Create(ctx context.Context, group *Group) error {
+	now := time.Now()
+	res, err := r.q.ExecContext(ctx, `INSERT INTO groups (name, description, created_at) VALUES (?, ?, ?)`,
+		group.Name, group.Description, toUnix(now))
+	if err != nil {
+		return errors.CallStorageError(fmt.Sprintf("Failed to create group %s", group.Name), err)
+	}
+
+	group.ID, err = res.LastInsertId()
+	if err != nil {
+		return errors.CallStorageError("Failed to read group id", err)
+	}
+	group.CreatedAt = fromUnix(toUnix(now))
+	return nil
+}
+
+// Get возвращает группу по ID
+func (r *GroupRepo) Get(ctx context.Context, id int64) (*Group, error) {
+	return r.one(ctx, `WHERE id = ?`, id)
+}
+
+// GetByName возвращает группу по имени
+func (r *GroupRepo) GetByName(ctx context.Context, name string) (*Group, error) {
+	return r.one(ctx, `WHERE name = ?`, name)
+}
+
+// List возвращает все группы по имени
+func (r *GroupRepo) List(ctx context.Context) ([]Group, error) {
+	rows, err := r.q.QueryContext(ctx, `SELECT id, name, description, created_at FROM groups ORDER BY name`)
+	if err != nil {
+		return nil, errors.CallStorageError("Failed to list groups", err)
+	}
+	defer rows.Close()
+
+	var groups []Group
+	for rows.Next() {
+		group, err := scanGroup(rows)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, *group)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.CallStorageError("Failed to list groups", err)
+	}
+	return groups, nil
+}
+
+// Update сохраняет описание группы
+func (r *GroupRepo) Update(ctx context.Context, group *Group) error {
+	res, err := r.q.ExecContext(ctx, `UPDATE groups SET description = ? WHERE id = ?`, group.Description, group.ID)
+	if err != nil {
+		return errors.CallStorageError(fmt.Sprintf("Failed to update group %s", group.Name), err)
+	}
+	return expectRow(res)
+}
+
+// Delete удаляет группу; пользователи остаются без группы
+func (r *GroupRepo) Delete(ctx context.Context, id int64) error {
+	res, err := r.q.ExecContext(ctx, `DELETE FROM groups WHERE id = ?`, id)
+	if err != nil {
+		return errors.CallStorageError(fmt.Sprintf("Failed to delete group %d", id), err)
+	}
+	return expectRow(res)
+}
+
+// one читает одну группу по условию
+func (r *GroupRepo) one(ctx context.Context, where string, args ...any) (*Group, error) {
+	row := r.q.QueryRowContext(ctx, `SELECT id, name, description, created_at FROM groups `+where, args...)
+	return scanGroup(row)
+}
+
+// scanGroup разбирает строку группы
+func scanGroup(row scanner) (*Group, error) {
+	var (
+		group   Group
+		created int64
+	)
+	err := row.Scan(&group.ID, &group.Name, &group.Description, &created)
+	if err != nil {
+		return nil, queryError("Failed to read group", err)
+	}
+	group.CreatedAt = fromUnix(created)
+	return &group, nil
+}
@@ -0,0 +1,214 @@
+package storage
+
+import (
+	"context"
+	"eidolonVPN/internal/errors"
+	"fmt"
+	"time"
+)
+
+// migration - версия схемы; уже примененные миграции не меняются, только добавляются новые
+type migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+var migrations = []migration{
+	{
+		Version: 1,
+		Name:    "initial schema",
+		SQL: `
+CREATE TABLE groups (
+	id          INTEGER PRIMARY KEY,
+	name        TEXT NOT NULL UNIQUE,
+	description TEXT NOT NULL DEFAULT '',
+	created_at  INTEGER NOT NULL
+);
+
+CREATE TABLE users (
+	id            INTEGER PRIMARY KEY,
+	username      TEXT NOT NULL UNIQUE,
+	group_id      INTEGER REFERENCES groups(id) ON DELETE SET NULL,
+	password_hash TEXT NOT NULL DEFAULT '',
+	telegram_id   INTEGER,
+	status        TEXT NOT NULL DEFAULT 'active',
+	created_at    INTEGER NOT NULL,
+	updated_at    INTEGER NOT NULL
+);
+CREATE UNIQUE INDEX users_telegram_id ON users(telegram_id) WHERE telegram_id IS NOT NULL;
+
+CREATE TABLE certificates (
+	id         INTEGER PRIMARY KEY,
+	user_id    INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	serial     TEXT NOT NULL UNIQUE,
+	not_after  INTEGER NOT NULL,
+	issued_at  INTEGER NOT NULL,
+	revoked_at INTEGER,
+	reason     INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX certificates_user ON certificates(user_id);
+
+CREATE TABLE sessions (
+	id              INTEGER PRIMARY KEY,
+	user_id         INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	remote_ip       TEXT NOT NULL DEFAULT '',
+	vpn_ip          TEXT NOT NULL DEFAULT '',
+	device          TEXT NOT NULL DEFAULT '',
+	connected_at    INTEGER NOT NULL,
+	disconnected_at INTEGER,
+	rx_bytes        INTEGER NOT NULL DEFAULT 0,
+	tx_bytes        INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX sessions_user ON sessions(user_id, connected_at);
+CREATE INDEX sessions_active ON sessions(disconnected_at) WHERE disconnected_at IS NULL;
+
+CREATE TABLE traffic (
+	user_id  INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	day      TEXT NOT NULL,
+	rx_bytes INTEGER NOT NULL DEFAULT 0,
+	tx_bytes INTEGER NOT NULL DEFAULT 0,
+	PRIMARY KEY (user_id, day)
+);
+
+CREATE TABLE audit_events (
+	id      INTEGER PRIMARY KEY,
+	time    INTEGER NOT NULL,
+	actor   TEXT NOT NULL,
+	action  TEXT NOT NULL,
+	target  TEXT NOT NULL DEFAULT '',
+	details TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX audit_events_time ON audit_events(time);
+`,
+	},
+	{
+		Version: 2,
+		Name:    "access requests",
+		SQL: `
+CREATE TABLE access_requests (
+	id          INTEGER PRIMARY KEY,
+	telegram_id INTEGER NOT NULL,
+	chat_id     INTEGER NOT NULL,
+	name        TEXT NOT NULL DEFAULT '',
+	username    TEXT NOT NULL,
+	status      TEXT NOT NULL DEFAULT 'pending',
+	created_at  INTEGER NOT NULL,
+	decided_at  INTEGER,
+	decided_by  INTEGER
+);
+CREATE UNIQUE INDEX access_requests_pending ON access_requests(telegram_id) WHERE status = 'pending';
+
+CREATE TABLE access_request_messages (
+	request_id INTEGER NOT NULL REFERENCES access_requests(id) ON DELETE CASCADE,
+	chat_id    INTEGER NOT NULL,
+	message_id INTEGER NOT NULL,
+	PRIMARY KEY (request_id, chat_id)
+);
+`,
+	},
+	{
+		Version: 3,
+		Name:    "ip bans",
+		SQL: `
+CREATE TABLE ip_bans (
+	ip         TEXT PRIMARY KEY,
+	reason     TEXT NOT NULL DEFAULT '',
+	created_by TEXT NOT NULL,
+	created_at INTEGER NOT NULL
+);
+`,
+	},
+	{
+		Version: 4,
+		Name:    "notification preferences",
+		SQL: `
+CREATE TABLE notification_mutes (
+	telegram_id INTEGER NOT NULL,
+	kind        TEXT NOT NULL,
+	PRIMARY KEY (telegram_id, kind)
+);
+`,
+	},
+	{
+		Version: 5,
+		Name:    "user routes",
+		SQL: `
+CREATE TABLE user_routes (
+	user_id      INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+	routes       TEXT NOT NULL DEFAULT '',
+	no_routes    TEXT NOT NULL DEFAULT '',
+	dns          TEXT NOT NULL DEFAULT '',
+	ipv4_network TEXT NOT NULL DEFAULT '',
+	updated_at   INTEGER NOT NULL
+);
+`,
+	},
+	{
+		Version: 6,
+		Name:    "group policies",
+		SQL: `
+CREATE TABLE group_policies (
+	group_id            INTEGER PRIMARY KEY REFERENCES groups(id) ON DELETE CASCADE,
+	routes              TEXT NOT NULL DEFAULT '',
+	no_routes           TEXT NOT NULL DEFAULT '',
+	dns                 TEXT NOT NULL DEFAULT '',
+	rx_per_sec          INTEGER NOT NULL DEFAULT 0,
+	tx_per_sec          INTEGER NOT NULL DEFAULT 0,
+	max_same_clients    INTEGER NOT NULL DEFAULT 0,
+	idle_timeout        INTEGER NOT NULL DEFAULT 0,
+	mobile_idle_timeout INTEGER NOT NULL DEFAULT 0,
+	updated_at          INTEGER NOT NULL
+);
+`,
+	},
+}
+
+// migrate применяет недостающие миграции, каждую в своей транзакции
+func (s *DB) migrate(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+	version    INTEGER PRIMARY KEY,
+	name       TEXT NOT NULL,
+	applied_at INTEGER NOT NULL
+)`)
+	if err != nil {
+		return errors.CallStorageError("Failed to create schema_migrations", err)
+	}
+
+	current, err := s.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if m.Version <= current {
+			continue
+		}
+
+		err = s.InTx(ctx, func(tx *Tx) error {
+			_, err := tx.tx.ExecContext(ctx, m.SQL)
+			if err != nil {
+				return err
+			}
+			_, err = tx.tx.ExecContext(ctx,
+				`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`,
+				m.Version, m.Name, toUnix(time.Now()))
+			return err
+		})
+		if err != nil {
+			return errors.CallStorageError(fmt.Sprintf("Migration %d (%s) failed", m.Version, m.Name), err)
+		}
+	}
+	return nil
+}
+
+// SchemaVersion возвращает номер последней примененной миграции
+func (s *DB) SchemaVersion(ctx context.Context) (int, error) {
+	var version int
+	err := s.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	if err != nil {
+		return 0, errors.CallStorageError("Failed to read schema version", err)
+	}
+	return version, nil
+}
@@ -0,0 +1,162 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// latestVersion - номер последней миграции
+func latestVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// openTestDB открывает новую базу во временном каталоге
+func openTestDB(t *testing.T) *DB {
+	t.Helper()
+
+	db, err := Open(filepath.Join(t.TempDir(), "eidolon.db"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// tableExists проверяет наличие таблицы в схеме
+func tableExists(t *testing.T, db *sql.DB, name string) bool {
+	t.Helper()
+
+	var count int
+	err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, name).Scan(&count)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return count == 1
+}
+
+func TestMigrationsAreSequential(t *testing.T) {
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("migration %d has version %d", i, m.Version)
+		}
+		if m.Name == "" || m.SQL == "" {
+			t.Errorf("migration %d has no name or SQL", m.Version)
+		}
+	}
+}
+
+func TestOpenMigratesFromScratch(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+
+	version, err := db.SchemaVersion(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != latestVersion() {
+		t.Errorf("schema version %d, want %d", version, latestVersion())
+	}
+
+	for _, table := range []string{"groups", "users", "certificates", "sessions", "traffic", "audit_events",
+		"access_requests", "access_request_messages", "ip_bans", "notification_mutes", "user_routes", "group_policies"} {
+		if !tableExists(t, db.SQL(), table) {
+			t.Errorf("table %s is missing", table)
+		}
+	}
+
+	var applied int
+	err = db.SQL().QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&applied)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if applied != len(migrations) {
+		t.Errorf("schema_migrations has %d rows, want %d", applied, len(migrations))
+	}
+}
+
+func TestOpenMigratesFromOlderVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "eidolon.db")
+	ctx := context.Background()
+
+	// База версии 3 с данными, как ее оставила бы старая сборка
+	raw, err := sql.Open("sqlite", "file:"+path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = raw.Exec(`CREATE TABLE schema_migrations (version INTEGER PRIMARY KEY, name TEXT NOT NULL, applied_at INTEGER NOT NULL)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range migrations[:3] {
+		_, err = raw.Exec(m.SQL)
+		if err != nil {
+			t.Fatalf("migration %d: %v", m.Version, err)
+		}
+		_, err = raw.Exec(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`,
+			m.Version, m.Name, time.Now().Unix())
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	now := time.Now().Unix()
+	_, err = raw.Exec(`INSERT INTO groups (name, created_at) VALUES ('staff', ?)`, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = raw.Exec(`INSERT INTO users (username, group_id, password_hash, status, created_at, updated_at)
+VALUES ('alice', 1, '$5$hash', 'active', ?, ?)`, now, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw.Close()
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	version, err := db.SchemaVersion(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != latestVersion() {
+		t.Errorf("schema version %d, want %d", version, latestVersion())
+	}
+
+	// Данные старой версии сохранились, новые таблицы работают
+	user, err := db.Users().GetByUsername(ctx, "alice")
+	if err != nil {
+		t.Fatalf("user lost after migration: %v", err)
+	}
+	if user.Group != "staff" || user.PasswordHash != "$5$hash" {
+		t.Errorf("unexpected user after migration: %+v", user)
+	}
+	err = db.Routes().Set(ctx, &UserRoutes{UserID: user.ID, Routes: []string{"10.0.0.0/8"}})
+	if err != nil {
+		t.Errorf("user_routes after migration: %v", err)
+	}
+	err = db.Policies().Set(ctx, &GroupPolicy{GroupID: *user.GroupID, MaxSameClients: 1})
+	if err != nil {
+		t.Errorf("group_policies after migration: %v", err)
+	}
+
+	// Повторное открытие не применяет миграции заново
+	db.Close()
+	db, err = Open(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer db.Close()
+
+	var applied int
+	err = db.SQL().QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&applied)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if applied != len(migrations) {
+		t.Errorf("schema_migrations has %d rows, want %d", applied, len(migrations))
+	}
+}
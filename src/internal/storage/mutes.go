@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"context"
+	"eidolonVPN/internal/errors"
+	"fmt"
+)
+
+// MuteRepo - отписки администраторов от типов уведомлений; по умолчанию включены все
+type MuteRepo struct {
+	q querier
+}
+
+// List возвращает типы уведомлений, отключенные администратором
+func (r *MuteRepo) List(ctx context.Context, telegramID int64) ([]string, error) {
+	rows, err := r.q.QueryContext(ctx,
+		`SELECT kind FROM notification_mutes WHERE telegram_id = ? ORDER BY kind`, telegramID)
+	if err != nil {
+		return nil, errors.CallStorageError(fmt.Sprintf("Failed to list notification mutes of %d", telegramID), err)
+	}
+	defer rows.Close()
+
+	var kinds []string
+	for rows.Next() {
+		var kind string
+		err := rows.Scan(&kind)
+		if err != nil {
+			return nil, queryError("Failed to read notification mute", err)
+		}
+		kinds = append(kinds, kind)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.CallStorageError(fmt.Sprintf("Failed to list notification mutes of %d", telegramID), err)
+	}
+	return kinds, nil
+}
+
+// Set отключает или включает тип уведомлений для администратора
+func (r *MuteRepo) Set(ctx context.Context, telegramID int64, kind string, muted bool) error {
+	query := `DELETE FROM notification_mutes WHERE telegram_id = ? AND kind = ?`
+	if muted {
+		query = `INSERT OR IGNORE INTO notification_mutes (telegram_id, kind) VALUES (?, ?)`
+	}
+	_, err := r.q.ExecContext(ctx, query, telegramID, kind)
+	if err != nil {
+		return errors.CallStorageError(fmt.Sprintf("Failed to set notification %s for %d", kind, telegramID), err)
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"context"
+	"eidolonVPN/internal/errors"
+	"fmt"
+	"time"
+)
+
+// GroupPolicy - сетевая политика группы для config-per-group ocserv.
+// Нулевые значения ограничений означают настройки сервера
+type GroupPolicy struct {
+	GroupID           int64
+	Group             string   // Имя группы, заполняется при чтении
+	Routes            []string // route
+	NoRoutes          []string // no-route
+	DNS               []string // dns
+	RxPerSec          int64    // rx-data-per-sec, байт/с
+	TxPerSec          int64    // tx-data-per-sec, байт/с
+	MaxSameClients    int      // max-same-clients
+	IdleTimeout       int      // idle-timeout, секунды
+	MobileIdleTimeout int      // mobile-idle-timeout, секунды
+	UpdatedAt         time.Time
+}
+
+// Empty сообщает, что политика ничего не меняет
+func (p GroupPolicy) Empty() bool {
+	return len(p.Routes) == 0 && len(p.NoRoutes) == 0 && len(p.DNS) == 0 &&
+		p.RxPerSec == 0 && p.TxPerSec == 0 && p.MaxSameClients == 0 &&
+		p.IdleTimeout == 0 && p.MobileIdleTimeout == 0
+}
+
+// PolicyRepo - репозиторий политик групп
+type PolicyRepo struct {
+	q querier
+}
+
+const policyColumns = `p.group_id, g.name, p.routes, p.no_routes, p.dns, p.rx_per_sec, p.tx_per_sec,
+	p.max_same_clients, p.idle_timeout, p.mobile_idle_timeout, p.updated_at`
+
+const policyFrom = ` FROM group_policies p JOIN groups g ON g.id = p.group_id`
+
+// Get возвращает политику группы
+func (r *PolicyRepo) Get(ctx context.Context, groupID int64) (*GroupPolicy, error) {
+	row := r.q.QueryRowContext(ctx, `SELECT `+policyColumns+policyFrom+` WHERE p.group_id = ?`, groupID)
+	return scanPolicy(row)
+}
+
+// List возвращает политики всех групп
+func (r *PolicyRepo) List(ctx context.Context) ([]GroupPolicy, error) {
+	rows, err := r.q.QueryContext(ctx, `SELECT `+policyColumns+policyFrom+` ORDER BY g.name`)
+	if err != nil {
+		return nil, errors.CallStorageError("Failed to list group policies", err)
+	}
+	defer rows.Close()
+
+	var policies []GroupPolicy
+	for rows.Next() {
+		policy, err := scanPolicy(rows)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, *policy)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.CallStorageError("Failed to list group policies", err)
+	}
+	return policies, nil
+}
+
+// Set сохраняет политику группы целиком; пустая политика удаляется
+func (r *PolicyRepo) Set(ctx context.Context, policy *GroupPolicy) error {
+	if policy.Empty() {
+		err := r.Delete(ctx, policy.GroupID)
+		if err == ErrNotFound {
+			return nil
+		}
+		return err
+	}
+
+	now := time.Now()
+	_, err := r.q.ExecContext(ctx, `
+INSERT INTO group_policies (group_id, routes, no_routes, dns, rx_per_sec, tx_per_sec,
+	max_same_clients, idle_timeout, mobile_idle_timeout, updated_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (group_id) DO UPDATE SET routes = excluded.routes, no_routes = excluded.no_routes,
+	dns = excluded.dns, rx_per_sec = excluded.rx_per_sec, tx_per_sec = excluded.tx_per_sec,
+	max_same_clients = excluded.max_same_clients, idle_timeout = excluded.idle_timeout,
+	mobile_idle_timeout = excluded.mobile_idle_timeout, updated_at = excluded.updated_at`,
+		policy.GroupID, joinList(policy.Routes), joinList(policy.NoRoutes), joinList(policy.DNS),
+		policy.RxPerSec, policy.TxPerSec, policy.MaxSameClients, policy.IdleTimeout,
+		policy.MobileIdleTimeout, toUnix(now))
+	if err != nil {
+		return errors.CallStorageError(fmt.Sprintf("Failed to save policy of group %d", policy.GroupID), err)
+	}
+	policy.UpdatedAt = fromUnix(toUnix(now))
+	return nil
+}
+
+// Delete удаляет политику группы
+func (r *PolicyRepo) Delete(ctx context.Context, groupID int64) error {
+	res, err := r.q.ExecContext(ctx, `DELETE FROM group_policies WHERE group_id = ?`, groupID)
+	if err != nil {
+		return errors.CallStorageError(fmt.Sprintf("Failed to delete policy of group %d", groupID), err)
+	}
+	return expectRow(res)
+}
+
+// scanPolicy разбирает строку политики
+func scanPolicy(row scanner) (*GroupPolicy, error) {
+	var (
+		policy              GroupPolicy
+		list, noRoutes, dns string
+		updated             int64
+	)
+	err := row.Scan(&policy.GroupID, &policy.Group, &list, &noRoutes, &dns, &policy.RxPerSec, &policy.TxPerSec,
+		&policy.MaxSameClients, &policy.IdleTimeout, &policy.MobileIdleTimeout, &updated)
+	if err != nil {
+		return nil, queryError("Failed to read group policy", err)
+	}
+	policy.Routes = splitList(list)
+	policy.NoRoutes = splitList(noRoutes)
+	policy.DNS = splitList(dns)
+	policy.UpdatedAt = fromUnix(updated)
+	return &policy, nil
+}
@@ -0,0 +1,161 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"eidolonVPN/internal/errors"
+	"fmt"
+	"time"
+)
+
+// Статусы заявки на доступ
+const (
+	RequestPending  = "pending"
+	RequestApproved = "approved"
+	RequestDenied   = "denied"
+)
+
+// AccessRequest - заявка на доступ, поданная через Telegram
+type AccessRequest struct {
+	ID         int64
+	TelegramID int64
+	ChatID     int64  // Личный чат с заявителем для выдачи доступа
+	Name       string // Имя в Telegram для администраторов
+	Username   string // Предлагаемое имя пользователя VPN
+	Status     string
+	CreatedAt  time.Time
+	DecidedAt  *time.Time
+	DecidedBy  int64 // Telegram ID администратора
+}
+
+// RequestMessage - уведомление о заявке, отправленное администратору
+type RequestMessage struct {
+	ChatID    int64
+	MessageID int64
+}
+
+// RequestRepo - репозиторий заявок на доступ
+type RequestRepo struct {
+	q querier
+}
+
+const requestColumns = `id, telegram_id, chat_id, name, username, status, created_at, decided_at, COALESCE(decided_by, 0)`
+
+// Create сохраняет новую заявку; у пользователя может быть только одна заявка на рассмотрении
+func (r *RequestRepo) Create(ctx context.Context, request *AccessRequest) error {
+	request.Status = RequestPending
+	now := time.Now()
+	res, err := r.q.ExecContext(ctx, `
+INSERT INTO access_requests (telegram_id, chat_id, name, username, status, created_at)
+VALUES (?, ?, ?, ?, ?, ?)`,
+		request.TelegramID, request.ChatID, request.Name, request.Username, request.Status, toUnix(now))
+	if err != nil {
+		return errors.CallStorageError(fmt.Sprintf("Failed to create access request for %d", request.TelegramID), err)
+	}
+
+	request.ID, err = res.LastInsertId()
+	if err != nil {
+		return errors.CallStorageError("Failed to read access request id", err)
+	}
+	request.CreatedAt = fromUnix(toUnix(now))
+	return nil
+}
+
+// Get возвращает заявку по ID
+func (r *RequestRepo) Get(ctx context.Context, id int64) (*AccessRequest, error) {
+	row := r.q.QueryRowContext(ctx, `SELECT `+requestColumns+` FROM access_requests WHERE id = ?`, id)
+	return scanRequest(row)
+}
+
+// GetPending возвращает заявку пользователя, ожидающую решения
+func (r *RequestRepo) GetPending(ctx context.Context, telegramID int64) (*AccessRequest, error) {
+	row := r.q.QueryRowContext(ctx, `SELECT `+requestColumns+` FROM access_requests
+WHERE telegram_id = ? AND status = ?`, telegramID, RequestPending)
+	return scanRequest(row)
+}
+
+// ListPending возвращает заявки на рассмотрении, старые первыми
+func (r *RequestRepo) ListPending(ctx context.Context) ([]AccessRequest, error) {
+	rows, err := r.q.QueryContext(ctx, `SELECT `+requestColumns+` FROM access_requests
+WHERE status = ? ORDER BY created_at, id`, RequestPending)
+	if err != nil {
+		return nil, errors.CallStorageError("Failed to list access requests", err)
+	}
+	defer rows.Close()
+
+	var requests []AccessRequest
+	for rows.Next() {
+		request, err := scanRequest(rows)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, *request)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.CallStorageError("Failed to list access requests", err)
+	}
+	return requests, nil
+}
+
+// Decide закрывает заявку. ErrNotFound - заявка уже решена другим администратором
+func (r *RequestRepo) Decide(ctx context.Context, id int64, status string, adminID int64) error {
+	res, err := r.q.ExecContext(ctx, `
+UPDATE access_requests SET status = ?, decided_at = ?, decided_by = ? WHERE id = ? AND status = ?`,
+		status, toUnix(time.Now()), adminID, id, RequestPending)
+	if err != nil {
+		return errors.CallStorageError(fmt.Sprintf("Failed to decide access request %d", id), err)
+	}
+	return expectRow(res)
+}
+
+// AddMessage запоминает уведомление администратору, чтобы потом отметить в нем решение
+func (r *RequestRepo) AddMessage(ctx context.Context, id int64, message RequestMessage) error {
+	_, err := r.q.ExecContext(ctx, `
+INSERT OR REPLACE INTO access_request_messages (request_id, chat_id, message_id) VALUES (?, ?, ?)`,
+		id, message.ChatID, message.MessageID)
+	if err != nil {
+		return errors.CallStorageError(fmt.Sprintf("Failed to save message of access request %d", id), err)
+	}
+	return nil
+}
+
+// Messages возвращает уведомления администраторам о заявке
+func (r *RequestRepo) Messages(ctx context.Context, id int64) ([]RequestMessage, error) {
+	rows, err := r.q.QueryContext(ctx,
+		`SELECT chat_id, message_id FROM access_request_messages WHERE request_id = ?`, id)
+	if err != nil {
+		return nil, errors.CallStorageError(fmt.Sprintf("Failed to list messages of access request %d", id), err)
+	}
+	defer rows.Close()
+
+	var messages []RequestMessage
+	for rows.Next() {
+		var message RequestMessage
+		err := rows.Scan(&message.ChatID, &message.MessageID)
+		if err != nil {
+			return nil, queryError("Failed to read access request message", err)
+		}
+		messages = append(messages, message)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.CallStorageError(fmt.Sprintf("Failed to list messages of access request %d", id), err)
+	}
+	return messages, nil
+}
+
+// scanRequest разбирает строку заявки
+func scanRequest(row scanner) (*AccessRequest, error) {
+	var (
+		request   AccessRequest
+		created   int64
+		decidedAt sql.NullInt64
+	)
+	err := row.Scan(&request.ID, &request.TelegramID, &request.ChatID, &request.Name, &request.Username,
+		&request.Status, &created, &decidedAt, &request.DecidedBy)
+	if err != nil {
+		return nil, queryError("Failed to read access request", err)
+	}
+	request.CreatedAt = fromUnix(created)
+	request.DecidedAt = fromNullUnix(decidedAt)
+	return &request, nil
+}
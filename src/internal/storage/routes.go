@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"context"
+	"eidolonVPN/internal/errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// UserRoutes - персональные сетевые настройки пользователя для config-per-user ocserv
+type UserRoutes struct {
+	UserID      int64
+	Username    string   // Заполняется при чтении
+	Routes      []string // route
+	NoRoutes    []string // no-route
+	DNS         []string // dns
+	IPv4Network string   // ipv4-network в CIDR, пусто - общий пул
+	UpdatedAt   time.Time
+}
+
+// Empty сообщает, что персональных настроек нет
+func (r UserRoutes) Empty() bool {
+	return len(r.Routes) == 0 && len(r.NoRoutes) == 0 && len(r.DNS) == 0 && r.IPv4Network == ""
+}
+
+// RouteRepo - репозиторий персональных маршрутов
+type RouteRepo struct {
+	q querier
+}
+
+const routeColumns = `r.user_id, u.username, r.routes, r.no_routes, r.dns, r.ipv4_network, r.updated_at`
+
+const routeFrom = ` FROM user_routes r JOIN users u ON u.id = r.user_id`
+
+// Get возвращает настройки пользователя
+func (r *RouteRepo) Get(ctx context.Context, userID int64) (*UserRoutes, error) {
+	row := r.q.QueryRowContext(ctx, `SELECT `+routeColumns+routeFrom+` WHERE r.user_id = ?`, userID)
+	return scanRoutes(row)
+}
+
+// List возвращает настройки всех пользователей
+func (r *RouteRepo) List(ctx context.Context) ([]UserRoutes, error) {
+	rows, err := r.q.QueryContext(ctx, `SELECT `+routeColumns+routeFrom+` ORDER BY u.username`)
+	if err != nil {
+		return nil, errors.CallStorageError("Failed to list user routes", err)
+	}
+	defer rows.Close()
+
+	var list []UserRoutes
+	for rows.Next() {
+		routes, err := scanRoutes(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *routes)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.CallStorageError("Failed to list user routes", err)
+	}
+	return list, nil
+}
+
+// Set сохраняет настройки пользователя целиком; пустые настройки удаляются
+func (r *RouteRepo) Set(ctx context.Context, routes *UserRoutes) error {
+	if routes.Empty() {
+		err := r.Delete(ctx, routes.UserID)
+		if err == ErrNotFound {
+			return nil
+		}
+		return err
+	}
+
+	now := time.Now()
+	_, err := r.q.ExecContext(ctx, `
+INSERT INTO user_routes (user_id, routes, no_routes, dns, ipv4_network, updated_at)
+VALUES (?, ?, ?, ?, ?, ?)
+ON CONFLICT (user_id) DO UPDATE SET routes = excluded.routes, no_routes = excluded.no_routes,
+	dns = excluded.dns, ipv4_network = excluded.ipv4_network, updated_at = excluded.updated_at`,
+		routes.UserID, joinList(routes.Routes), joinList(routes.NoRoutes), joinList(routes.DNS),
+		routes.IPv4Network, toUnix(now))
+	if err != nil {
+		return errors.CallStorageError(fmt.Sprintf("Failed to save routes of user %d", routes.UserID), err)
+	}
+	routes.UpdatedAt = fromUnix(toUnix(now))
+	return nil
+}
+
+// Delete удаляет настройки пользователя
+func (r *RouteRepo) Delete(ctx context.Context, userID int64) error {
+	res, err := r.q.ExecContext(ctx, `DELETE FROM user_routes WHERE user_id = ?`, userID)
+	if err != nil {
+		return errors.CallStorageError(fmt.Sprintf("Failed to delete routes of user %d", userID), err)
+	}
+	return expectRow(res)
+}
+
+// scanRoutes разбирает строку настроек
+func scanRoutes(row scanner) (*UserRoutes, error) {
+	var (
+		routes              UserRoutes
+		list, noRoutes, dns string
+		updated             int64
+	)
+	err := row.Scan(&routes.UserID, &routes.Username, &list, &noRoutes, &dns, &routes.IPv4Network, &updated)
+	if err != nil {
+		return nil, queryError("Failed to read user routes", err)
+	}
+	routes.Routes = splitList(list)
+	routes.NoRoutes = splitList(noRoutes)
+	routes.DNS = splitList(dns)
+	routes.UpdatedAt = fromUnix(updated)
+	return &routes, nil
+}
+
+// joinList хранит список строкой через перевод строки
+func joinList(values []string) string {
+	return strings.Join(values, "\n")
+}
+
+// splitList разбирает список, сохраненный joinList
+func splitList(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, "\n")
+}
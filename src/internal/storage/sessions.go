@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"eidolonVPN/internal/errors"
+	"fmt"
+	"time"
+)
+
+// Session - VPN сессия пользователя
+type Session struct {
+	ID             int64
+	UserID         int64
+	Username       string // Заполняется при чтении
+	RemoteIP       string
+	VPNIP          string
+	Device         string
+	ConnectedAt    time.Time
+	DisconnectedAt *time.Time // nil - сессия активна
+	RxBytes        int64
+	TxBytes        int64
+}
+
+// SessionRepo - репозиторий сессий
+type SessionRepo struct {
+	q querier
+}
+
+const sessionColumns = `s.id, s.user_id, u.username, s.remote_ip, s.vpn_ip, s.device,
+	s.connected_at, s.disconnected_at, s.rx_bytes, s.tx_bytes`
+
+const sessionFrom = ` FROM sessions s JOIN users u ON u.id = s.user_id`
+
+// Open сохраняет начало сессии
+func (r *SessionRepo) Open(ctx context.Context, session *Session) error {
+	if session.ConnectedAt.IsZero() {
+		session.ConnectedAt = time.Now()
+	}
+	res, err := r.q.ExecContext(ctx, `
+INSERT INTO sessions (user_id, remote_ip, vpn_ip, device, connected_at)
+VALUES (?, ?, ?, ?, ?)`,
+		session.UserID, session.RemoteIP, session.VPNIP, session.Device, toUnix(session.ConnectedAt))
+	if err != nil {
+		return errors.CallStorageError(fmt.Sprintf("Failed to open session of user %d", session.UserID), err)
+	}
+
+	session.ID, err = res.LastInsertId()
+	if err != nil {
+		return errors.CallStorageError("Failed to read session id", err)
+	}
+	return nil
+}
+
+// Close завершает сессию с итоговым трафиком
+func (r *SessionRepo) Close(ctx context.Context, id int64, rxBytes, txBytes int64) error {
+	res, err := r.q.ExecContext(ctx, `
+UPDATE sessions SET disconnected_at = ?, rx_bytes = ?, tx_bytes = ?
+WHERE id = ? AND disconnected_at IS NULL`,
+		toUnix(time.Now()), rxBytes, txBytes, id)
+	if err != nil {
+		return errors.CallStorageError(fmt.Sprintf("Failed to close session %d", id), err)
+	}
+	return expectRow(res)
+}
+
+// ListActive возвращает незавершенные сессии
+func (r *SessionRepo) ListActive(ctx context.Context) ([]Session, error) {
+	return r.list(ctx, `WHERE s.disconnected_at IS NULL ORDER BY s.connected_at`)
+}
+
+// ListByUser возвращает последние сессии пользователя
+func (r *SessionRepo) ListByUser(ctx context.Context, userID int64, limit int) ([]Session, error) {
+	return r.list(ctx, `WHERE s.user_id = ? ORDER BY s.connected_at DESC LIMIT ?`, userID, limit)
+}
+
+// list читает сессии по условию
+func (r *SessionRepo) list(ctx context.Context, where string, args ...any) ([]Session, error) {
+	rows, err := r.q.QueryContext(ctx, `SELECT `+sessionColumns+sessionFrom+` `+where, args...)
+	if err != nil {
+		return nil, errors.CallStorageError("Failed to list sessions", err)
+	}
+	defer rows.Close()
+
+	var sessions []Session
+	for rows.Next() {
+		var (
+			s            Session
+			connected    int64
+			disconnected sql.NullInt64
+		)
+		err := rows.Scan(&s.ID, &s.UserID, &s.Username, &s.RemoteIP, &s.VPNIP, &s.Device,
+			&connected, &disconnected, &s.RxBytes, &s.TxBytes)
+		if err != nil {
+			return nil, queryError("Failed to read session", err)
+		}
+		s.ConnectedAt = fromUnix(connected)
+		s.DisconnectedAt = fromNullUnix(disconnected)
+		sessions = append(sessions, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.CallStorageError("Failed to list sessions", err)
+	}
+	return sessions, nil
+}
@@ -0,0 +1,205 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"eidolonVPN/internal/errors"
+	stderrors "errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "modernc.org/sqlite" // Pure-Go драйвер SQLite, сборка с CGO_ENABLED=0
+)
+
+// ErrNotFound возвращается, когда запись не найдена
+var ErrNotFound = stderrors.New("record not found")
+
+// Сколько ждать снятия блокировки записи другим соединением
+const busyTimeout = 5 * time.Second
+
+// querier - общее для *sql.DB и *sql.Tx, чтобы репозитории работали и внутри транзакций
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// DB - база данных eidolon (StorageConfig.DatabasePath)
+type DB struct {
+	db   *sql.DB
+	path string
+}
+
+// Open открывает базу в режиме WAL и применяет миграции схемы
+func Open(path string) (*DB, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return nil, errors.CallStorageError(fmt.Sprintf("Failed to create directory for %s", path), err)
+	}
+
+	// Прагмы задаются в DSN, чтобы применяться к каждому соединению пула
+	params := url.Values{}
+	params.Add("_pragma", "journal_mode(WAL)")
+	params.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", busyTimeout.Milliseconds()))
+	params.Add("_pragma", "foreign_keys(1)")
+	params.Add("_pragma", "synchronous(NORMAL)")
+	params.Set("_txlock", "immediate")
+
+	db, err := sql.Open("sqlite", "file:"+path+"?"+params.Encode())
+	if err != nil {
+		return nil, errors.CallStorageError(fmt.Sprintf("Failed to open database %s", path), err)
+	}
+
+	s := &DB{db: db, path: path}
+	err = s.migrate(context.Background())
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Close закрывает базу
+func (s *DB) Close() error {
+	return s.db.Close()
+}
+
+// Path возвращает путь к файлу базы
+func (s *DB) Path() string {
+	return s.path
+}
+
+// SQL возвращает соединение для операций вне репозиториев (например, резервного копирования)
+func (s *DB) SQL() *sql.DB {
+	return s.db
+}
+
+// Tx - транзакция с теми же репозиториями, что и у DB
+type Tx struct {
+	tx *sql.Tx
+}
+
+// InTx выполняет fn в транзакции: фиксирует при nil и откатывает при ошибке
+func (s *DB) InTx(ctx context.Context, fn func(tx *Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.CallStorageError("Failed to begin transaction", err)
+	}
+
+	err = fn(&Tx{tx: tx})
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return errors.CallStorageError("Failed to commit transaction", err)
+	}
+	return nil
+}
+
+// Репозитории вне транзакции
+func (s *DB) Users() *UserRepo       { return &UserRepo{q: s.db} }
+func (s *DB) Groups() *GroupRepo     { return &GroupRepo{q: s.db} }
+func (s *DB) Certs() *CertRepo       { return &CertRepo{q: s.db} }
+func (s *DB) Sessions() *SessionRepo { return &SessionRepo{q: s.db} }
+func (s *DB) Traffic() *TrafficRepo  { return &TrafficRepo{q: s.db} }
+func (s *DB) Audit() *AuditRepo      { return &AuditRepo{q: s.db} }
+func (s *DB) Requests() *RequestRepo { return &RequestRepo{q: s.db} }
+func (s *DB) Bans() *BanRepo         { return &BanRepo{q: s.db} }
+func (s *DB) Mutes() *MuteRepo       { return &MuteRepo{q: s.db} }
+func (s *DB) Routes() *RouteRepo     { return &RouteRepo{q: s.db} }
+func (s *DB) Policies() *PolicyRepo  { return &PolicyRepo{q: s.db} }
+
+// Репозитории внутри транзакции
+func (t *Tx) Users() *UserRepo       { return &UserRepo{q: t.tx} }
+func (t *Tx) Groups() *GroupRepo     { return &GroupRepo{q: t.tx} }
+func (t *Tx) Certs() *CertRepo       { return &CertRepo{q: t.tx} }
+func (t *Tx) Sessions() *SessionRepo { return &SessionRepo{q: t.tx} }
+func (t *Tx) Traffic() *TrafficRepo  { return &TrafficRepo{q: t.tx} }
+func (t *Tx) Audit() *AuditRepo      { return &AuditRepo{q: t.tx} }
+func (t *Tx) Requests() *RequestRepo { return &RequestRepo{q: t.tx} }
+func (t *Tx) Bans() *BanRepo         { return &BanRepo{q: t.tx} }
+func (t *Tx) Mutes() *MuteRepo       { return &MuteRepo{q: t.tx} }
+func (t *Tx) Routes() *RouteRepo     { return &RouteRepo{q: t.tx} }
+func (t *Tx) Policies() *PolicyRepo  { return &PolicyRepo{q: t.tx} }
+
+// Время хранится как unix-секунды, NULL - отсутствие значения
+func toUnix(t time.Time) int64 {
+	return t.Unix()
+}
+
+func fromUnix(sec int64) time.Time {
+	return time.Unix(sec, 0)
+}
+
+func toNullUnix(t *time.Time) sql.NullInt64 {
+	if t == nil || t.IsZero() {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: t.Unix(), Valid: true}
+}
+
+func fromNullUnix(v sql.NullInt64) *time.Time {
+	if !v.Valid {
+		return nil
+	}
+	t := time.Unix(v.Int64, 0)
+	return &t
+}
+
+// queryError оборачивает ошибку запроса, сохраняя ErrNotFound
+func queryError(msg string, err error) error {
+	if stderrors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	return errors.CallStorageError(msg, err)
+}
+
+// Snapshot делает согласованную копию базы без остановки записи (VACUUM INTO)
+func (s *DB) Snapshot(ctx context.Context, target string) error {
+	err := os.MkdirAll(filepath.Dir(target), 0755)
+	if err != nil {
+		return errors.CallStorageError(fmt.Sprintf("Failed to create directory for %s", target), err)
+	}
+	// VACUUM INTO не перезаписывает существующий файл
+	os.Remove(target)
+
+	_, err = s.db.ExecContext(ctx, `VACUUM INTO ?`, target)
+	if err != nil {
+		return errors.CallStorageError(fmt.Sprintf("Failed to snapshot database to %s", target), err)
+	}
+	return nil
+}
+
+// CheckIntegrity открывает файл базы только для чтения и проверяет его целостность
+func CheckIntegrity(ctx context.Context, path string) error {
+	db, err := sql.Open("sqlite", "file:"+path+"?mode=ro")
+	if err != nil {
+		return errors.CallStorageError(fmt.Sprintf("Failed to open database %s", path), err)
+	}
+	defer db.Close()
+
+	var result string
+	err = db.QueryRowContext(ctx, `PRAGMA integrity_check`).Scan(&result)
+	if err != nil {
+		return errors.CallStorageError(fmt.Sprintf("Failed to check database %s", path), err)
+	}
+	if result != "ok" {
+		return errors.CallStorageError(fmt.Sprintf("Database %s is corrupted: %s", path, result), nil)
+	}
+
+	var version int
+	err = db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	if err != nil {
+		return errors.CallStorageError(fmt.Sprintf("Database %s has no schema_migrations", path), err)
+	}
+	if latest := migrations[len(migrations)-1].Version; version > latest {
+		return errors.CallStorageError(fmt.Sprintf("Database %s has schema %d, newer than supported %d", path, version, latest), nil)
+	}
+	return nil
+}
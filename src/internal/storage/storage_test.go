@@ -0,0 +1,329 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	stderrors "errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestUserRepoCRUD(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+
+	group := &Group{Name: "staff", Description: "Сотрудники"}
+	if err := db.Groups().Create(ctx, group); err != nil {
+		t.Fatal(err)
+	}
+
+	user := &User{Username: "alice", GroupID: &group.ID, PasswordHash: "$5$hash", TelegramID: 1001}
+	if err := db.Users().Create(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	if user.ID == 0 || user.Status != UserActive || user.CreatedAt.IsZero() {
+		t.Errorf("Create did not fill defaults: %+v", user)
+	}
+
+	got, err := db.Users().GetByUsername(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != user.ID || got.Group != "staff" || got.TelegramID != 1001 || got.PasswordHash != "$5$hash" {
+		t.Errorf("GetByUsername: %+v", got)
+	}
+	if got, err = db.Users().GetByTelegramID(ctx, 1001); err != nil || got.Username != "alice" {
+		t.Errorf("GetByTelegramID: %+v, %v", got, err)
+	}
+
+	// Имя пользователя уникально
+	if err := db.Users().Create(ctx, &User{Username: "alice"}); err == nil {
+		t.Error("duplicate username accepted")
+	}
+
+	user.GroupID = nil
+	user.TelegramID = 0
+	if err := db.Users().Update(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Users().SetStatus(ctx, user.ID, UserLocked); err != nil {
+		t.Fatal(err)
+	}
+	got, err = db.Users().Get(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.GroupID != nil || got.Group != "" || got.TelegramID != 0 || got.Status != UserLocked {
+		t.Errorf("after Update/SetStatus: %+v", got)
+	}
+
+	if err := db.Users().Create(ctx, &User{Username: "bob"}); err != nil {
+		t.Fatal(err)
+	}
+	list, err := db.Users().List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].Username != "alice" || list[1].Username != "bob" {
+		t.Errorf("List: %+v", list)
+	}
+
+	if err := db.Users().Delete(ctx, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Users().Get(ctx, user.ID); err != ErrNotFound {
+		t.Errorf("Get after Delete: %v, want ErrNotFound", err)
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+
+	checks := map[string]error{
+		"Users.Get":           second(db.Users().Get(ctx, 42)),
+		"Users.GetByUsername": second(db.Users().GetByUsername(ctx, "nobody")),
+		"Users.Update":        db.Users().Update(ctx, &User{ID: 42, Username: "nobody", Status: UserActive}),
+		"Users.SetStatus":     db.Users().SetStatus(ctx, 42, UserLocked),
+		"Users.Delete":        db.Users().Delete(ctx, 42),
+		"Groups.GetByName":    second(db.Groups().GetByName(ctx, "nobody")),
+		"Groups.Delete":       db.Groups().Delete(ctx, 42),
+		"Certs.GetBySerial":   second(db.Certs().GetBySerial(ctx, "ff")),
+		"Certs.Revoke":        db.Certs().Revoke(ctx, "ff", 1),
+		"Bans.Remove":         db.Bans().Remove(ctx, "203.0.113.1"),
+		"Routes.Get":          second(db.Routes().Get(ctx, 42)),
+		"Routes.Delete":       db.Routes().Delete(ctx, 42),
+		"Policies.Get":        second(db.Policies().Get(ctx, 42)),
+	}
+	for name, err := range checks {
+		if err != ErrNotFound {
+			t.Errorf("%s: got %v, want ErrNotFound", name, err)
+		}
+	}
+}
+
+// second возвращает ошибку из пары (значение, ошибка)
+func second[T any](_ T, err error) error {
+	return err
+}
+
+func TestCertRepo(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+
+	user := &User{Username: "alice"}
+	if err := db.Users().Create(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	old := &IssuedCert{UserID: user.ID, Serial: "0a", NotAfter: now.Add(24 * time.Hour), IssuedAt: now.Add(-time.Hour)}
+	fresh := &IssuedCert{UserID: user.ID, Serial: "0b", NotAfter: now.Add(365 * 24 * time.Hour)}
+	for _, cert := range []*IssuedCert{old, fresh} {
+		if err := db.Certs().Add(ctx, cert); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Certs().Add(ctx, &IssuedCert{UserID: user.ID, Serial: "0a", NotAfter: now}); err == nil {
+		t.Error("duplicate serial accepted")
+	}
+
+	list, err := db.Certs().ListByUser(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].Serial != "0b" || list[0].Username != "alice" {
+		t.Errorf("ListByUser: %+v", list)
+	}
+
+	expiring, err := db.Certs().ListExpiring(ctx, now.Add(7*24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(expiring) != 1 || expiring[0].Serial != "0a" {
+		t.Errorf("ListExpiring: %+v", expiring)
+	}
+
+	if err := db.Certs().Revoke(ctx, "0a", 4); err != nil {
+		t.Fatal(err)
+	}
+	// Повторный отзыв не меняет дату и причину
+	if err := db.Certs().Revoke(ctx, "0a", 1); err != ErrNotFound {
+		t.Errorf("second Revoke: %v, want ErrNotFound", err)
+	}
+	revoked, err := db.Certs().ListRevoked(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revoked) != 1 || revoked[0].Serial != "0a" || revoked[0].Reason != 4 || revoked[0].RevokedAt == nil {
+		t.Errorf("ListRevoked: %+v", revoked)
+	}
+	if expiring, _ := db.Certs().ListExpiring(ctx, now.Add(7*24*time.Hour)); len(expiring) != 0 {
+		t.Errorf("revoked cert still expiring: %+v", expiring)
+	}
+
+	// Сертификаты удаляются вместе с пользователем
+	if err := db.Users().Delete(ctx, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Certs().GetBySerial(ctx, "0b"); err != ErrNotFound {
+		t.Errorf("cert survived user deletion: %v", err)
+	}
+}
+
+func TestRoutesAndPolicies(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+
+	group := &Group{Name: "staff"}
+	if err := db.Groups().Create(ctx, group); err != nil {
+		t.Fatal(err)
+	}
+	user := &User{Username: "alice", GroupID: &group.ID}
+	if err := db.Users().Create(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+
+	routes := &UserRoutes{UserID: user.ID, Routes: []string{"10.0.0.0/8", "192.168.1.0/24"}, DNS: []string{"10.0.0.1"}}
+	if err := db.Routes().Set(ctx, routes); err != nil {
+		t.Fatal(err)
+	}
+	got, err := db.Routes().Get(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Username != "alice" || !slices.Equal(got.Routes, routes.Routes) || !slices.Equal(got.DNS, routes.DNS) || len(got.NoRoutes) != 0 {
+		t.Errorf("Routes.Get: %+v", got)
+	}
+	// Пустые настройки удаляют запись
+	if err := db.Routes().Set(ctx, &UserRoutes{UserID: user.ID}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Routes().Get(ctx, user.ID); err != ErrNotFound {
+		t.Errorf("empty routes kept: %v", err)
+	}
+
+	policy := &GroupPolicy{GroupID: group.ID, NoRoutes: []string{"10.1.0.0/16"}, RxPerSec: 1 << 20, IdleTimeout: 600}
+	if err := db.Policies().Set(ctx, policy); err != nil {
+		t.Fatal(err)
+	}
+	list, err := db.Policies().List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Group != "staff" || list[0].RxPerSec != 1<<20 || list[0].IdleTimeout != 600 {
+		t.Errorf("Policies.List: %+v", list)
+	}
+
+	// Удаление группы снимает ее с пользователей и удаляет политику
+	if err := db.Groups().Delete(ctx, group.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Policies().Get(ctx, group.ID); err != ErrNotFound {
+		t.Errorf("policy survived group deletion: %v", err)
+	}
+	if user, err := db.Users().Get(ctx, user.ID); err != nil || user.GroupID != nil {
+		t.Errorf("user after group deletion: %+v, %v", user, err)
+	}
+}
+
+func TestInTxRollback(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+
+	failure := stderrors.New("abort")
+	err := db.InTx(ctx, func(tx *Tx) error {
+		err := tx.Users().Create(ctx, &User{Username: "alice"})
+		if err != nil {
+			return err
+		}
+		return failure
+	})
+	if err != failure {
+		t.Fatalf("InTx: %v, want fn error", err)
+	}
+	if _, err := db.Users().GetByUsername(ctx, "alice"); err != ErrNotFound {
+		t.Errorf("rolled back user exists: %v", err)
+	}
+
+	err = db.InTx(ctx, func(tx *Tx) error {
+		return tx.Users().Create(ctx, &User{Username: "bob"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Users().GetByUsername(ctx, "bob"); err != nil {
+		t.Errorf("committed user missing: %v", err)
+	}
+}
+
+func TestSnapshotAndCheckIntegrity(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+
+	if err := db.Users().Create(ctx, &User{Username: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+
+	target := filepath.Join(t.TempDir(), "snapshots", "eidolon.db")
+	// Снимок перезаписывает прежний файл
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(target, []byte("stale"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Snapshot(ctx, target); err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	if err := CheckIntegrity(ctx, target); err != nil {
+		t.Fatalf("CheckIntegrity of snapshot: %v", err)
+	}
+
+	snapshot, err := Open(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := snapshot.Users().GetByUsername(ctx, "alice"); err != nil {
+		t.Errorf("snapshot lost data: %v", err)
+	}
+	snapshot.Close()
+
+	// Не база SQLite
+	garbage := filepath.Join(t.TempDir(), "garbage.db")
+	if err := os.WriteFile(garbage, []byte("definitely not sqlite, just some bytes padded out"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckIntegrity(ctx, garbage); err == nil {
+		t.Error("CheckIntegrity accepted a non-database file")
+	}
+
+	// База SQLite без схемы eidolon
+	foreign := filepath.Join(t.TempDir(), "foreign.db")
+	raw, err := sql.Open("sqlite", "file:"+foreign)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := raw.Exec(`CREATE TABLE notes (text TEXT)`); err != nil {
+		t.Fatal(err)
+	}
+	raw.Close()
+	if err := CheckIntegrity(ctx, foreign); err == nil {
+		t.Error("CheckIntegrity accepted a database without schema_migrations")
+	}
+
+	// Схема новее, чем поддерживает сборка
+	if _, err := db.SQL().Exec(`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, 'future', 0)`,
+		latestVersion()+1); err != nil {
+		t.Fatal(err)
+	}
+	future := filepath.Join(t.TempDir(), "future.db")
+	if err := db.Snapshot(ctx, future); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckIntegrity(ctx, future); err == nil {
+		t.Error("CheckIntegrity accepted a newer schema")
+	}
+}
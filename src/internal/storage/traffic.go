@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"context"
+	"eidolonVPN/internal/errors"
+	"fmt"
+	"time"
+)
+
+// Формат дня в таблице traffic
+const dayLayout = "2006-01-02"
+
+// TrafficCounter - трафик пользователя за сутки (UTC)
+type TrafficCounter struct {
+	UserID  int64
+	Day     string // YYYY-MM-DD
+	RxBytes int64
+	TxBytes int64
+}
+
+// TrafficRepo - репозиторий счетчиков трафика
+type TrafficRepo struct {
+	q querier
+}
+
+// Add прибавляет трафик к счетчику пользователя за день времени at
+func (r *TrafficRepo) Add(ctx context.Context, userID int64, at time.Time, rxBytes, txBytes int64) error {
+	_, err := r.q.ExecContext(ctx, `
+INSERT INTO traffic (user_id, day, rx_bytes, tx_bytes) VALUES (?, ?, ?, ?)
+ON CONFLICT (user_id, day) DO UPDATE SET
+	rx_bytes = rx_bytes + excluded.rx_bytes,
+	tx_bytes = tx_bytes + excluded.tx_bytes`,
+		userID, at.UTC().Format(dayLayout), rxBytes, txBytes)
+	if err != nil {
+		return errors.CallStorageError(fmt.Sprintf("Failed to add traffic of user %d", userID), err)
+	}
+	return nil
+}
+
+// Total возвращает суммарный трафик пользователя начиная с дня since
+func (r *TrafficRepo) Total(ctx context.Context, userID int64, since time.Time) (rxBytes, txBytes int64, err error) {
+	err = r.q.QueryRowContext(ctx, `
+SELECT COALESCE(SUM(rx_bytes), 0), COALESCE(SUM(tx_bytes), 0) FROM traffic
+WHERE user_id = ? AND day >= ?`,
+		userID, since.UTC().Format(dayLayout)).Scan(&rxBytes, &txBytes)
+	if err != nil {
+		return 0, 0, errors.CallStorageError(fmt.Sprintf("Failed to sum traffic of user %d", userID), err)
+	}
+	return rxBytes, txBytes, nil
+}
+
+// ListByUser возвращает дневные счетчики пользователя за период
+func (r *TrafficRepo) ListByUser(ctx context.Context, userID int64, since time.Time) ([]TrafficCounter, error) {
+	rows, err := r.q.QueryContext(ctx, `
+SELECT user_id, day, rx_bytes, tx_bytes FROM traffic
+WHERE user_id = ? AND day >= ? ORDER BY day`,
+		userID, since.UTC().Format(dayLayout))
+	if err != nil {
+		return nil, errors.CallStorageError("Failed to list traffic", err)
+	}
+	defer rows.Close()
+
+	var counters []TrafficCounter
+	for rows.Next() {
+		var c TrafficCounter
+		err := rows.Scan(&c.UserID, &c.Day, &c.RxBytes, &c.TxBytes)
+		if err != nil {
+			return nil, queryError("Failed to read traffic", err)
+		}
+		counters = append(counters, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.CallStorageError("Failed to list traffic", err)
+	}
+	return counters, nil
+}
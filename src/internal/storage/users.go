@@ -0,0 +1,181 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"eidolonVPN/internal/errors"
+	"fmt"
+	"time"
+)
+
+// Статусы пользователя
+const (
+	UserActive = "active"
+	UserLocked = "locked" // Временно заблокирован администратором
+	UserBanned = "banned"
+)
+
+// User - пользователь VPN
+type User struct {
+	ID           int64
+	Username     string // Совпадает с CN клиентского сертификата и именем в passwd
+	GroupID      *int64
+	Group        string // Имя группы, заполняется при чтении
+	PasswordHash string // crypt(3) хэш для ocpasswd
+	TelegramID   int64  // 0 - не привязан
+	Status       string
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// UserRepo - репозиторий пользователей
+type UserRepo struct {
+	q querier
+}
+
+const userColumns = `u.id, u.username, u.group_id, COALESCE(g.name, ''), u.password_hash,
+	COALESCE(u.telegram_id, 0), u.status, u.created_at, u.updated_at`
+
+const userFrom = ` FROM users u LEFT JOIN groups g ON g.id = u.group_id`
+
+// Create добавляет пользователя и заполняет ID и время создания
+func (r *UserRepo) Create(ctx context.Context, user *User) error {
+	if user.Status == "" {
+		user.Status = UserActive
+	}
+	now := time.Now()
+	res, err := r.q.ExecContext(ctx, `
+INSERT INTO users (username, group_id, password_hash, telegram_id, status, created_at, updated_at)
+VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		user.Username, user.GroupID, user.PasswordHash, nullID(user.TelegramID), user.Status,
+		toUnix(now), toUnix(now))
+	if err != nil {
+		return errors.CallStorageError(fmt.Sprintf("Failed to create user %s", user.Username), err)
+	}
+
+	user.ID, err = res.LastInsertId()
+	if err != nil {
+		return errors.CallStorageError("Failed to read user id", err)
+	}
+	user.CreatedAt = fromUnix(toUnix(now))
+	user.UpdatedAt = user.CreatedAt
+	return nil
+}
+
+// Get возвращает пользователя по ID
+func (r *UserRepo) Get(ctx context.Context, id int64) (*User, error) {
+	return r.one(ctx, `WHERE u.id = ?`, id)
+}
+
+// GetByUsername возвращает пользователя по имени
+func (r *UserRepo) GetByUsername(ctx context.Context, username string) (*User, error) {
+	return r.one(ctx, `WHERE u.username = ?`, username)
+}
+
+// GetByTelegramID возвращает пользователя, привязанного к Telegram аккаунту
+func (r *UserRepo) GetByTelegramID(ctx context.Context, telegramID int64) (*User, error) {
+	return r.one(ctx, `WHERE u.telegram_id = ?`, telegramID)
+}
+
+// List возвращает всех пользователей по имени
+func (r *UserRepo) List(ctx context.Context) ([]User, error) {
+	rows, err := r.q.QueryContext(ctx, `SELECT `+userColumns+userFrom+` ORDER BY u.username`)
+	if err != nil {
+		return nil, errors.CallStorageError("Failed to list users", err)
+	}
+	defer rows.Close()
+
+	var users []User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.CallStorageError("Failed to list users", err)
+	}
+	return users, nil
+}
+
+// Update сохраняет изменяемые поля пользователя
+func (r *UserRepo) Update(ctx context.Context, user *User) error {
+	now := time.Now()
+	res, err := r.q.ExecContext(ctx, `
+UPDATE users SET group_id = ?, password_hash = ?, telegram_id = ?, status = ?, updated_at = ?
+WHERE id = ?`,
+		user.GroupID, user.PasswordHash, nullID(user.TelegramID), user.Status, toUnix(now), user.ID)
+	if err != nil {
+		return errors.CallStorageError(fmt.Sprintf("Failed to update user %s", user.Username), err)
+	}
+	user.UpdatedAt = fromUnix(toUnix(now))
+	return expectRow(res)
+}
+
+// SetStatus меняет статус пользователя
+func (r *UserRepo) SetStatus(ctx context.Context, id int64, status string) error {
+	res, err := r.q.ExecContext(ctx, `UPDATE users SET status = ?, updated_at = ? WHERE id = ?`,
+		status, toUnix(time.Now()), id)
+	if err != nil {
+		return errors.CallStorageError(fmt.Sprintf("Failed to set status of user %d", id), err)
+	}
+	return expectRow(res)
+}
+
+// Delete удаляет пользователя вместе с его сертификатами, сессиями и трафиком
+func (r *UserRepo) Delete(ctx context.Context, id int64) error {
+	res, err := r.q.ExecContext(ctx, `DELETE FROM users WHERE id = ?`, id)
+	if err != nil {
+		return errors.CallStorageError(fmt.Sprintf("Failed to delete user %d", id), err)
+	}
+	return expectRow(res)
+}
+
+// one читает одного пользователя по условию
+func (r *UserRepo) one(ctx context.Context, where string, args ...any) (*User, error) {
+	row := r.q.QueryRowContext(ctx, `SELECT `+userColumns+userFrom+` `+where, args...)
+	return scanUser(row)
+}
+
+// scanner - общее для *sql.Row и *sql.Rows
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanUser разбирает строку пользователя
+func scanUser(row scanner) (*User, error) {
+	var (
+		user               User
+		groupID            sql.NullInt64
+		created, updatedAt int64
+	)
+	err := row.Scan(&user.ID, &user.Username, &groupID, &user.Group, &user.PasswordHash,
+		&user.TelegramID, &user.Status, &created, &updatedAt)
+	if err != nil {
+		return nil, queryError("Failed to read user", err)
+	}
+	if groupID.Valid {
+		user.GroupID = &groupID.Int64
+	}
+	user.CreatedAt = fromUnix(created)
+	user.UpdatedAt = fromUnix(updatedAt)
+	return &user, nil
+}
+
+// nullID превращает нулевой внешний идентификатор в NULL
+func nullID(id int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: id, Valid: id != 0}
+}
+
+// expectRow возвращает ErrNotFound, если запрос не затронул ни одной строки
+func expectRow(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.CallStorageError("Failed to read affected rows", err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
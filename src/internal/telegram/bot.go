@@ -0,0 +1,217 @@
+package telegram
+
+import (
+	"context"
+	"crypto/subtle"
+	"eidolonVPN/internal/config/structures"
+	"eidolonVPN/internal/errors"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Параметры работы бота
+const (
+	defaultPollTimeout = 30 * time.Second
+	maxPollBackoff     = 30 * time.Second
+	shutdownTimeout    = 10 * time.Second // Ожидание незавершенных обработчиков
+	handlerTimeout     = 2 * time.Minute
+)
+
+// Bot получает обновления через long polling или webhook и передает их маршрутизатору
+type Bot struct {
+	config   structures.TelegramConfig
+	client   *Client
+	router   *Router
+	handlers sync.WaitGroup
+	ctx      context.Context // Контекст обработчиков, отменяется после ожидания при остановке
+}
+
+// NewBot создает бота по настройкам telegram.yaml
+func NewBot(cfg structures.TelegramConfig, router *Router) *Bot {
+	return &Bot{
+		config: cfg,
+		client: NewClient(cfg.Token, cfg.APIURL),
+		router: router,
+	}
+}
+
+// Client возвращает клиента Bot API для отправки сообщений вне обработчиков
+func (b *Bot) Client() *Client {
+	return b.client
+}
+
+// Run принимает обновления до отмены контекста, затем дожидается обработчиков
+func (b *Bot) Run(ctx context.Context) error {
+	me, err := b.client.GetMe(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Telegram bot @%s started\n", me.Username)
+
+	// Обработчики получают свой контекст, чтобы успеть ответить при остановке
+	handlerCtx, cancelHandlers := context.WithCancel(context.Background())
+	defer cancelHandlers()
+	b.ctx = handlerCtx
+
+	err = b.client.SetMyCommands(ctx, b.router.Commands())
+	if err != nil {
+		fmt.Printf("Failed to publish bot commands: %v\n", err)
+	}
+
+	if b.config.Webhook.Enabled {
+		err = b.runWebhook(ctx)
+	} else {
+		err = b.runPolling(ctx)
+	}
+
+	b.wait()
+	return err
+}
+
+// runPolling получает обновления через getUpdates
+func (b *Bot) runPolling(ctx context.Context) error {
+	err := b.client.DeleteWebhook(ctx)
+	if err != nil {
+		return err
+	}
+
+	timeout := b.config.PollTimeout
+	if timeout <= 0 {
+		timeout = defaultPollTimeout
+	}
+
+	var offset int64
+	backoff := time.Second
+	for {
+		updates, err := b.client.GetUpdates(ctx, offset, timeout)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			wait := backoff
+			var apiErr *APIError
+			if stderrors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+				wait = time.Duration(apiErr.RetryAfter) * time.Second
+			}
+			fmt.Printf("Telegram polling failed, retrying in %s: %v\n", wait, err)
+
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(wait):
+			}
+			backoff = min(backoff*2, maxPollBackoff)
+			continue
+		}
+		backoff = time.Second
+
+		for _, update := range updates {
+			offset = update.UpdateID + 1
+			b.dispatch(update)
+		}
+	}
+}
+
+// runWebhook принимает обновления HTTP сервером
+func (b *Bot) runWebhook(ctx context.Context) error {
+	webhook := b.config.Webhook
+
+	err := b.client.SetWebhook(ctx, webhook.URL, webhook.SecretToken)
+	if err != nil {
+		return err
+	}
+
+	server := &http.Server{
+		Addr:              webhook.Listen,
+		Handler:           b.WebhookHandler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		if webhook.CertFile != "" {
+			serveErr <- server.ListenAndServeTLS(webhook.CertFile, webhook.KeyFile)
+		} else {
+			serveErr <- server.ListenAndServe()
+		}
+	}()
+
+	select {
+	case err = <-serveErr:
+		return errors.CallTelegramError(fmt.Sprintf("Webhook server on %s failed", webhook.Listen), err)
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	server.Shutdown(shutdownCtx)
+	return nil
+}
+
+// WebhookHandler возвращает HTTP обработчик обновлений от Telegram
+func (b *Bot) WebhookHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		// Без настроенного секрета обновления не принимаются вовсе
+		secret := b.config.Webhook.SecretToken
+		header := r.Header.Get("X-Telegram-Bot-Api-Secret-Token")
+		if secret == "" || subtle.ConstantTimeCompare([]byte(header), []byte(secret)) != 1 {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		var update Update
+		err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&update)
+		if err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+
+		// Отвечаем сразу: Telegram повторяет обновления при медленном ответе
+		w.WriteHeader(http.StatusOK)
+		b.dispatch(update)
+	})
+}
+
+// dispatch обрабатывает обновление в отдельной горутине
+func (b *Bot) dispatch(update Update) {
+	b.handlers.Add(1)
+	go func() {
+		defer b.handlers.Done()
+
+		base := b.ctx
+		if base == nil {
+			base = context.Background()
+		}
+		ctx, cancel := context.WithTimeout(base, handlerTimeout)
+		defer cancel()
+
+		c := &Context{Ctx: ctx, Client: b.client, Update: update}
+		err := b.router.Dispatch(c)
+		if err != nil {
+			fmt.Printf("Telegram update %d failed: %v\n", update.UpdateID, err)
+		}
+	}()
+}
+
+// wait дожидается обработчиков не дольше shutdownTimeout
+func (b *Bot) wait() {
+	done := make(chan struct{})
+	go func() {
+		b.handlers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(shutdownTimeout):
+		fmt.Println("Telegram handlers did not finish in time")
+	}
+}
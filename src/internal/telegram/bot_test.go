@@ -0,0 +1,132 @@
+package telegram
+
+import (
+	"context"
+	"eidolonVPN/internal/config/structures"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Обновление от администратора, которое злоумышленник попытался бы подделать
+const adminUpdate = `{"update_id":1,"message":{"message_id":1,"from":{"id":1001,"first_name":"Admin"},"chat":{"id":1001,"type":"private"},"text":"/ping"}}`
+
+// pingRouter отвечает на /ping и сообщает о вызове в канал
+func pingRouter() (*Router, chan int64) {
+	called := make(chan int64, 1)
+	router := NewRouter()
+	router.Handle("ping", "Проверка", func(c *Context) error {
+		called <- c.From().ID
+		return nil
+	}, AdminOnly(func() []int64 { return []int64{1001} }))
+	return router, called
+}
+
+func TestWebhookAuth(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret string // webhook.secret_token
+		method string
+		header string // X-Telegram-Bot-Api-Secret-Token
+		body   string
+		status int
+	}{
+		{"valid", "s3cret_token-1", http.MethodPost, "s3cret_token-1", adminUpdate, http.StatusOK},
+		{"missing header", "s3cret_token-1", http.MethodPost, "", adminUpdate, http.StatusForbidden},
+		{"wrong header", "s3cret_token-1", http.MethodPost, "s3cret_token-2", adminUpdate, http.StatusForbidden},
+		{"prefix of secret", "s3cret_token-1", http.MethodPost, "s3cret", adminUpdate, http.StatusForbidden},
+		{"no secret configured", "", http.MethodPost, "", adminUpdate, http.StatusForbidden},
+		{"get", "s3cret_token-1", http.MethodGet, "s3cret_token-1", "", http.StatusMethodNotAllowed},
+		{"bad body", "s3cret_token-1", http.MethodPost, "s3cret_token-1", "{", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, called := pingRouter()
+			cfg := structures.TelegramConfig{Webhook: structures.WebhookConfig{Enabled: true, SecretToken: tt.secret}}
+			bot := NewBot(cfg, router)
+
+			req := httptest.NewRequest(tt.method, "/telegram", strings.NewReader(tt.body))
+			if tt.header != "" {
+				req.Header.Set("X-Telegram-Bot-Api-Secret-Token", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			bot.WebhookHandler().ServeHTTP(rec, req)
+			bot.wait()
+
+			if rec.Code != tt.status {
+				t.Errorf("status %d, want %d", rec.Code, tt.status)
+			}
+			select {
+			case id := <-called:
+				if tt.status != http.StatusOK {
+					t.Errorf("handler called by rejected request from %d", id)
+				}
+			default:
+				if tt.status == http.StatusOK {
+					t.Error("handler not called for accepted update")
+				}
+			}
+		})
+	}
+}
+
+func TestBotPolling(t *testing.T) {
+	api := newFakeAPI(t)
+	delivered := false
+	api.respond = func(method string, params map[string]any) (any, *apiResponse) {
+		switch method {
+		case "getMe":
+			return User{ID: 1, IsBot: true, FirstName: "Eidolon", Username: "eidolon_bot"}, nil
+		case "getUpdates":
+			if delivered {
+				time.Sleep(50 * time.Millisecond) // Пустой long polling
+				return []Update{}, nil
+			}
+			delivered = true
+			return []Update{{UpdateID: 5, Message: &Message{
+				MessageID: 1, From: &User{ID: 1001}, Chat: Chat{ID: 1001, Type: "private"}, Text: "/ping",
+			}}}, nil
+		case "sendMessage":
+			return Message{MessageID: 2, Chat: Chat{ID: 1001}}, nil
+		}
+		return true, nil
+	}
+
+	router := NewRouter()
+	router.Handle("ping", "Проверка", func(c *Context) error { return c.Reply("pong") })
+	bot := NewBot(structures.TelegramConfig{Token: testToken, APIURL: api.server.URL, PollTimeout: time.Second}, router)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- bot.Run(ctx) }()
+
+	deadline := time.After(5 * time.Second)
+	for len(api.called("sendMessage")) == 0 {
+		select {
+		case <-deadline:
+			t.Fatal("bot did not reply to /ping")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Run: %v", err)
+	}
+
+	reply := api.called("sendMessage")[0].Params
+	if reply["chat_id"] != float64(1001) || reply["text"] != "pong" {
+		t.Errorf("unexpected reply: %v", reply)
+	}
+	if len(api.called("deleteWebhook")) != 1 || len(api.called("setMyCommands")) != 1 {
+		t.Error("polling did not delete webhook or publish commands")
+	}
+
+	// Следующий запрос подтверждает полученное обновление
+	updates := api.called("getUpdates")
+	if len(updates) < 2 || updates[1].Params["offset"] != float64(6) {
+		t.Errorf("offset was not advanced: %v", updates)
+	}
+}
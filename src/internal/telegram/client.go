@@ -0,0 +1,239 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"eidolonVPN/internal/errors"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Адрес Bot API по умолчанию
+const defaultAPIURL = "https://api.telegram.org"
+
+// Запас к таймауту long polling на сетевые задержки
+const requestTimeout = 15 * time.Second
+
+// Client - клиент Telegram Bot API
+type Client struct {
+	token   string
+	baseURL string
+	http    *http.Client
+}
+
+// NewClient создает клиента; пустой apiURL - официальный Bot API
+func NewClient(token, apiURL string) *Client {
+	if apiURL == "" {
+		apiURL = defaultAPIURL
+	}
+	return &Client{
+		token:   token,
+		baseURL: strings.TrimSuffix(apiURL, "/"),
+		http:    &http.Client{},
+	}
+}
+
+// apiResponse - общий конверт ответа Bot API
+type apiResponse struct {
+	OK          bool            `json:"ok"`
+	Result      json.RawMessage `json:"result"`
+	Description string          `json:"description"`
+	ErrorCode   int             `json:"error_code"`
+	Parameters  *struct {
+		RetryAfter int `json:"retry_after"`
+	} `json:"parameters"`
+}
+
+// Call вызывает метод Bot API с JSON параметрами и разбирает результат в result
+func (c *Client) Call(ctx context.Context, method string, params, result any) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return errors.CallTelegramError(fmt.Sprintf("Failed to encode %s params", method), err)
+	}
+
+	// Таймаут запроса учитывает long polling, поэтому задается контекстом, а не клиентом
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, requestTimeout)
+		defer cancel()
+	}
+
+	url := c.baseURL + "/bot" + c.token + "/" + method
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return errors.CallTelegramError(fmt.Sprintf("Failed to build %s request", method), err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	return c.do(req, method, result)
+}
+
+// Upload вызывает метод Bot API с файлом в multipart/form-data
+func (c *Client) Upload(ctx context.Context, method string, fields map[string]string, field, filename string, data []byte, result any) error {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for key, value := range fields {
+		err := form.WriteField(key, value)
+		if err != nil {
+			return errors.CallTelegramError(fmt.Sprintf("Failed to encode %s params", method), err)
+		}
+	}
+	part, err := form.CreateFormFile(field, filename)
+	if err == nil {
+		_, err = part.Write(data)
+	}
+	if err == nil {
+		err = form.Close()
+	}
+	if err != nil {
+		return errors.CallTelegramError(fmt.Sprintf("Failed to encode %s file", method), err)
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, requestTimeout)
+		defer cancel()
+	}
+
+	url := c.baseURL + "/bot" + c.token + "/" + method
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, &body)
+	if err != nil {
+		return errors.CallTelegramError(fmt.Sprintf("Failed to build %s request", method), err)
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+
+	return c.do(req, method, result)
+}
+
+// do выполняет запрос и разбирает конверт ответа
+func (c *Client) do(req *http.Request, method string, result any) error {
+	resp, err := c.http.Do(req)
+	if err != nil {
+		// Токен входит в URL, поэтому текст ошибки транспорта не выводим целиком
+		return errors.CallTelegramError(fmt.Sprintf("Request %s failed", method), redactToken(err, c.token))
+	}
+	defer resp.Body.Close()
+
+	var envelope apiResponse
+	err = json.NewDecoder(resp.Body).Decode(&envelope)
+	if err != nil {
+		return errors.CallTelegramError(fmt.Sprintf("Invalid %s response (HTTP %d)", method, resp.StatusCode), err)
+	}
+	if !envelope.OK {
+		apiErr := &APIError{Code: envelope.ErrorCode, Description: envelope.Description}
+		if envelope.Parameters != nil {
+			apiErr.RetryAfter = envelope.Parameters.RetryAfter
+		}
+		return errors.CallTelegramError(fmt.Sprintf("Method %s failed", method), apiErr)
+	}
+
+	if result == nil {
+		return nil
+	}
+	err = json.Unmarshal(envelope.Result, result)
+	if err != nil {
+		return errors.CallTelegramError(fmt.Sprintf("Failed to decode %s result", method), err)
+	}
+	return nil
+}
+
+// GetMe возвращает информацию о боте и проверяет токен
+func (c *Client) GetMe(ctx context.Context) (*User, error) {
+	var me User
+	err := c.Call(ctx, "getMe", struct{}{}, &me)
+	if err != nil {
+		return nil, err
+	}
+	return &me, nil
+}
+
+// GetUpdates получает обновления начиная с offset, ожидая до timeout
+func (c *Client) GetUpdates(ctx context.Context, offset int64, timeout time.Duration) ([]Update, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout+requestTimeout)
+	defer cancel()
+
+	params := map[string]any{
+		"offset":          offset,
+		"timeout":         int(timeout.Seconds()),
+		"allowed_updates": []string{"message", "callback_query"},
+	}
+	var updates []Update
+	err := c.Call(ctx, "getUpdates", params, &updates)
+	if err != nil {
+		return nil, err
+	}
+	return updates, nil
+}
+
+// SendMessage отправляет сообщение
+func (c *Client) SendMessage(ctx context.Context, params SendMessageParams) (*Message, error) {
+	var message Message
+	err := c.Call(ctx, "sendMessage", params, &message)
+	if err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+// SendDocument отправляет файл с подписью
+func (c *Client) SendDocument(ctx context.Context, chatID int64, filename string, data []byte, caption string) (*Message, error) {
+	fields := map[string]string{"chat_id": strconv.FormatInt(chatID, 10)}
+	if caption != "" {
+		fields["caption"] = caption
+		fields["parse_mode"] = "HTML"
+	}
+
+	var message Message
+	err := c.Upload(ctx, "sendDocument", fields, "document", filename, data, &message)
+	if err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+// EditMessageText меняет текст и клавиатуру отправленного сообщения
+func (c *Client) EditMessageText(ctx context.Context, params EditMessageTextParams) error {
+	return c.Call(ctx, "editMessageText", params, nil)
+}
+
+// AnswerCallbackQuery подтверждает нажатие кнопки, text показывается всплывающим уведомлением
+func (c *Client) AnswerCallbackQuery(ctx context.Context, callbackID, text string) error {
+	params := map[string]any{"callback_query_id": callbackID}
+	if text != "" {
+		params["text"] = text
+	}
+	return c.Call(ctx, "answerCallbackQuery", params, nil)
+}
+
+// SetMyCommands публикует меню команд
+func (c *Client) SetMyCommands(ctx context.Context, commands []BotCommand) error {
+	return c.Call(ctx, "setMyCommands", map[string]any{"commands": commands}, nil)
+}
+
+// SetWebhook регистрирует webhook
+func (c *Client) SetWebhook(ctx context.Context, url, secretToken string) error {
+	params := map[string]any{
+		"url":             url,
+		"allowed_updates": []string{"message", "callback_query"},
+		"secret_token":    secretToken,
+	}
+	return c.Call(ctx, "setWebhook", params, nil)
+}
+
+// DeleteWebhook снимает webhook: иначе getUpdates вернет конфликт
+func (c *Client) DeleteWebhook(ctx context.Context) error {
+	return c.Call(ctx, "deleteWebhook", map[string]any{"drop_pending_updates": false}, nil)
+}
+
+// redactToken убирает токен бота из текста ошибки
+func redactToken(err error, token string) error {
+	if token == "" || !strings.Contains(err.Error(), token) {
+		return err
+	}
+	return fmt.Errorf("%s", strings.ReplaceAll(err.Error(), token, "<token>"))
+}
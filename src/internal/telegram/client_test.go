@@ -0,0 +1,188 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testToken = "123456:TEST-token"
+
+// apiCall - запрос к поддельному Bot API
+type apiCall struct {
+	Method string
+	Params map[string]any
+}
+
+// fakeAPI - поддельный Bot API: записывает вызовы и отвечает через respond
+type fakeAPI struct {
+	t      *testing.T
+	server *httptest.Server
+
+	mutex   sync.Mutex
+	calls   []apiCall
+	respond func(method string, params map[string]any) (result any, apiErr *apiResponse)
+}
+
+func newFakeAPI(t *testing.T) *fakeAPI {
+	t.Helper()
+
+	api := &fakeAPI{t: t}
+	api.server = httptest.NewServer(http.HandlerFunc(api.handle))
+	t.Cleanup(api.server.Close)
+	return api
+}
+
+func (a *fakeAPI) handle(w http.ResponseWriter, r *http.Request) {
+	method, ok := strings.CutPrefix(r.URL.Path, "/bot"+testToken+"/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	params := map[string]any{}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		err := json.NewDecoder(r.Body).Decode(&params)
+		if err != nil {
+			a.t.Errorf("%s: bad JSON: %v", method, err)
+		}
+	}
+
+	a.mutex.Lock()
+	a.calls = append(a.calls, apiCall{Method: method, Params: params})
+	respond := a.respond
+	a.mutex.Unlock()
+
+	var result any = true
+	var failure *apiResponse
+	if respond != nil {
+		result, failure = respond(method, params)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if failure != nil {
+		json.NewEncoder(w).Encode(failure)
+		return
+	}
+	data, _ := json.Marshal(result)
+	json.NewEncoder(w).Encode(apiResponse{OK: true, Result: data})
+}
+
+// called возвращает вызовы метода
+func (a *fakeAPI) called(method string) []apiCall {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	var calls []apiCall
+	for _, call := range a.calls {
+		if call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+func (a *fakeAPI) client() *Client {
+	return NewClient(testToken, a.server.URL+"/")
+}
+
+func TestClientGetUpdates(t *testing.T) {
+	api := newFakeAPI(t)
+	api.respond = func(method string, params map[string]any) (any, *apiResponse) {
+		return []Update{
+			{UpdateID: 10, Message: &Message{MessageID: 1, Chat: Chat{ID: 42}, Text: "/start"}},
+			{UpdateID: 11, CallbackQuery: &CallbackQuery{ID: "cb", From: User{ID: 7}, Data: "users:2"}},
+		}, nil
+	}
+
+	updates, err := api.client().GetUpdates(context.Background(), 10, 25*time.Second)
+	if err != nil {
+		t.Fatalf("GetUpdates: %v", err)
+	}
+	if len(updates) != 2 || updates[0].Message.Text != "/start" || updates[1].CallbackQuery.Data != "users:2" {
+		t.Errorf("unexpected updates: %+v", updates)
+	}
+
+	calls := api.called("getUpdates")
+	if len(calls) != 1 {
+		t.Fatalf("getUpdates called %d times", len(calls))
+	}
+	params := calls[0].Params
+	if params["offset"] != float64(10) || params["timeout"] != float64(25) {
+		t.Errorf("unexpected params: %v", params)
+	}
+	allowed, _ := params["allowed_updates"].([]any)
+	if len(allowed) != 2 || allowed[0] != "message" || allowed[1] != "callback_query" {
+		t.Errorf("allowed_updates = %v", params["allowed_updates"])
+	}
+}
+
+func TestClientSendMessage(t *testing.T) {
+	api := newFakeAPI(t)
+	api.respond = func(method string, params map[string]any) (any, *apiResponse) {
+		return Message{MessageID: 99, Chat: Chat{ID: 42}, Text: params["text"].(string)}, nil
+	}
+
+	message, err := api.client().SendMessage(context.Background(), SendMessageParams{
+		ChatID:    42,
+		Text:      "<b>hi</b>",
+		ParseMode: "HTML",
+		ReplyMarkup: &InlineKeyboardMarkup{InlineKeyboard: [][]InlineKeyboardButton{
+			{{Text: "OK", CallbackData: "ok"}},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+	if message.MessageID != 99 || message.Text != "<b>hi</b>" {
+		t.Errorf("unexpected message: %+v", message)
+	}
+
+	params := api.called("sendMessage")[0].Params
+	if params["chat_id"] != float64(42) || params["parse_mode"] != "HTML" {
+		t.Errorf("unexpected params: %v", params)
+	}
+	markup, _ := params["reply_markup"].(map[string]any)
+	if markup == nil || markup["inline_keyboard"] == nil {
+		t.Errorf("reply_markup not sent: %v", params)
+	}
+}
+
+func TestClientAPIError(t *testing.T) {
+	api := newFakeAPI(t)
+	api.respond = func(method string, params map[string]any) (any, *apiResponse) {
+		failure := &apiResponse{ErrorCode: 429, Description: "Too Many Requests: retry after 5"}
+		failure.Parameters = &struct {
+			RetryAfter int `json:"retry_after"`
+		}{RetryAfter: 5}
+		return nil, failure
+	}
+
+	_, err := api.client().SendMessage(context.Background(), SendMessageParams{ChatID: 1, Text: "x"})
+	var apiErr *APIError
+	if !stderrors.As(err, &apiErr) {
+		t.Fatalf("got %v, want APIError", err)
+	}
+	if apiErr.Code != 429 || apiErr.RetryAfter != 5 {
+		t.Errorf("unexpected APIError: %+v", apiErr)
+	}
+}
+
+func TestClientRedactsToken(t *testing.T) {
+	api := newFakeAPI(t)
+	client := api.client()
+	api.server.Close()
+
+	_, err := client.GetMe(context.Background())
+	if err == nil {
+		t.Fatal("GetMe succeeded against a closed server")
+	}
+	if strings.Contains(err.Error(), testToken) {
+		t.Errorf("error leaks bot token: %v", err)
+	}
+}
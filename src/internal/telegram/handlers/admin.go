@@ -0,0 +1,440 @@
+package handlers
+
+import (
+	"context"
+	"eidolonVPN/internal/config"
+	"eidolonVPN/internal/openconnect"
+	"eidolonVPN/internal/pki"
+	"eidolonVPN/internal/storage"
+	"eidolonVPN/internal/telegram"
+	"eidolonVPN/internal/utils"
+	stderrors "errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Как часто отключать сессии с заблокированных адресов
+const banCheckInterval = 30 * time.Second
+
+// Admin - команды управления ocserv из Telegram. Просмотр доступен операторам,
+// действия, затрагивающие пользователей или сервис, - только администраторам
+type Admin struct {
+	registry   *config.Registry
+	db         *storage.DB
+	manager    *openconnect.Manager
+	supervisor *openconnect.Supervisor
+	renewer    *pki.Renewer
+}
+
+// NewAdmin создает обработчики команд администратора
+func NewAdmin(registry *config.Registry, db *storage.DB, manager *openconnect.Manager, supervisor *openconnect.Supervisor, renewer *pki.Renewer) *Admin {
+	return &Admin{registry: registry, db: db, manager: manager, supervisor: supervisor, renewer: renewer}
+}
+
+// Register добавляет команды администратора в маршрутизатор
+func (a *Admin) Register(router *telegram.Router) {
+	view := telegram.AdminOnly(Viewers(a.registry))
+	manage := telegram.AdminOnly(Admins(a.registry))
+
+	router.Handle("status", "Состояние ocserv", a.status, view)
+	router.Handle("users", "Подключенные пользователи", a.users, view)
+	router.HandleCallback("users", a.users, view)
+	router.Handle("kick", "Отключить пользователя: /kick <user>", a.kick, manage)
+	router.Handle("ban", "Заблокировать адрес: /ban <ip> [причина]", a.ban, manage)
+	router.Handle("unban", "Разблокировать адрес: /unban <ip>", a.unban, manage)
+	router.HandleCallback("bans", a.bans, manage)
+	router.Handle("restart", "Перезапустить ocserv", a.restart, manage)
+	router.Handle("reload", "Перечитать конфиги", a.reload, manage)
+}
+
+// status показывает состояние процесса, супервизора, серверного сертификата и счетчики ocserv
+func (a *Admin) status(c *telegram.Context) error {
+	supervisor := a.supervisor.Status()
+
+	var text strings.Builder
+	if supervisor.Running {
+		text.WriteString("ocserv: 🟢 работает\n")
+	} else {
+		text.WriteString("ocserv: 🔴 остановлен\n")
+	}
+	fmt.Fprintf(&text, "Перезапусков: %d\n", supervisor.Restarts)
+	if supervisor.LastExit != nil {
+		fmt.Fprintf(&text, "Последнее завершение: %s (%s)\n",
+			escape(supervisor.LastReason), supervisor.LastExit.Time.Format("2006-01-02 15:04:05"))
+	}
+	if supervisor.GaveUp {
+		text.WriteString("⚠️ Перезапуски остановлены: слишком частые падения\n")
+	}
+
+	if supervisor.Running {
+		status, err := a.manager.Occtl().ShowStatus(c.Ctx)
+		if err != nil {
+			fmt.Fprintf(&text, "\nocctl: %s\n", escape(err.Error()))
+		} else {
+			text.WriteString("\n")
+			if status.UpSince > 0 {
+				fmt.Fprintf(&text, "Работает с: %s\n", time.Unix(int64(status.UpSince), 0).Format("2006-01-02 15:04:05"))
+			}
+			fmt.Fprintf(&text, "Активных сессий: %d (всего %d)\n", status.ActiveSessions, status.TotalSessions)
+			fmt.Fprintf(&text, "Ошибок входа: %d\n", status.TotalAuthFails)
+			fmt.Fprintf(&text, "Трафик: ↓%s ↑%s\n", utils.FormatBytes(int64(status.RX)), utils.FormatBytes(int64(status.TX)))
+			fmt.Fprintf(&text, "Адресов в бане ocserv: %d\n", status.IPsInBanList)
+		}
+	}
+
+	cert := a.renewer.Status()
+	switch {
+	case cert.CheckedAt.IsZero():
+		text.WriteString("\nСерверный сертификат: еще не проверялся\n")
+	case cert.NotAfter.IsZero() && cert.Err != nil:
+		fmt.Fprintf(&text, "\nСерверный сертификат: ⚠️ %s\n", escape(cert.Err.Error()))
+	default:
+		fmt.Fprintf(&text, "\nСерверный сертификат: до %s, осталось %d дн.\n", cert.NotAfter.Format("2006-01-02"), cert.DaysLeft)
+		if cert.Err != nil {
+			fmt.Fprintf(&text, "⚠️ Продление не удалось: %s\n", escape(cert.Err.Error()))
+		}
+	}
+
+	bans, err := a.db.Bans().List(c.Ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(&text, "Адресов в бане eidolon: %d", len(bans))
+	return c.Reply(text.String())
+}
+
+// users показывает подключенных пользователей постранично
+func (a *Admin) users(c *telegram.Context) error {
+	if !a.manager.IsRunning() {
+		return a.respond(c, "ocserv не запущен")
+	}
+	sessions, err := a.manager.Occtl().ListUsers(c.Ctx)
+	if err != nil {
+		return err
+	}
+
+	lines := make([]string, 0, len(sessions))
+	for _, s := range sessions {
+		since := ""
+		if s.ConnectedAt > 0 {
+			since = time.Unix(int64(s.ConnectedAt), 0).Format("02.01 15:04")
+		}
+		lines = append(lines, fmt.Sprintf("<b>%s</b> %s → %s, с %s, ↓%s ↑%s",
+			escape(s.Username), escape(s.RemoteIP), escape(s.IPv4), since,
+			utils.FormatBytes(int64(s.RX)), utils.FormatBytes(int64(s.TX))))
+	}
+
+	text, keyboard := paginate(fmt.Sprintf("Подключено: %d", len(sessions)), lines, pageArg(c), "users")
+	if c.Callback != nil {
+		c.Answer("")
+		return c.Edit(text, keyboard)
+	}
+	return c.ReplyKeyboard(text, keyboard)
+}
+
+// kick отключает все сессии пользователя
+func (a *Admin) kick(c *telegram.Context) error {
+	if len(c.Args) != 1 {
+		return c.Reply("Использование: /kick &lt;user&gt;")
+	}
+	if !a.manager.IsRunning() {
+		return c.Reply("ocserv не запущен")
+	}
+	username := c.Args[0]
+
+	err := a.manager.Occtl().Disconnect(c.Ctx, username)
+	if err != nil {
+		return c.Reply(fmt.Sprintf("Не удалось отключить %s: %s", escape(username), escape(err.Error())))
+	}
+	audit(c.Ctx, a.db, storage.AuditEvent{Actor: actor(c.From().ID), Action: "session.kick", Target: username})
+	return c.Reply(fmt.Sprintf("Сессии <b>%s</b> отключены", escape(username)))
+}
+
+// ban блокирует адрес и отключает его сессии. occtl не умеет банить вручную,
+// поэтому блокировка хранится в базе, новые подключения отклоняет connect-script
+// по списку security.ban.list, а без списка - периодическое отключение сессий
+func (a *Admin) ban(c *telegram.Context) error {
+	if len(c.Args) == 0 {
+		return c.Reply("Использование: /ban &lt;ip&gt; [причина]")
+	}
+	ip := net.ParseIP(c.Args[0])
+	if ip == nil {
+		return c.Reply(fmt.Sprintf("%s не является IP адресом", escape(c.Args[0])))
+	}
+
+	ban := &storage.IPBan{
+		IP:        ip.String(),
+		Reason:    strings.Join(c.Args[1:], " "),
+		CreatedBy: actor(c.From().ID),
+	}
+	err := a.db.Bans().Add(c.Ctx, ban)
+	if err != nil {
+		return err
+	}
+
+	listErr := a.writeBanList(c.Ctx)
+	kicked, err := a.disconnectBanned(c.Ctx, map[string]bool{ban.IP: true})
+	audit(c.Ctx, a.db, storage.AuditEvent{
+		Actor:   ban.CreatedBy,
+		Action:  "ip.ban",
+		Target:  ban.IP,
+		Details: fmt.Sprintf("reason=%q sessions=%d", ban.Reason, kicked),
+	})
+	if err != nil {
+		return c.Reply(fmt.Sprintf("Адрес %s заблокирован, но сессии не отключены: %s", ban.IP, escape(err.Error())))
+	}
+
+	text := fmt.Sprintf("Адрес <code>%s</code> заблокирован, отключено сессий: %d", ban.IP, kicked)
+	switch {
+	case listErr != nil:
+		text += fmt.Sprintf("\n⚠️ Список блокировок не записан: %s. Новые подключения с адреса отключаются в течение %s",
+			escape(listErr.Error()), banCheckInterval)
+	case a.registry.Current().OpenConnect.Security.Ban.List == "":
+		text += fmt.Sprintf("\n⚠️ security.ban.list не задан: ocserv примет новое подключение с адреса, "+
+			"eidolon отключит его в течение %s", banCheckInterval)
+	}
+	return c.Reply(text)
+}
+
+// unban снимает блокировку eidolon и ocserv; без аргументов показывает список блокировок
+func (a *Admin) unban(c *telegram.Context) error {
+	if len(c.Args) == 0 {
+		return a.bans(c)
+	}
+	ip := net.ParseIP(c.Args[0])
+	if ip == nil {
+		return c.Reply(fmt.Sprintf("%s не является IP адресом", escape(c.Args[0])))
+	}
+	address := ip.String()
+
+	var removed []string
+	err := a.db.Bans().Remove(c.Ctx, address)
+	switch {
+	case err == nil:
+		removed = append(removed, "eidolon")
+		err = a.writeBanList(c.Ctx)
+		if err != nil {
+			return c.Reply(fmt.Sprintf("Блокировка %s снята в базе, но список блокировок не записан: %s", address, escape(err.Error())))
+		}
+	case !stderrors.Is(err, storage.ErrNotFound):
+		return err
+	}
+
+	// Автоматический бан ocserv за ошибки входа снимается через occtl
+	if a.manager.IsRunning() {
+		bans, err := a.manager.Occtl().ShowIPBans(c.Ctx)
+		if err != nil {
+			return err
+		}
+		for _, ban := range bans {
+			if ban.IP != address {
+				continue
+			}
+			err = a.manager.Occtl().Unban(c.Ctx, address)
+			if err != nil {
+				return c.Reply(fmt.Sprintf("Не удалось снять бан ocserv с %s: %s", address, escape(err.Error())))
+			}
+			removed = append(removed, "ocserv")
+		}
+	}
+
+	if len(removed) == 0 {
+		return c.Reply(fmt.Sprintf("Адрес <code>%s</code> не заблокирован", address))
+	}
+	audit(c.Ctx, a.db, storage.AuditEvent{
+		Actor:   actor(c.From().ID),
+		Action:  "ip.unban",
+		Target:  address,
+		Details: "from=" + strings.Join(removed, ","),
+	})
+	return c.Reply(fmt.Sprintf("Адрес <code>%s</code> разблокирован (%s)", address, strings.Join(removed, ", ")))
+}
+
+// bans показывает блокировки eidolon и ocserv постранично
+func (a *Admin) bans(c *telegram.Context) error {
+	bans, err := a.db.Bans().List(c.Ctx)
+	if err != nil {
+		return err
+	}
+
+	lines := make([]string, 0, len(bans))
+	for _, ban := range bans {
+		line := fmt.Sprintf("<code>%s</code> eidolon, %s", ban.IP, ban.CreatedAt.Format("02.01.2006"))
+		if ban.Reason != "" {
+			line += ": " + escape(ban.Reason)
+		}
+		lines = append(lines, line)
+	}
+	if a.manager.IsRunning() {
+		ocservBans, err := a.manager.Occtl().ShowIPBans(c.Ctx)
+		if err != nil {
+			return err
+		}
+		for _, ban := range ocservBans {
+			lines = append(lines, fmt.Sprintf("<code>%s</code> ocserv, score %d", escape(ban.IP), ban.Score))
+		}
+	}
+
+	text, keyboard := paginate("Заблокированные адреса", lines, pageArg(c), "bans")
+	if c.Callback != nil {
+		c.Answer("")
+		return c.Edit(text, keyboard)
+	}
+	return c.ReplyKeyboard(text, keyboard)
+}
+
+// restart перезапускает ocserv
+func (a *Admin) restart(c *telegram.Context) error {
+	c.Reply("Перезапускаю ocserv...")
+
+	err := a.manager.Restart()
+	audit(c.Ctx, a.db, storage.AuditEvent{Actor: actor(c.From().ID), Action: "ocserv.restart", Details: errorDetails(err)})
+	if err != nil {
+		return c.Reply("Перезапуск не удался: " + escape(err.Error()))
+	}
+	return c.Reply("ocserv перезапущен")
+}
+
+// reload перечитывает конфиги. Изменения ocserv.conf применяет подписчик реестра
+// (Manager.Apply: SIGHUP или перезапуск), отдельный сигнал не нужен
+func (a *Admin) reload(c *telegram.Context) error {
+	started := time.Now()
+	snapshot, err := a.registry.Reload()
+	if err != nil {
+		audit(c.Ctx, a.db, storage.AuditEvent{Actor: actor(c.From().ID), Action: "config.reload", Details: errorDetails(err)})
+		return c.Reply("Конфиги не применены: " + escape(err.Error()))
+	}
+
+	text := fmt.Sprintf("Конфиги перечитаны (версия %d)", snapshot.Version)
+	details := fmt.Sprintf("version=%d", snapshot.Version)
+	event := a.manager.LastReload()
+	switch {
+	case event.Time.Before(started):
+		text += ", ocserv.conf не изменился"
+	case event.Err != nil:
+		text += ", но ocserv не применил изменения: " + escape(event.Err.Error())
+		details += " " + errorDetails(event.Err)
+	case event.Restart:
+		text += fmt.Sprintf(", ocserv перезапущен: %s", escape(strings.Join(event.ChangedKeys, ", ")))
+		details += " restart=true"
+	default:
+		text += fmt.Sprintf(", ocserv перечитал: %s", escape(strings.Join(event.ChangedKeys, ", ")))
+	}
+	audit(c.Ctx, a.db, storage.AuditEvent{Actor: actor(c.From().ID), Action: "config.reload", Details: details})
+	return c.Reply(text)
+}
+
+// EnforceBans поддерживает список блокировок для connect-script и периодически
+// отключает сессии с заблокированных адресов до отмены контекста
+func (a *Admin) EnforceBans(ctx context.Context) {
+	ticker := time.NewTicker(banCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		// Список перезаписывается и на случай смены security.ban.list
+		err := a.writeBanList(ctx)
+		if err != nil {
+			fmt.Printf("Failed to write ban list: %v\n", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		bans, err := a.db.Bans().List(ctx)
+		if err != nil || len(bans) == 0 {
+			continue
+		}
+		banned := make(map[string]bool, len(bans))
+		for _, ban := range bans {
+			banned[ban.IP] = true
+		}
+
+		kicked, err := a.disconnectBanned(ctx, banned)
+		if err != nil {
+			fmt.Printf("Failed to enforce IP bans: %v\n", err)
+		} else if kicked > 0 {
+			fmt.Printf("Disconnected %d session(s) from banned addresses\n", kicked)
+		}
+	}
+}
+
+// writeBanList записывает адреса из базы в security.ban.list, если он задан
+func (a *Admin) writeBanList(ctx context.Context) error {
+	list := a.registry.Current().OpenConnect.Security.Ban.List
+	if list == "" {
+		return nil
+	}
+	bans, err := a.db.Bans().List(ctx)
+	if err != nil {
+		return err
+	}
+	ips := make([]string, 0, len(bans))
+	for _, ban := range bans {
+		ips = append(ips, ban.IP)
+	}
+	return openconnect.WriteBanList(list, ips)
+}
+
+// disconnectBanned отключает сессии с указанных адресов и возвращает их число
+func (a *Admin) disconnectBanned(ctx context.Context, banned map[string]bool) (int, error) {
+	if !a.manager.IsRunning() {
+		return 0, nil
+	}
+	occtl := a.manager.Occtl()
+	sessions, err := occtl.ListUsers(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	kicked := 0
+	for _, session := range sessions {
+		ip := net.ParseIP(session.RemoteIP)
+		if ip == nil || !banned[ip.String()] {
+			continue
+		}
+		err = occtl.DisconnectID(ctx, session.ID)
+		if err != nil {
+			return kicked, err
+		}
+		kicked++
+	}
+	return kicked, nil
+}
+
+// respond отвечает всплывающим уведомлением на кнопку или сообщением на команду
+func (a *Admin) respond(c *telegram.Context, text string) error {
+	if c.Callback != nil {
+		return c.Answer(text)
+	}
+	return c.Reply(text)
+}
+
+// pageArg возвращает номер страницы из аргументов команды или кнопки
+func pageArg(c *telegram.Context) int {
+	if len(c.Args) == 0 {
+		return 0
+	}
+	n, err := strconv.Atoi(c.Args[0])
+	if err != nil {
+		return 0
+	}
+	// В команде страницы нумеруются с 1, в кнопках - с 0
+	if c.Callback == nil {
+		n--
+	}
+	return n
+}
+
+// errorDetails - поле details журнала для результата действия
+func errorDetails(err error) string {
+	if err != nil {
+		return "error=" + err.Error()
+	}
+	return ""
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"eidolonVPN/internal/config"
+	"eidolonVPN/internal/openconnect"
+	"eidolonVPN/internal/pki"
+	"eidolonVPN/internal/storage"
+	"eidolonVPN/internal/telegram"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Причины отзыва, принимаемые /revoke
+var revokeReasons = map[string]int{
+	"unspecified": pki.ReasonUnspecified,
+	"compromise":  pki.ReasonKeyCompromise,
+	"superseded":  pki.ReasonSuperseded,
+	"cessation":   pki.ReasonCessation,
+}
+
+// Certs - отзыв клиентских сертификатов. Отзыв записывается в базу,
+// из которой строится CRL, после чего ocserv перечитывает CRL и отключает сессии
+type Certs struct {
+	registry  *config.Registry
+	db        *storage.DB
+	authority *pki.Authority
+	manager   *openconnect.Manager
+}
+
+// NewCerts создает обработчик отзыва сертификатов
+func NewCerts(registry *config.Registry, db *storage.DB, authority *pki.Authority, manager *openconnect.Manager) *Certs {
+	return &Certs{registry: registry, db: db, authority: authority, manager: manager}
+}
+
+// Register добавляет /revoke в маршрутизатор
+func (r *Certs) Register(router *telegram.Router) {
+	manage := telegram.AdminOnly(Admins(r.registry))
+	router.Handle("revoke", "Отозвать сертификаты: /revoke <user> [причина]", r.revoke, manage)
+}
+
+// revoke отзывает все действующие сертификаты пользователя
+func (r *Certs) revoke(c *telegram.Context) error {
+	if len(c.Args) == 0 || len(c.Args) > 2 {
+		return c.Reply("Использование: /revoke &lt;user&gt; [unspecified|compromise|superseded|cessation]")
+	}
+	username := c.Args[0]
+	reason := pki.ReasonUnspecified
+	if len(c.Args) == 2 {
+		code, ok := revokeReasons[strings.ToLower(c.Args[1])]
+		if !ok {
+			return c.Reply(fmt.Sprintf("Неизвестная причина %s", escape(c.Args[1])))
+		}
+		reason = code
+	}
+	if r.authority.CRLPath() == "" {
+		return c.Reply("Отзыв недоступен: не задан security.crl")
+	}
+
+	user, err := r.db.Users().GetByUsername(c.Ctx, username)
+	if err == storage.ErrNotFound {
+		return c.Reply(fmt.Sprintf("Пользователь <b>%s</b> не найден", escape(username)))
+	}
+	if err != nil {
+		return err
+	}
+	certs, err := r.db.Certs().ListByUser(c.Ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	var serials []string
+	for _, cert := range certs {
+		if cert.RevokedAt != nil || cert.NotAfter.Before(time.Now()) {
+			continue
+		}
+		err = r.authority.RevokeSerial(user.Username, cert.Serial, reason)
+		if err != nil {
+			return c.Reply(fmt.Sprintf("Не удалось отозвать %s: %s", cert.Serial, escape(err.Error())))
+		}
+		serials = append(serials, cert.Serial)
+	}
+
+	// Сертификат, выпущенный до появления базы, есть только на диске
+	if len(serials) == 0 {
+		serial, err := r.authority.Revoke(user.Username, reason)
+		if err != nil {
+			return c.Reply(fmt.Sprintf("У <b>%s</b> нет действующих сертификатов", escape(username)))
+		}
+		serials = append(serials, serial)
+	}
+
+	err = r.manager.ApplyRevocation(c.Ctx, user.Username)
+	audit(c.Ctx, r.db, storage.AuditEvent{
+		Actor:   actor(c.From().ID),
+		Action:  "cert.revoke",
+		Target:  user.Username,
+		Details: fmt.Sprintf("serials=%s reason=%d", strings.Join(serials, ","), reason),
+	})
+	if err != nil {
+		return c.Reply(fmt.Sprintf("Сертификаты <b>%s</b> отозваны, но ocserv не применил CRL: %s",
+			escape(username), escape(err.Error())))
+	}
+	return c.Reply(fmt.Sprintf("Отозвано сертификатов <b>%s</b>: %d", escape(username), len(serials)))
+}
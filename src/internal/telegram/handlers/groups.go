@@ -0,0 +1,294 @@
+package handlers
+
+import (
+	"eidolonVPN/internal/config"
+	"eidolonVPN/internal/groups"
+	"eidolonVPN/internal/storage"
+	"eidolonVPN/internal/telegram"
+	"eidolonVPN/internal/utils"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Справка по /group
+const groupUsage = `Использование:
+/group &lt;name&gt; - политика и участники группы
+/group &lt;name&gt; create [описание]
+/group &lt;name&gt; delete
+/group &lt;name&gt; route|no-route|dns add|del &lt;значение&gt;
+/group &lt;name&gt; rx|tx &lt;скорость|off&gt; - байт/с, допускаются K, M, G
+/group &lt;name&gt; max-same &lt;n|off&gt;
+/group &lt;name&gt; idle|mobile-idle &lt;секунды|off&gt;
+/group &lt;name&gt; clear
+/setgroup &lt;user&gt; &lt;group|none&gt;`
+
+// Groups - команды управления группами и их политиками.
+// Просмотр доступен операторам, изменения - только администраторам
+type Groups struct {
+	registry *config.Registry
+	service  *groups.Service
+}
+
+// NewGroups создает обработчик групп
+func NewGroups(registry *config.Registry, service *groups.Service) *Groups {
+	return &Groups{registry: registry, service: service}
+}
+
+// Register добавляет /groups, /group и /setgroup в маршрутизатор
+func (g *Groups) Register(router *telegram.Router) {
+	view := telegram.AdminOnly(Viewers(g.registry))
+	manage := telegram.AdminOnly(Admins(g.registry))
+
+	router.Handle("groups", "Группы и их политики", g.list, view)
+	router.Handle("group", "Политика группы: /group <name>", g.group, view)
+	router.Handle("setgroup", "Назначить группу: /setgroup <user> <group|none>", g.assign, manage)
+}
+
+// list показывает группы с числом участников
+func (g *Groups) list(c *telegram.Context) error {
+	list, err := g.service.List(c.Ctx)
+	if err != nil {
+		return err
+	}
+	if len(list) == 0 {
+		return c.Reply("Групп нет\n\n" + groupUsage)
+	}
+
+	var text strings.Builder
+	text.WriteString("Группы:\n")
+	for _, info := range list {
+		fmt.Fprintf(&text, "<b>%s</b>: участников %d", escape(info.Name), len(info.Members))
+		if info.Policy.Empty() {
+			text.WriteString(", без политики")
+		}
+		if info.Description != "" {
+			fmt.Fprintf(&text, " - %s", escape(info.Description))
+		}
+		text.WriteString("\n")
+	}
+	if !g.service.Enabled() {
+		text.WriteString("\n⚠️ network.config_per_group не задан: политики групп не применяются")
+	}
+	return c.Reply(text.String())
+}
+
+// group разбирает подкоманды /group
+func (g *Groups) group(c *telegram.Context) error {
+	switch len(c.Args) {
+	case 0:
+		return c.Reply(groupUsage)
+	case 1:
+		return g.show(c, c.Args[0])
+	}
+
+	if !slices.Contains(Admins(g.registry)(), c.From().ID) {
+		return c.Reply("Изменять группы могут только администраторы")
+	}
+	name, action, args := c.Args[0], c.Args[1], c.Args[2:]
+	by := actor(c.From().ID)
+
+	var change func(p *storage.GroupPolicy)
+	switch action {
+	case "create":
+		_, err := g.service.Create(c.Ctx, name, strings.Join(args, " "), by)
+		if err != nil {
+			return c.Reply(fmt.Sprintf("Не удалось создать группу <b>%s</b>: %s", escape(name), escape(err.Error())))
+		}
+		return c.Reply(fmt.Sprintf("Группа <b>%s</b> создана", escape(name)))
+	case "delete":
+		err := g.service.Delete(c.Ctx, name, by)
+		if err != nil {
+			return g.failed(c, name, err)
+		}
+		return c.Reply(fmt.Sprintf("Группа <b>%s</b> удалена, участники остались без группы", escape(name)))
+	case "route", "no-route", "dns":
+		if len(args) != 2 || (args[0] != "add" && args[0] != "del") {
+			return c.Reply(groupUsage)
+		}
+		add, value := args[0] == "add", args[1]
+		change = func(p *storage.GroupPolicy) {
+			list := &p.Routes
+			switch action {
+			case "no-route":
+				list = &p.NoRoutes
+			case "dns":
+				list = &p.DNS
+			}
+			editList(list, add, value)
+		}
+	case "rx", "tx":
+		if len(args) != 1 {
+			return c.Reply(groupUsage)
+		}
+		rate, ok := parseRate(args[0])
+		if !ok {
+			return c.Reply(fmt.Sprintf("Неверная скорость %s", escape(args[0])))
+		}
+		change = func(p *storage.GroupPolicy) {
+			if action == "rx" {
+				p.RxPerSec = rate
+			} else {
+				p.TxPerSec = rate
+			}
+		}
+	case "max-same", "idle", "mobile-idle":
+		if len(args) != 1 {
+			return c.Reply(groupUsage)
+		}
+		value, ok := parseLimit(args[0])
+		if !ok {
+			return c.Reply(fmt.Sprintf("Неверное значение %s", escape(args[0])))
+		}
+		change = func(p *storage.GroupPolicy) {
+			switch action {
+			case "max-same":
+				p.MaxSameClients = value
+			case "idle":
+				p.IdleTimeout = value
+			default:
+				p.MobileIdleTimeout = value
+			}
+		}
+	case "clear":
+		if len(args) != 0 {
+			return c.Reply(groupUsage)
+		}
+		change = func(p *storage.GroupPolicy) {
+			*p = storage.GroupPolicy{}
+		}
+	default:
+		return c.Reply(groupUsage)
+	}
+
+	_, err := g.service.UpdatePolicy(c.Ctx, name, by, change)
+	if err != nil {
+		return g.failed(c, name, err)
+	}
+	return g.show(c, name)
+}
+
+// show показывает политику и участников группы
+func (g *Groups) show(c *telegram.Context, name string) error {
+	info, err := g.service.Get(c.Ctx, name)
+	if err == storage.ErrNotFound {
+		return c.Reply(fmt.Sprintf("Группа <b>%s</b> не найдена", escape(name)))
+	}
+	if err != nil {
+		return err
+	}
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "Группа <b>%s</b>", escape(info.Name))
+	if info.Description != "" {
+		fmt.Fprintf(&text, " - %s", escape(info.Description))
+	}
+	text.WriteString("\n")
+	if info.Name == groups.DefaultGroup {
+		text.WriteString("Политика применяется к пользователям без группы\n")
+	}
+
+	policy := info.Policy
+	if policy.Empty() {
+		text.WriteString("Политика: общие настройки сервера\n")
+	}
+	field := func(title string, values []string) {
+		if len(values) > 0 {
+			fmt.Fprintf(&text, "%s: %s\n", title, escape(strings.Join(values, ", ")))
+		}
+	}
+	field("route", policy.Routes)
+	field("no-route", policy.NoRoutes)
+	field("dns", policy.DNS)
+	if policy.RxPerSec > 0 {
+		fmt.Fprintf(&text, "rx-data-per-sec: %s/с\n", utils.FormatBytes(policy.RxPerSec))
+	}
+	if policy.TxPerSec > 0 {
+		fmt.Fprintf(&text, "tx-data-per-sec: %s/с\n", utils.FormatBytes(policy.TxPerSec))
+	}
+	if policy.MaxSameClients > 0 {
+		fmt.Fprintf(&text, "max-same-clients: %d\n", policy.MaxSameClients)
+	}
+	if policy.IdleTimeout > 0 {
+		fmt.Fprintf(&text, "idle-timeout: %d с\n", policy.IdleTimeout)
+	}
+	if policy.MobileIdleTimeout > 0 {
+		fmt.Fprintf(&text, "mobile-idle-timeout: %d с\n", policy.MobileIdleTimeout)
+	}
+
+	if len(info.Members) == 0 {
+		text.WriteString("\nУчастников нет")
+	} else {
+		fmt.Fprintf(&text, "\nУчастники (%d): %s", len(info.Members), escape(strings.Join(info.Members, ", ")))
+	}
+	return c.Reply(text.String())
+}
+
+// assign назначает пользователю группу или убирает ее
+func (g *Groups) assign(c *telegram.Context) error {
+	if len(c.Args) != 2 {
+		return c.Reply("Использование: /setgroup &lt;user&gt; &lt;group|none&gt;")
+	}
+	username, name := c.Args[0], c.Args[1]
+	if name == "none" {
+		name = ""
+	}
+
+	err := g.service.Assign(c.Ctx, username, name, actor(c.From().ID))
+	if err == storage.ErrNotFound {
+		return c.Reply(fmt.Sprintf("Пользователь <b>%s</b> или группа <b>%s</b> не найдены", escape(username), escape(name)))
+	}
+	if err != nil {
+		return c.Reply(fmt.Sprintf("Не удалось назначить группу: %s", escape(err.Error())))
+	}
+	if name == "" {
+		return c.Reply(fmt.Sprintf("<b>%s</b> больше не состоит в группе", escape(username)))
+	}
+	return c.Reply(fmt.Sprintf("<b>%s</b> назначен в группу <b>%s</b>. Применится при следующем подключении",
+		escape(username), escape(name)))
+}
+
+// failed сообщает об ошибке изменения группы
+func (g *Groups) failed(c *telegram.Context, name string, err error) error {
+	if err == storage.ErrNotFound {
+		return c.Reply(fmt.Sprintf("Группа <b>%s</b> не найдена", escape(name)))
+	}
+	return c.Reply(fmt.Sprintf("Не удалось изменить группу <b>%s</b>: %s", escape(name), escape(err.Error())))
+}
+
+// parseRate разбирает скорость в байтах/с с необязательным суффиксом K, M или G; off - без ограничения
+func parseRate(value string) (int64, bool) {
+	if value == "off" {
+		return 0, true
+	}
+	multiplier := int64(1)
+	switch strings.ToUpper(value[len(value)-1:]) {
+	case "K":
+		multiplier = 1 << 10
+	case "M":
+		multiplier = 1 << 20
+	case "G":
+		multiplier = 1 << 30
+	}
+	if multiplier > 1 {
+		value = value[:len(value)-1]
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 || n > (1<<62)/multiplier {
+		return 0, false
+	}
+	return n * multiplier, true
+}
+
+// parseLimit разбирает неотрицательное число; off - значение сервера
+func parseLimit(value string) (int, bool) {
+	if value == "off" {
+		return 0, true
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return n, true
+}
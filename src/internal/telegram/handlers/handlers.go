@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"eidolonVPN/internal/config"
+	"eidolonVPN/internal/storage"
+	"fmt"
+	"html"
+	"strings"
+)
+
+// Алфавит генерируемых паролей без похожих символов (0/O, 1/l/I)
+const secretAlphabet = "abcdefghijkmnopqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// Admins возвращает функцию чтения списка администраторов из текущего снимка конфигов
+func Admins(registry *config.Registry) func() []int64 {
+	return func() []int64 {
+		return registry.Current().Telegram.Admins
+	}
+}
+
+// Viewers возвращает функцию чтения администраторов и операторов: им доступны команды просмотра
+func Viewers(registry *config.Registry) func() []int64 {
+	return func() []int64 {
+		cfg := registry.Current().Telegram
+		return append(append([]int64(nil), cfg.Admins...), cfg.Operators...)
+	}
+}
+
+// actor - автор записи журнала для действия из Telegram
+func actor(telegramID int64) string {
+	return fmt.Sprintf("telegram:%d", telegramID)
+}
+
+// audit пишет событие в журнал; ошибка журнала не прерывает действие
+func audit(ctx context.Context, db *storage.DB, event storage.AuditEvent) {
+	err := db.Audit().Record(ctx, &event)
+	if err != nil {
+		fmt.Printf("Failed to record audit event %s: %v\n", event.Action, err)
+	}
+}
+
+// randomSecret генерирует пароль из secretAlphabet
+func randomSecret(length int) (string, error) {
+	// Байты вне целого числа алфавитов отбрасываются, чтобы символы были равновероятны
+	limit := 256 - 256%len(secretAlphabet)
+	var secret strings.Builder
+	buf := make([]byte, length)
+	for secret.Len() < length {
+		_, err := rand.Read(buf)
+		if err != nil {
+			return "", err
+		}
+		for _, b := range buf {
+			if int(b) < limit && secret.Len() < length {
+				secret.WriteByte(secretAlphabet[int(b)%len(secretAlphabet)])
+			}
+		}
+	}
+	return secret.String(), nil
+}
+
+// escape экранирует текст для parse_mode=HTML
+func escape(text string) string {
+	return html.EscapeString(text)
+}
@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"context"
+	"eidolonVPN/internal/config"
+	"eidolonVPN/internal/events"
+	"eidolonVPN/internal/storage"
+	"eidolonVPN/internal/telegram"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Ограничения рассылки уведомлений
+const (
+	dedupeWindow = time.Hour   // Повтор события с тем же Kind и Key в пределах окна не отправляется
+	rateLimit    = 10          // Уведомлений одному получателю за rateWindow
+	rateWindow   = time.Minute // Окно ограничения частоты
+	sendTimeout  = 15 * time.Second
+)
+
+// Названия типов событий для сообщений и настроек подписки
+var kindTitles = map[events.Kind]string{
+	events.OcservCrash:   "Падение ocserv",
+	events.CertExpiring:  "Истечение сертификата",
+	events.BackupFailed:  "Ошибка резервного копирования",
+	events.QuotaExceeded: "Превышение квоты",
+}
+
+// rate - счетчик отправок получателю в текущем окне
+type rate struct {
+	windowStart time.Time
+	count       int
+	dropped     int // Пропущено из-за лимита, сообщается со следующим уведомлением
+}
+
+// Notifier рассылает события шины администраторам и операторам с учетом их подписок
+type Notifier struct {
+	registry *config.Registry
+	db       *storage.DB
+	client   *telegram.Client
+	mutex    sync.Mutex
+	sent     map[string]time.Time // Последняя отправка по Kind/Key
+	rates    map[int64]*rate
+}
+
+// NewNotifier создает рассыльщик уведомлений
+func NewNotifier(registry *config.Registry, db *storage.DB, client *telegram.Client) *Notifier {
+	return &Notifier{
+		registry: registry,
+		db:       db,
+		client:   client,
+		sent:     map[string]time.Time{},
+		rates:    map[int64]*rate{},
+	}
+}
+
+// Register добавляет /notify для настройки подписок
+func (n *Notifier) Register(router *telegram.Router) {
+	view := telegram.AdminOnly(Viewers(n.registry))
+	router.Handle("notify", "Настроить уведомления", n.settings, view)
+	router.HandleCallback("notify", n.toggle, view)
+}
+
+// Handle отправляет событие подписанным получателям; используется как подписчик шины
+func (n *Notifier) Handle(event events.Event) {
+	if n.duplicate(event) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+	defer cancel()
+
+	title := kindTitles[event.Kind]
+	if title == "" {
+		title = string(event.Kind)
+	}
+	text := fmt.Sprintf("🔔 <b>%s</b>\n%s\n<i>%s</i>", escape(title), escape(event.Message), event.Time.Format("2006-01-02 15:04:05"))
+
+	recipients := Viewers(n.registry)()
+	slices.Sort(recipients)
+	for _, id := range slices.Compact(recipients) {
+		muted, err := n.db.Mutes().List(ctx, id)
+		if err != nil {
+			fmt.Printf("Failed to load notification settings of %d: %v\n", id, err)
+			continue
+		}
+		if slices.Contains(muted, string(event.Kind)) {
+			continue
+		}
+
+		allowed, dropped := n.allow(id)
+		if !allowed {
+			continue
+		}
+		message := text
+		if dropped > 0 {
+			message += fmt.Sprintf("\n\nПропущено уведомлений из-за лимита: %d", dropped)
+		}
+
+		_, err = n.client.SendMessage(ctx, telegram.SendMessageParams{ChatID: id, Text: message, ParseMode: "HTML"})
+		if err != nil {
+			fmt.Printf("Failed to send %s notification to %d: %v\n", event.Kind, id, err)
+		}
+	}
+}
+
+// duplicate запоминает событие и сообщает, отправлялось ли оно в пределах dedupeWindow
+func (n *Notifier) duplicate(event events.Event) bool {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	key := string(event.Kind) + "/" + event.Key
+	if last, ok := n.sent[key]; ok && event.Time.Sub(last) < dedupeWindow {
+		return true
+	}
+	n.sent[key] = event.Time
+
+	// Устаревшие ключи больше ничего не подавляют
+	for k, last := range n.sent {
+		if event.Time.Sub(last) >= dedupeWindow {
+			delete(n.sent, k)
+		}
+	}
+	return false
+}
+
+// allow учитывает отправку получателю. Возвращает false сверх лимита
+// и число пропущенных уведомлений, если отправка разрешена
+func (n *Notifier) allow(id int64) (bool, int) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	now := time.Now()
+	r := n.rates[id]
+	if r == nil {
+		r = &rate{windowStart: now}
+		n.rates[id] = r
+	}
+	if now.Sub(r.windowStart) >= rateWindow {
+		r.windowStart = now
+		r.count = 0
+	}
+	if r.count >= rateLimit {
+		r.dropped++
+		return false, 0
+	}
+	r.count++
+	dropped := r.dropped
+	r.dropped = 0
+	return true, dropped
+}
+
+// settings показывает подписки получателя
+func (n *Notifier) settings(c *telegram.Context) error {
+	text, keyboard, err := n.settingsView(c)
+	if err != nil {
+		return err
+	}
+	if c.Callback != nil {
+		return c.Edit(text, keyboard)
+	}
+	return c.ReplyKeyboard(text, keyboard)
+}
+
+// toggle включает или отключает тип уведомлений
+func (n *Notifier) toggle(c *telegram.Context) error {
+	if len(c.Args) != 1 || kindTitles[events.Kind(c.Args[0])] == "" {
+		return c.Answer("Неизвестный тип уведомлений")
+	}
+	kind := c.Args[0]
+	id := c.From().ID
+
+	muted, err := n.db.Mutes().List(c.Ctx, id)
+	if err != nil {
+		return err
+	}
+	mute := !slices.Contains(muted, kind)
+	err = n.db.Mutes().Set(c.Ctx, id, kind, mute)
+	if err != nil {
+		return err
+	}
+	audit(c.Ctx, n.db, storage.AuditEvent{
+		Actor:   actor(id),
+		Action:  "notify.set",
+		Target:  kind,
+		Details: fmt.Sprintf("muted=%t", mute),
+	})
+
+	if mute {
+		c.Answer("Отключено")
+	} else {
+		c.Answer("Включено")
+	}
+	return n.settings(c)
+}
+
+// settingsView - текст и кнопки переключения подписок
+func (n *Notifier) settingsView(c *telegram.Context) (string, *telegram.InlineKeyboardMarkup, error) {
+	muted, err := n.db.Mutes().List(c.Ctx, c.From().ID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	keyboard := &telegram.InlineKeyboardMarkup{}
+	for _, kind := range events.Kinds {
+		mark := "✅"
+		if slices.Contains(muted, string(kind)) {
+			mark = "🔕"
+		}
+		keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, []telegram.InlineKeyboardButton{{
+			Text:         mark + " " + kindTitles[kind],
+			CallbackData: "notify:" + string(kind),
+		}})
+	}
+	return "Уведомления: нажмите, чтобы включить или отключить", keyboard, nil
+}
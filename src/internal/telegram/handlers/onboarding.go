@@ -0,0 +1,430 @@
+package handlers
+
+import (
+	"eidolonVPN/internal/config"
+	"eidolonVPN/internal/errors"
+	"eidolonVPN/internal/passwd"
+	"eidolonVPN/internal/pki"
+	"eidolonVPN/internal/storage"
+	"eidolonVPN/internal/telegram"
+	stderrors "errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Префикс callback_data кнопок заявки: access:approve:<id>, access:deny:<id>
+const accessCallback = "access"
+
+// Символы, которые не годятся для имени пользователя VPN
+var usernameUnsafe = regexp.MustCompile(`[^a-z0-9._-]+`)
+
+// Onboarding выдает доступ по заявке из Telegram: /start, решение администратора,
+// создание пользователя и отправка реквизитов
+type Onboarding struct {
+	registry  *config.Registry
+	db        *storage.DB
+	passwd    *passwd.Syncer // nil, если ocserv не использует файл паролей
+	authority *pki.Authority // nil, если eidolon не ведет собственный CA
+}
+
+// NewOnboarding создает обработчики заявок на доступ
+func NewOnboarding(registry *config.Registry, db *storage.DB, passwdSync *passwd.Syncer, authority *pki.Authority) *Onboarding {
+	return &Onboarding{registry: registry, db: db, passwd: passwdSync, authority: authority}
+}
+
+// Register добавляет /start и кнопки заявки в маршрутизатор
+func (o *Onboarding) Register(router *telegram.Router) {
+	router.Handle("start", "Запросить доступ к VPN", o.start)
+	router.HandleCallback(accessCallback, o.decide, telegram.AdminOnly(Admins(o.registry)))
+}
+
+// start подает заявку на доступ или сообщает ее состояние
+func (o *Onboarding) start(c *telegram.Context) error {
+	from := c.From()
+	if from == nil || c.Update.Message.Chat.Type != "private" {
+		return nil
+	}
+
+	user, err := o.db.Users().GetByTelegramID(c.Ctx, from.ID)
+	if err == nil {
+		return c.Reply(fmt.Sprintf("Доступ уже выдан, логин <code>%s</code>, статус %s.",
+			escape(user.Username), user.Status))
+	}
+	if !stderrors.Is(err, storage.ErrNotFound) {
+		return err
+	}
+
+	_, err = o.db.Requests().GetPending(c.Ctx, from.ID)
+	if err == nil {
+		return c.Reply("Заявка уже на рассмотрении, дождитесь решения администратора.")
+	}
+	if !stderrors.Is(err, storage.ErrNotFound) {
+		return err
+	}
+
+	admins := Admins(o.registry)()
+	if len(admins) == 0 {
+		return c.Reply("Прием заявок не настроен: нет администраторов.")
+	}
+
+	username, err := o.pickUsername(c, *from)
+	if err != nil {
+		return err
+	}
+	request := &storage.AccessRequest{
+		TelegramID: from.ID,
+		ChatID:     c.ChatID(),
+		Name:       from.Name(),
+		Username:   username,
+	}
+	err = o.db.Requests().Create(c.Ctx, request)
+	if err != nil {
+		return err
+	}
+	audit(c.Ctx, o.db, storage.AuditEvent{
+		Actor:   actor(from.ID),
+		Action:  "access.request",
+		Target:  username,
+		Details: fmt.Sprintf("request=%d name=%q", request.ID, request.Name),
+	})
+
+	// Уведомление каждому администратору; сообщения запоминаются, чтобы отметить решение
+	text := requestText(request)
+	keyboard := &telegram.InlineKeyboardMarkup{InlineKeyboard: [][]telegram.InlineKeyboardButton{{
+		{Text: "Одобрить", CallbackData: fmt.Sprintf("%s:approve:%d", accessCallback, request.ID)},
+		{Text: "Отклонить", CallbackData: fmt.Sprintf("%s:deny:%d", accessCallback, request.ID)},
+	}}}
+	notified := 0
+	for _, admin := range admins {
+		message, err := c.Client.SendMessage(c.Ctx, telegram.SendMessageParams{
+			ChatID:      admin,
+			Text:        text,
+			ParseMode:   "HTML",
+			ReplyMarkup: keyboard,
+		})
+		if err != nil {
+			fmt.Printf("Failed to notify admin %d about access request %d: %v\n", admin, request.ID, err)
+			continue
+		}
+		notified++
+		err = o.db.Requests().AddMessage(c.Ctx, request.ID,
+			storage.RequestMessage{ChatID: message.Chat.ID, MessageID: message.MessageID})
+		if err != nil {
+			fmt.Printf("Failed to save notification of access request %d: %v\n", request.ID, err)
+		}
+	}
+	if notified == 0 {
+		fmt.Printf("Access request %d was not delivered to any admin\n", request.ID)
+	}
+
+	return c.Reply("Заявка отправлена администраторам. Реквизиты придут сюда после одобрения.")
+}
+
+// decide обрабатывает кнопки одобрения и отказа
+func (o *Onboarding) decide(c *telegram.Context) error {
+	if len(c.Args) != 2 {
+		return c.Answer("Неизвестная кнопка")
+	}
+	id, err := strconv.ParseInt(c.Args[1], 10, 64)
+	if err != nil {
+		return c.Answer("Неизвестная заявка")
+	}
+	request, err := o.db.Requests().Get(c.Ctx, id)
+	if stderrors.Is(err, storage.ErrNotFound) {
+		return c.Answer("Заявка не найдена")
+	}
+	if err != nil {
+		return err
+	}
+
+	switch c.Args[0] {
+	case "approve":
+		return o.approve(c, request)
+	case "deny":
+		return o.deny(c, request)
+	default:
+		return c.Answer("Неизвестная кнопка")
+	}
+}
+
+// approve создает пользователя и отправляет ему реквизиты
+func (o *Onboarding) approve(c *telegram.Context, request *storage.AccessRequest) error {
+	admin := c.From()
+	usePassword, useCert := o.methods()
+	if !usePassword && !useCert {
+		return c.Answer("Нельзя выдать доступ: нет ни файла паролей ocserv, ни собственного CA")
+	}
+
+	// Имя могло быть занято, пока заявка ждала решения
+	username := request.Username
+	_, err := o.db.Users().GetByUsername(c.Ctx, username)
+	if err == nil {
+		username = fmt.Sprintf("%s-%d", username, request.TelegramID)
+	} else if !stderrors.Is(err, storage.ErrNotFound) {
+		return err
+	}
+
+	user := &storage.User{Username: username, TelegramID: request.TelegramID}
+	var password string
+	if usePassword {
+		password, err = randomSecret(16)
+		if err != nil {
+			return err
+		}
+		user.PasswordHash, err = passwd.HashPassword(password)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Решение, пользователь и записи журнала фиксируются вместе
+	err = o.db.InTx(c.Ctx, func(tx *storage.Tx) error {
+		err := tx.Requests().Decide(c.Ctx, request.ID, storage.RequestApproved, admin.ID)
+		if err != nil {
+			return err
+		}
+		err = tx.Users().Create(c.Ctx, user)
+		if err != nil {
+			return err
+		}
+		err = tx.Audit().Record(c.Ctx, &storage.AuditEvent{
+			Actor:   actor(admin.ID),
+			Action:  "access.approve",
+			Target:  username,
+			Details: fmt.Sprintf("request=%d telegram_id=%d", request.ID, request.TelegramID),
+		})
+		if err != nil {
+			return err
+		}
+		return tx.Audit().Record(c.Ctx, &storage.AuditEvent{
+			Actor:   actor(admin.ID),
+			Action:  "user.create",
+			Target:  username,
+			Details: fmt.Sprintf("password=%t certificate=%t", usePassword, useCert),
+		})
+	})
+	if stderrors.Is(err, storage.ErrNotFound) {
+		return c.Answer("Заявка уже рассмотрена")
+	}
+	if err != nil {
+		return err
+	}
+	c.Answer("Заявка одобрена")
+
+	var failures []string
+	if usePassword {
+		// Файл паролей строится из базы, синхронизация сразу добавляет пользователя и шлет SIGHUP
+		_, err = o.passwd.Sync(c.Ctx)
+		if err != nil {
+			failures = append(failures, "passwd: "+err.Error())
+			audit(c.Ctx, o.db, storage.AuditEvent{Actor: "system", Action: "passwd.sync_failed", Target: username, Details: err.Error()})
+		} else {
+			audit(c.Ctx, o.db, storage.AuditEvent{Actor: "system", Action: "passwd.add", Target: username})
+		}
+	}
+
+	var bundle *pki.ClientBundle
+	var bundlePassword string
+	if useCert {
+		bundle, bundlePassword, err = o.issueBundle(c, user)
+		if err != nil {
+			failures = append(failures, "certificate: "+err.Error())
+			audit(c.Ctx, o.db, storage.AuditEvent{Actor: "system", Action: "cert.issue_failed", Target: username, Details: err.Error()})
+		}
+	}
+
+	err = o.deliver(c, request, username, password, bundle, bundlePassword)
+	if err != nil {
+		failures = append(failures, "delivery: "+err.Error())
+		audit(c.Ctx, o.db, storage.AuditEvent{Actor: "system", Action: "access.delivery_failed", Target: username, Details: err.Error()})
+	} else {
+		audit(c.Ctx, o.db, storage.AuditEvent{
+			Actor:   "system",
+			Action:  "access.deliver",
+			Target:  username,
+			Details: fmt.Sprintf("password=%t bundle=%t", password != "", bundle != nil),
+		})
+	}
+
+	status := fmt.Sprintf("✅ Одобрено %s, логин <code>%s</code>", escape(admin.Name()), escape(username))
+	if len(failures) > 0 {
+		status += "\n⚠️ " + escape(strings.Join(failures, "\n"))
+	}
+	o.markDecided(c, request, status)
+	return nil
+}
+
+// deny отклоняет заявку и сообщает об этом заявителю
+func (o *Onboarding) deny(c *telegram.Context, request *storage.AccessRequest) error {
+	admin := c.From()
+	err := o.db.Requests().Decide(c.Ctx, request.ID, storage.RequestDenied, admin.ID)
+	if stderrors.Is(err, storage.ErrNotFound) {
+		return c.Answer("Заявка уже рассмотрена")
+	}
+	if err != nil {
+		return err
+	}
+	audit(c.Ctx, o.db, storage.AuditEvent{
+		Actor:   actor(admin.ID),
+		Action:  "access.deny",
+		Target:  request.Username,
+		Details: fmt.Sprintf("request=%d telegram_id=%d", request.ID, request.TelegramID),
+	})
+	c.Answer("Заявка отклонена")
+
+	_, err = c.Client.SendMessage(c.Ctx, telegram.SendMessageParams{
+		ChatID: request.ChatID,
+		Text:   "Заявка на доступ отклонена администратором.",
+	})
+	if err != nil {
+		fmt.Printf("Failed to notify %d about denied request: %v\n", request.TelegramID, err)
+	}
+
+	o.markDecided(c, request, "❌ Отклонено "+escape(admin.Name()))
+	return nil
+}
+
+// issueBundle выпускает сертификат и готовит .p12 и .mobileconfig
+func (o *Onboarding) issueBundle(c *telegram.Context, user *storage.User) (*pki.ClientBundle, string, error) {
+	cert, err := o.authority.IssueClientCert(user.Username, 0)
+	if err != nil {
+		return nil, "", err
+	}
+	err = o.db.Certs().Add(c.Ctx, &storage.IssuedCert{UserID: user.ID, Serial: cert.Serial, NotAfter: cert.NotAfter})
+	if err != nil {
+		return nil, "", err
+	}
+	audit(c.Ctx, o.db, storage.AuditEvent{
+		Actor:   "system",
+		Action:  "cert.issue",
+		Target:  user.Username,
+		Details: fmt.Sprintf("serial=%s not_after=%s", cert.Serial, cert.NotAfter.Format("2006-01-02")),
+	})
+
+	password, err := randomSecret(12)
+	if err != nil {
+		return nil, "", err
+	}
+	bundle, err := o.authority.ExportBundle(o.registry.Current().OpenConnect, user.Username, password)
+	if err != nil {
+		return nil, "", err
+	}
+	return bundle, password, nil
+}
+
+// deliver отправляет заявителю реквизиты и инструкцию по подключению
+func (o *Onboarding) deliver(c *telegram.Context, request *storage.AccessRequest, username, password string,
+	bundle *pki.ClientBundle, bundlePassword string) error {
+	if password == "" && bundle == nil {
+		return errors.CallTelegramError(fmt.Sprintf("No credentials to deliver to %s", username), nil)
+	}
+
+	ocConfig := o.registry.Current().OpenConnect
+	var text strings.Builder
+	text.WriteString("Доступ к VPN одобрен.\n\n")
+	fmt.Fprintf(&text, "Сервер: <code>%s</code>\n", escape(serverAddress(ocConfig.Server, ocConfig.Port)))
+	fmt.Fprintf(&text, "Логин: <code>%s</code>\n", escape(username))
+	if password != "" {
+		fmt.Fprintf(&text, "Пароль: <code>%s</code>\n", escape(password))
+	}
+	if bundle != nil {
+		fmt.Fprintf(&text, "Пароль сертификата: <code>%s</code>\n", escape(bundlePassword))
+	}
+
+	text.WriteString("\nПодключение: Cisco Secure Client (AnyConnect) или OpenConnect.\n")
+	if bundle != nil {
+		text.WriteString("iOS/macOS: установите профиль .mobileconfig, при установке введите пароль сертификата. ")
+		text.WriteString("Остальные: импортируйте .p12 и подключитесь командой\n")
+		fmt.Fprintf(&text, "<code>%s</code>\n", escape(bundle.Command))
+	} else {
+		pin := ""
+		certPath, _ := pki.ServerCertPaths(ocConfig)
+		if p, err := pki.ServerPin(certPath); err == nil {
+			pin = p
+		}
+		fmt.Fprintf(&text, "<code>%s --user=%s</code>\n", escape(pki.OpenConnectCommand(ocConfig, pin, "")), escape(username))
+	}
+	text.WriteString("\nСообщение содержит секреты: удалите его после настройки.")
+
+	_, err := c.Client.SendMessage(c.Ctx, telegram.SendMessageParams{
+		ChatID:    request.ChatID,
+		Text:      text.String(),
+		ParseMode: "HTML",
+	})
+	if err != nil {
+		return err
+	}
+
+	if bundle != nil {
+		_, err = c.Client.SendDocument(c.Ctx, request.ChatID, username+".p12", bundle.PKCS12, "Сертификат PKCS#12")
+		if err != nil {
+			return err
+		}
+		_, err = c.Client.SendDocument(c.Ctx, request.ChatID, username+".mobileconfig", bundle.MobileConfig, "Профиль для iOS/macOS")
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// markDecided заменяет кнопки в уведомлениях администраторов на итог решения
+func (o *Onboarding) markDecided(c *telegram.Context, request *storage.AccessRequest, status string) {
+	messages, err := o.db.Requests().Messages(c.Ctx, request.ID)
+	if err != nil {
+		fmt.Printf("Failed to load notifications of access request %d: %v\n", request.ID, err)
+		return
+	}
+	text := requestText(request) + "\n\n" + status
+	for _, message := range messages {
+		err := c.Client.EditMessageText(c.Ctx, telegram.EditMessageTextParams{
+			ChatID:    message.ChatID,
+			MessageID: message.MessageID,
+			Text:      text,
+			ParseMode: "HTML",
+		})
+		if err != nil {
+			fmt.Printf("Failed to update notification of access request %d: %v\n", request.ID, err)
+		}
+	}
+}
+
+// methods определяет способы выдачи доступа по текущей конфигурации ocserv
+func (o *Onboarding) methods() (password, certificate bool) {
+	security := o.registry.Current().OpenConnect.Security
+	return o.passwd != nil, o.authority != nil && security.CertAuth != ""
+}
+
+// pickUsername предлагает имя пользователя VPN по Telegram аккаунту
+func (o *Onboarding) pickUsername(c *telegram.Context, from telegram.User) (string, error) {
+	fallback := fmt.Sprintf("tg%d", from.ID)
+	username := strings.Trim(usernameUnsafe.ReplaceAllString(strings.ToLower(from.Username), ""), "._-")
+	if username == "" {
+		return fallback, nil
+	}
+
+	_, err := o.db.Users().GetByUsername(c.Ctx, username)
+	if stderrors.Is(err, storage.ErrNotFound) {
+		return username, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s-%d", username, from.ID), nil
+}
+
+// requestText - текст уведомления администратору о заявке
+func requestText(request *storage.AccessRequest) string {
+	return fmt.Sprintf("Заявка #%d на доступ к VPN\nИмя: %s\nTelegram ID: <code>%d</code>\nЛогин: <code>%s</code>",
+		request.ID, escape(request.Name), request.TelegramID, escape(request.Username))
+}
+
+// serverAddress - адрес сервера для пользователя, порт 443 опускается
+func serverAddress(server string, port int) string {
+	if port == 0 || port == 443 {
+		return server
+	}
+	return fmt.Sprintf("%s:%d", server, port)
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"eidolonVPN/internal/telegram"
+	"fmt"
+	"strings"
+)
+
+// Строк на странице; с запасом до лимита сообщения Telegram в 4096 символов
+const pageSize = 10
+
+// paginate возвращает страницу n и кнопки листания с callback_data "<prefix>:<страница>"
+func paginate(title string, lines []string, n int, prefix string) (string, *telegram.InlineKeyboardMarkup) {
+	pages := max((len(lines)+pageSize-1)/pageSize, 1)
+	n = min(max(n, 0), pages-1)
+
+	var text strings.Builder
+	text.WriteString(title)
+	if pages > 1 {
+		fmt.Fprintf(&text, " (%d/%d)", n+1, pages)
+	}
+	text.WriteString("\n\n")
+	if len(lines) == 0 {
+		text.WriteString("Пусто")
+	}
+	end := min((n+1)*pageSize, len(lines))
+	for _, line := range lines[min(n*pageSize, end):end] {
+		text.WriteString(line)
+		text.WriteString("\n")
+	}
+
+	if pages == 1 {
+		return text.String(), nil
+	}
+	var row []telegram.InlineKeyboardButton
+	if n > 0 {
+		row = append(row, telegram.InlineKeyboardButton{Text: "◀", CallbackData: fmt.Sprintf("%s:%d", prefix, n-1)})
+	}
+	if n < pages-1 {
+		row = append(row, telegram.InlineKeyboardButton{Text: "▶", CallbackData: fmt.Sprintf("%s:%d", prefix, n+1)})
+	}
+	return text.String(), &telegram.InlineKeyboardMarkup{InlineKeyboard: [][]telegram.InlineKeyboardButton{row}}
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"eidolonVPN/internal/config"
+	"eidolonVPN/internal/routes"
+	"eidolonVPN/internal/storage"
+	"eidolonVPN/internal/telegram"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Справка по /routes
+const routesUsage = `Использование:
+/routes - пользователи с персональными маршрутами
+/routes &lt;user&gt; - настройки пользователя
+/routes &lt;user&gt; route|no-route|dns add|del &lt;значение&gt;
+/routes &lt;user&gt; network &lt;cidr|off&gt;
+/routes &lt;user&gt; clear`
+
+// Routes - команда просмотра и изменения персональных маршрутов.
+// Просмотр доступен операторам, изменения - только администраторам
+type Routes struct {
+	registry *config.Registry
+	service  *routes.Service
+}
+
+// NewRoutes создает обработчик персональных маршрутов
+func NewRoutes(registry *config.Registry, service *routes.Service) *Routes {
+	return &Routes{registry: registry, service: service}
+}
+
+// Register добавляет /routes в маршрутизатор
+func (r *Routes) Register(router *telegram.Router) {
+	view := telegram.AdminOnly(Viewers(r.registry))
+	router.Handle("routes", "Персональные маршруты: /routes <user>", r.routes, view)
+}
+
+// routes разбирает подкоманды /routes
+func (r *Routes) routes(c *telegram.Context) error {
+	if !r.service.Enabled() {
+		return c.Reply("Персональные маршруты отключены: не задан network.config_per_user")
+	}
+	switch len(c.Args) {
+	case 0:
+		return r.list(c)
+	case 1:
+		return r.show(c, c.Args[0])
+	}
+
+	if !slices.Contains(Admins(r.registry)(), c.From().ID) {
+		return c.Reply("Изменять маршруты могут только администраторы")
+	}
+	username, action, args := c.Args[0], c.Args[1], c.Args[2:]
+
+	var change func(set *storage.UserRoutes)
+	switch action {
+	case "route", "no-route", "dns":
+		if len(args) != 2 || (args[0] != "add" && args[0] != "del") {
+			return c.Reply(routesUsage)
+		}
+		add, value := args[0] == "add", args[1]
+		change = func(set *storage.UserRoutes) {
+			list := &set.Routes
+			switch action {
+			case "no-route":
+				list = &set.NoRoutes
+			case "dns":
+				list = &set.DNS
+			}
+			editList(list, add, value)
+		}
+	case "network":
+		if len(args) != 1 {
+			return c.Reply(routesUsage)
+		}
+		network := args[0]
+		if network == "off" {
+			network = ""
+		}
+		change = func(set *storage.UserRoutes) { set.IPv4Network = network }
+	case "clear":
+		if len(args) != 0 {
+			return c.Reply(routesUsage)
+		}
+		err := r.service.Clear(c.Ctx, username, actor(c.From().ID))
+		if err != nil {
+			return r.failed(c, username, err)
+		}
+		return c.Reply(fmt.Sprintf("Персональные маршруты <b>%s</b> удалены", escape(username)))
+	default:
+		return c.Reply(routesUsage)
+	}
+
+	set, err := r.service.Update(c.Ctx, username, actor(c.From().ID), change)
+	if err != nil {
+		return r.failed(c, username, err)
+	}
+	return c.Reply(formatRoutes(set) + "\n\nПрименится при следующем подключении")
+}
+
+// list показывает пользователей с персональными настройками
+func (r *Routes) list(c *telegram.Context) error {
+	list, err := r.service.List(c.Ctx)
+	if err != nil {
+		return err
+	}
+	if len(list) == 0 {
+		return c.Reply("Персональных маршрутов нет\n\n" + routesUsage)
+	}
+
+	var text strings.Builder
+	text.WriteString("Персональные маршруты:\n")
+	for _, set := range list {
+		fmt.Fprintf(&text, "<b>%s</b>: маршрутов %d, исключений %d, DNS %d", escape(set.Username),
+			len(set.Routes), len(set.NoRoutes), len(set.DNS))
+		if set.IPv4Network != "" {
+			fmt.Fprintf(&text, ", сеть %s", escape(set.IPv4Network))
+		}
+		text.WriteString("\n")
+	}
+	return c.Reply(text.String())
+}
+
+// show показывает настройки пользователя
+func (r *Routes) show(c *telegram.Context, username string) error {
+	set, err := r.service.Get(c.Ctx, username)
+	if err == storage.ErrNotFound {
+		return c.Reply(fmt.Sprintf("Пользователь <b>%s</b> не найден", escape(username)))
+	}
+	if err != nil {
+		return err
+	}
+	return c.Reply(formatRoutes(set))
+}
+
+// failed сообщает об ошибке изменения маршрутов
+func (r *Routes) failed(c *telegram.Context, username string, err error) error {
+	if err == storage.ErrNotFound {
+		return c.Reply(fmt.Sprintf("Пользователь <b>%s</b> не найден", escape(username)))
+	}
+	return c.Reply(fmt.Sprintf("Не удалось изменить маршруты <b>%s</b>: %s", escape(username), escape(err.Error())))
+}
+
+// formatRoutes - текст настроек пользователя
+func formatRoutes(set *storage.UserRoutes) string {
+	var text strings.Builder
+	fmt.Fprintf(&text, "Маршруты <b>%s</b>:\n", escape(set.Username))
+	if set.Empty() {
+		text.WriteString("используются общие настройки сервера")
+		return text.String()
+	}
+	field := func(title string, values []string) {
+		if len(values) > 0 {
+			fmt.Fprintf(&text, "%s: %s\n", title, escape(strings.Join(values, ", ")))
+		}
+	}
+	field("route", set.Routes)
+	field("no-route", set.NoRoutes)
+	field("dns", set.DNS)
+	if set.IPv4Network != "" {
+		fmt.Fprintf(&text, "ipv4-network: %s\n", escape(set.IPv4Network))
+	}
+	return strings.TrimSuffix(text.String(), "\n")
+}
+
+// editList добавляет значение в конец списка или удаляет его; повторы не создаются
+func editList(list *[]string, add bool, value string) {
+	*list = slices.DeleteFunc(*list, func(v string) bool { return v == value })
+	if add {
+		*list = append(*list, value)
+	}
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"eidolonVPN/internal/config"
+	"eidolonVPN/internal/openconnect"
+	"eidolonVPN/internal/storage"
+	"eidolonVPN/internal/telegram"
+	"eidolonVPN/internal/users"
+	"fmt"
+)
+
+// Users - команды блокировки и удаления пользователей, только для администраторов
+type Users struct {
+	registry *config.Registry
+	service  *users.Service
+	manager  *openconnect.Manager
+}
+
+// NewUsers создает обработчик команд пользователей
+func NewUsers(registry *config.Registry, service *users.Service, manager *openconnect.Manager) *Users {
+	return &Users{registry: registry, service: service, manager: manager}
+}
+
+// Register добавляет /lock, /unlock и /deluser в маршрутизатор
+func (u *Users) Register(router *telegram.Router) {
+	manage := telegram.AdminOnly(Admins(u.registry))
+
+	router.Handle("lock", "Заблокировать пользователя: /lock <user>", u.lock, manage)
+	router.Handle("unlock", "Разблокировать пользователя: /unlock <user>", u.unlock, manage)
+	router.Handle("deluser", "Удалить пользователя: /deluser <user>", u.delete, manage)
+}
+
+// lock блокирует пользователя и отключает его сессии
+func (u *Users) lock(c *telegram.Context) error {
+	if len(c.Args) != 1 {
+		return c.Reply("Использование: /lock &lt;user&gt;")
+	}
+	username := c.Args[0]
+
+	err := u.service.Lock(c.Ctx, username, actor(c.From().ID))
+	if err != nil {
+		return u.failed(c, username, err)
+	}
+	return c.Reply(fmt.Sprintf("<b>%s</b> заблокирован%s", escape(username), u.disconnect(c, username)))
+}
+
+// unlock снимает блокировку
+func (u *Users) unlock(c *telegram.Context) error {
+	if len(c.Args) != 1 {
+		return c.Reply("Использование: /unlock &lt;user&gt;")
+	}
+	username := c.Args[0]
+
+	err := u.service.Unlock(c.Ctx, username, actor(c.From().ID))
+	if err != nil {
+		return u.failed(c, username, err)
+	}
+	return c.Reply(fmt.Sprintf("<b>%s</b> разблокирован", escape(username)))
+}
+
+// delete удаляет пользователя и отключает его сессии
+func (u *Users) delete(c *telegram.Context) error {
+	if len(c.Args) != 1 {
+		return c.Reply("Использование: /deluser &lt;user&gt;")
+	}
+	username := c.Args[0]
+
+	err := u.service.Delete(c.Ctx, username, actor(c.From().ID))
+	if err != nil {
+		return u.failed(c, username, err)
+	}
+	return c.Reply(fmt.Sprintf("<b>%s</b> удален%s", escape(username), u.disconnect(c, username)))
+}
+
+// disconnect отключает сессии пользователя и возвращает пояснение для ответа
+func (u *Users) disconnect(c *telegram.Context, username string) string {
+	if !u.manager.IsRunning() {
+		return ""
+	}
+	err := u.manager.Occtl().Disconnect(c.Ctx, username)
+	if err != nil {
+		return fmt.Sprintf(", но сессии не отключены: %s", escape(err.Error()))
+	}
+	return ", сессии отключены"
+}
+
+// failed сообщает об ошибке изменения пользователя
+func (u *Users) failed(c *telegram.Context, username string, err error) error {
+	if err == storage.ErrNotFound {
+		return c.Reply(fmt.Sprintf("Пользователь <b>%s</b> не найден", escape(username)))
+	}
+	return c.Reply(fmt.Sprintf("Не удалось изменить пользователя <b>%s</b>: %s", escape(username), escape(err.Error())))
+}
@@ -0,0 +1,239 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Context - входящее обновление и средства ответа на него
+type Context struct {
+	Ctx      context.Context
+	Client   *Client
+	Update   Update
+	Command  string   // Команда без / и @имени_бота
+	Args     []string // Аргументы команды
+	Callback *CallbackQuery
+}
+
+// From возвращает отправителя обновления
+func (c *Context) From() *User {
+	if c.Callback != nil {
+		return &c.Callback.From
+	}
+	if c.Update.Message != nil {
+		return c.Update.Message.From
+	}
+	return nil
+}
+
+// ChatID возвращает чат, в который нужно отвечать
+func (c *Context) ChatID() int64 {
+	if c.Update.Message != nil {
+		return c.Update.Message.Chat.ID
+	}
+	if c.Callback != nil && c.Callback.Message != nil {
+		return c.Callback.Message.Chat.ID
+	}
+	if from := c.From(); from != nil {
+		return from.ID
+	}
+	return 0
+}
+
+// Reply отправляет HTML сообщение в текущий чат
+func (c *Context) Reply(text string) error {
+	_, err := c.Client.SendMessage(c.Ctx, SendMessageParams{ChatID: c.ChatID(), Text: text, ParseMode: "HTML"})
+	return err
+}
+
+// ReplyKeyboard отправляет HTML сообщение с inline-клавиатурой
+func (c *Context) ReplyKeyboard(text string, keyboard *InlineKeyboardMarkup) error {
+	_, err := c.Client.SendMessage(c.Ctx, SendMessageParams{
+		ChatID:      c.ChatID(),
+		Text:        text,
+		ParseMode:   "HTML",
+		ReplyMarkup: keyboard,
+	})
+	return err
+}
+
+// Answer подтверждает нажатие кнопки; для команд ничего не делает
+func (c *Context) Answer(text string) error {
+	if c.Callback == nil {
+		return nil
+	}
+	return c.Client.AnswerCallbackQuery(c.Ctx, c.Callback.ID, text)
+}
+
+// Edit заменяет текст и клавиатуру сообщения с нажатой кнопкой, например при листании страниц
+func (c *Context) Edit(text string, keyboard *InlineKeyboardMarkup) error {
+	if c.Callback == nil || c.Callback.Message == nil {
+		return c.ReplyKeyboard(text, keyboard)
+	}
+	return c.Client.EditMessageText(c.Ctx, EditMessageTextParams{
+		ChatID:      c.Callback.Message.Chat.ID,
+		MessageID:   c.Callback.Message.MessageID,
+		Text:        text,
+		ParseMode:   "HTML",
+		ReplyMarkup: keyboard,
+	})
+}
+
+// HandlerFunc обрабатывает обновление
+type HandlerFunc func(c *Context) error
+
+// Middleware оборачивает обработчик: проверки доступа, логирование, восстановление после паники
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// route - команда с описанием для меню
+type route struct {
+	description string
+	handler     HandlerFunc
+}
+
+// Router направляет команды и нажатия кнопок к обработчикам
+type Router struct {
+	commands   map[string]route
+	callbacks  map[string]HandlerFunc // По префиксу callback_data до ":"
+	notFound   HandlerFunc
+	middleware []Middleware
+}
+
+// NewRouter создает пустой маршрутизатор
+func NewRouter() *Router {
+	return &Router{
+		commands:  map[string]route{},
+		callbacks: map[string]HandlerFunc{},
+	}
+}
+
+// Use добавляет middleware ко всем обработчикам; первый добавленный выполняется первым
+func (r *Router) Use(middleware ...Middleware) {
+	r.middleware = append(r.middleware, middleware...)
+}
+
+// Handle регистрирует обработчик команды /command. Пустое описание скрывает команду из меню
+func (r *Router) Handle(command, description string, handler HandlerFunc, middleware ...Middleware) {
+	r.commands[strings.ToLower(command)] = route{description: description, handler: chain(handler, middleware)}
+}
+
+// HandleCallback регистрирует обработчик кнопок с callback_data вида "prefix:..."
+func (r *Router) HandleCallback(prefix string, handler HandlerFunc, middleware ...Middleware) {
+	r.callbacks[prefix] = chain(handler, middleware)
+}
+
+// NotFound задает обработчик неизвестных команд и обычных сообщений
+func (r *Router) NotFound(handler HandlerFunc) {
+	r.notFound = handler
+}
+
+// Commands возвращает команды с описаниями для меню бота
+func (r *Router) Commands() []BotCommand {
+	var commands []BotCommand
+	for name, route := range r.commands {
+		if route.description != "" {
+			commands = append(commands, BotCommand{Command: name, Description: route.description})
+		}
+	}
+	sort.Slice(commands, func(i, j int) bool { return commands[i].Command < commands[j].Command })
+	return commands
+}
+
+// Dispatch находит обработчик обновления и вызывает его через middleware
+func (r *Router) Dispatch(c *Context) error {
+	handler := r.resolve(c)
+	if handler == nil {
+		return nil
+	}
+	return chain(handler, r.middleware)(c)
+}
+
+// resolve выбирает обработчик и заполняет Command, Args и Callback
+func (r *Router) resolve(c *Context) HandlerFunc {
+	if query := c.Update.CallbackQuery; query != nil {
+		c.Callback = query
+		prefix, rest, _ := strings.Cut(query.Data, ":")
+		c.Command = prefix
+		if rest != "" {
+			c.Args = strings.Split(rest, ":")
+		}
+		return r.callbacks[prefix]
+	}
+
+	message := c.Update.Message
+	if message == nil {
+		return nil
+	}
+
+	fields := strings.Fields(message.Text)
+	if len(fields) == 0 || !strings.HasPrefix(fields[0], "/") {
+		return r.notFound
+	}
+
+	// /command@botname в группах
+	command, _, _ := strings.Cut(strings.TrimPrefix(fields[0], "/"), "@")
+	c.Command = strings.ToLower(command)
+	c.Args = fields[1:]
+
+	if route, ok := r.commands[c.Command]; ok {
+		return route.handler
+	}
+	return r.notFound
+}
+
+// chain применяет middleware так, что первый в списке выполняется первым
+func chain(handler HandlerFunc, middleware []Middleware) HandlerFunc {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler = middleware[i](handler)
+	}
+	return handler
+}
+
+// Recover превращает панику обработчика в ошибку, не роняя бота
+func Recover() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(c *Context) (err error) {
+			defer func() {
+				if p := recover(); p != nil {
+					err = fmt.Errorf("handler /%s panicked: %v\n%s", c.Command, p, debug.Stack())
+				}
+			}()
+			return next(c)
+		}
+	}
+}
+
+// AdminOnly пропускает только администраторов; список читается при каждом вызове,
+// поэтому изменения telegram.yaml применяются без перезапуска
+func AdminOnly(admins func() []int64) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(c *Context) error {
+			user := c.From()
+			if user != nil && slices.Contains(admins(), user.ID) {
+				return next(c)
+			}
+			if c.Callback != nil {
+				return c.Answer("Недостаточно прав")
+			}
+			return c.Reply("Недостаточно прав")
+		}
+	}
+}
+
+// Logger печатает команду и отправителя; ошибки обработчиков печатает сам бот
+func Logger() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(c *Context) error {
+			from := int64(0)
+			if user := c.From(); user != nil {
+				from = user.ID
+			}
+			fmt.Printf("Telegram /%s from %d\n", c.Command, from)
+			return next(c)
+		}
+	}
+}
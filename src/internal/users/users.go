@@ -0,0 +1,111 @@
+package users
+
+import (
+	"context"
+	"eidolonVPN/internal/errors"
+	"eidolonVPN/internal/passwd"
+	"eidolonVPN/internal/routes"
+	"eidolonVPN/internal/storage"
+	"fmt"
+	"time"
+)
+
+// Service блокирует, разблокирует и удаляет пользователей. Изменения пишутся в базу
+// и сразу переносятся в файл паролей ocserv, не дожидаясь периодической сверки
+type Service struct {
+	db         *storage.DB
+	passwdSync *passwd.Syncer  // nil, если ocserv не использует plain[passwd=...]
+	routes     *routes.Service // Убирает файл config-per-user удаленного пользователя
+}
+
+// NewService создает сервис пользователей
+func NewService(db *storage.DB, passwdSync *passwd.Syncer, routes *routes.Service) *Service {
+	return &Service{db: db, passwdSync: passwdSync, routes: routes}
+}
+
+// Lock блокирует вход пользователя по паролю. Активные сессии не отключаются
+func (s *Service) Lock(ctx context.Context, username, actor string) error {
+	return s.setStatus(ctx, username, storage.UserLocked, "user.lock", actor)
+}
+
+// Unlock снимает блокировку пользователя
+func (s *Service) Unlock(ctx context.Context, username, actor string) error {
+	return s.setStatus(ctx, username, storage.UserActive, "user.unlock", actor)
+}
+
+// setStatus меняет статус и перезаписывает файл паролей: ocserv читает его при каждом входе
+func (s *Service) setStatus(ctx context.Context, username, status, action, actor string) error {
+	// С аутентификацией по сертификату ocserv статус из базы не видит
+	if s.passwdSync == nil {
+		return errors.CallUsersError("Locking requires plain[passwd=...] auth: revoke the certificate to block certificate login", nil)
+	}
+
+	err := s.db.InTx(ctx, func(tx *storage.Tx) error {
+		user, err := tx.Users().GetByUsername(ctx, username)
+		if err != nil {
+			return err
+		}
+		err = tx.Users().SetStatus(ctx, user.ID, status)
+		if err != nil {
+			return err
+		}
+		return tx.Audit().Record(ctx, &storage.AuditEvent{Actor: actor, Action: action, Target: username})
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = s.passwdSync.Sync(ctx)
+	if err != nil {
+		return errors.CallUsersError(fmt.Sprintf("Status of %s saved, but passwd file was not updated", username), err)
+	}
+	return nil
+}
+
+// Delete удаляет пользователя из базы, файла паролей и config-per-user.
+// Вместе с пользователем удаляются записи его сертификатов, поэтому пользователь
+// с действующими сертификатами не удаляется: отозванные пропали бы из CRL,
+// а неотозванные остались бы рабочими
+func (s *Service) Delete(ctx context.Context, username, actor string) error {
+	err := s.db.InTx(ctx, func(tx *storage.Tx) error {
+		user, err := tx.Users().GetByUsername(ctx, username)
+		if err != nil {
+			return err
+		}
+
+		certs, err := tx.Certs().ListByUser(ctx, user.ID)
+		if err != nil {
+			return err
+		}
+		for _, cert := range certs {
+			if cert.NotAfter.After(time.Now()) {
+				return errors.CallUsersError(fmt.Sprintf(
+					"User %s has certificate %s valid until %s: lock the user instead or delete after it expires",
+					username, cert.Serial, cert.NotAfter.Format("2006-01-02")), nil)
+			}
+		}
+
+		err = tx.Users().Delete(ctx, user.ID)
+		if err != nil {
+			return err
+		}
+		return tx.Audit().Record(ctx, &storage.AuditEvent{Actor: actor, Action: "user.delete", Target: username})
+	})
+	if err != nil {
+		return err
+	}
+
+	if s.passwdSync != nil {
+		_, err = s.passwdSync.Sync(ctx)
+		if err != nil {
+			return errors.CallUsersError(fmt.Sprintf("User %s deleted, but passwd file was not updated", username), err)
+		}
+	}
+	if s.routes != nil {
+		_, err = s.routes.Sync(ctx)
+		if err != nil {
+			return errors.CallUsersError(fmt.Sprintf("User %s deleted, but config-per-user was not updated", username), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package users
+
+import (
+	"context"
+	"eidolonVPN/internal/passwd"
+	"eidolonVPN/internal/storage"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestService создает сервис с базой, где есть пользователь alice с паролем
+func newTestService(t *testing.T) (*Service, *storage.DB, string) {
+	t.Helper()
+	dir := t.TempDir()
+
+	db, err := storage.Open(filepath.Join(dir, "eidolon.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	err = db.Users().Create(context.Background(), &storage.User{Username: "alice", PasswordHash: "$6$salt$alicehash"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "ocpasswd")
+	return NewService(db, passwd.NewSyncer(db, passwd.NewFile(path)), nil), db, path
+}
+
+// passwdLine возвращает содержимое файла паролей
+func passwdLine(t *testing.T, path string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestLockUnlock(t *testing.T) {
+	service, db, path := newTestService(t)
+	ctx := context.Background()
+
+	err := service.Lock(ctx, "alice", "test")
+	if err != nil {
+		t.Fatalf("Lock: %v", err)
+	}
+	if line := passwdLine(t, path); line != "alice:*:!$6$salt$alicehash\n" {
+		t.Errorf("passwd after lock: %q", line)
+	}
+
+	err = service.Unlock(ctx, "alice", "test")
+	if err != nil {
+		t.Fatalf("Unlock: %v", err)
+	}
+	if line := passwdLine(t, path); line != "alice:*:$6$salt$alicehash\n" {
+		t.Errorf("passwd after unlock: %q", line)
+	}
+
+	events, err := db.Audit().List(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].Action != "user.unlock" || events[1].Action != "user.lock" {
+		t.Errorf("audit events: %+v", events)
+	}
+
+	if err = service.Lock(ctx, "bob", "test"); err != storage.ErrNotFound {
+		t.Errorf("Lock of missing user: %v", err)
+	}
+	// Без файла паролей блокировка не действует на вход по сертификату
+	if err = NewService(db, nil, nil).Lock(ctx, "alice", "test"); err == nil {
+		t.Error("Lock without passwd file succeeded")
+	}
+}
+
+func TestDelete(t *testing.T) {
+	service, db, path := newTestService(t)
+	ctx := context.Background()
+
+	alice, err := db.Users().GetByUsername(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Certs().Add(ctx, &storage.IssuedCert{UserID: alice.ID, Serial: "01", NotAfter: time.Now().Add(-time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Certs().Add(ctx, &storage.IssuedCert{UserID: alice.ID, Serial: "02", NotAfter: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = service.passwdSync.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Действующий сертификат пропал бы из CRL вместе с пользователем
+	err = service.Delete(ctx, "alice", "test")
+	if err == nil {
+		t.Fatal("user with valid certificate deleted")
+	}
+	if _, err = db.Users().GetByUsername(ctx, "alice"); err != nil {
+		t.Errorf("user removed despite refusal: %v", err)
+	}
+
+	err = db.Certs().Revoke(ctx, "02", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = service.Delete(ctx, "alice", "test"); err == nil {
+		t.Fatal("user with revoked but unexpired certificate deleted")
+	}
+
+	_, err = db.SQL().ExecContext(ctx, `UPDATE certificates SET not_after = ? WHERE serial = '02'`, time.Now().Add(-time.Minute).Unix())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = service.Delete(ctx, "alice", "test")
+	if err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err = db.Users().GetByUsername(ctx, "alice"); err != storage.ErrNotFound {
+		t.Errorf("user still exists: %v", err)
+	}
+	if line := passwdLine(t, path); line != "" {
+		t.Errorf("passwd after delete: %q", line)
+	}
+	if err = service.Delete(ctx, "alice", "test"); err != storage.ErrNotFound {
+		t.Errorf("second Delete: %v", err)
+	}
+}
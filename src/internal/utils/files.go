@@ -0,0 +1,125 @@
+package utils
+
+import (
+	"bytes"
+	"eidolonVPN/internal/errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// GeneratedHeader - первая строка файлов, которые пишет eidolon. Лишние файлы
+// удаляются только с этим заголовком, файлы оператора остаются на месте
+const GeneratedHeader = "# Сгенерировано eidolon, изменения будут перезаписаны\n"
+
+// ValidFileName проверяет, что имя пользователя или группы годится как имя файла в каталоге
+func ValidFileName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.HasPrefix(name, ".") &&
+		!strings.ContainsAny(name, `/\`)
+}
+
+// SyncDir приводит каталог к набору файлов files (имя - содержимое): меняет отличающиеся
+// и удаляет лишние, если их записал eidolon (см. GeneratedHeader). Файлы оператора,
+// скрытые файлы и подкаталоги не трогаются. Возвращает true при изменении
+func SyncDir(dir string, files map[string][]byte) (bool, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return false, errors.CallUtilsError(fmt.Sprintf("Failed to create %s", dir), err)
+	}
+
+	changed := false
+	for name, data := range files {
+		written, err := WriteFile(filepath.Join(dir, name), data, 0644)
+		if err != nil {
+			return changed, err
+		}
+		changed = changed || written
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return changed, errors.CallUtilsError(fmt.Sprintf("Failed to read %s", dir), err)
+	}
+	for _, entry := range entries {
+		_, wanted := files[entry.Name()]
+		if entry.IsDir() || wanted || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		generated, err := isGenerated(path)
+		if err != nil {
+			return changed, err
+		}
+		if !generated {
+			continue
+		}
+		err = os.Remove(path)
+		if err != nil {
+			return changed, errors.CallUtilsError(fmt.Sprintf("Failed to remove stale %s", entry.Name()), err)
+		}
+		changed = true
+	}
+	return changed, nil
+}
+
+// isGenerated проверяет, что файл начинается с заголовка eidolon
+func isGenerated(path string) (bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, errors.CallUtilsError(fmt.Sprintf("Failed to open %s", path), err)
+	}
+	defer file.Close()
+
+	header := make([]byte, len(GeneratedHeader))
+	_, err = io.ReadFull(file, header)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.CallUtilsError(fmt.Sprintf("Failed to read %s", path), err)
+	}
+	return string(header) == GeneratedHeader, nil
+}
+
+// WriteFile атомарно записывает файл с правами perm, если содержимое отличается.
+// Возвращает true при записи
+func WriteFile(path string, data []byte, perm os.FileMode) (bool, error) {
+	current, err := os.ReadFile(path)
+	if err == nil && bytes.Equal(current, data) {
+		return false, nil
+	}
+
+	dir := filepath.Dir(path)
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return false, errors.CallUtilsError(fmt.Sprintf("Failed to create %s", dir), err)
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return false, errors.CallUtilsError(fmt.Sprintf("Failed to create temp file in %s", dir), err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Chmod(perm)
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	closeErr := tmp.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return false, errors.CallUtilsError(fmt.Sprintf("Failed to write %s", path), err)
+	}
+
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		return false, errors.CallUtilsError(fmt.Sprintf("Failed to replace %s", path), err)
+	}
+	return true, nil
+}
@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	err := os.WriteFile(path, []byte(content), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSyncDirKeepsOperatorFiles(t *testing.T) {
+	dir := t.TempDir()
+	alice := []byte(GeneratedHeader + "route = 10.0.0.0/8\n")
+
+	writeTestFile(t, filepath.Join(dir, "bob"), GeneratedHeader+"dns = 1.1.1.1\n")
+	writeTestFile(t, filepath.Join(dir, "carol"), "route = 192.168.0.0/16\n")
+	writeTestFile(t, filepath.Join(dir, "empty"), "")
+	writeTestFile(t, filepath.Join(dir, ".keep"), GeneratedHeader)
+	err := os.Mkdir(filepath.Join(dir, "nested"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changed, err := SyncDir(dir, map[string][]byte{"alice": alice})
+	if err != nil {
+		t.Fatalf("SyncDir: %v", err)
+	}
+	if !changed {
+		t.Error("SyncDir reported no changes")
+	}
+
+	// Устаревший файл eidolon удален, файлы оператора, скрытые файлы и каталоги остались
+	if _, err := os.Stat(filepath.Join(dir, "bob")); !os.IsNotExist(err) {
+		t.Errorf("stale generated file kept: %v", err)
+	}
+	for _, name := range []string{"carol", "empty", ".keep", "nested"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("%s removed: %v", name, err)
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "alice"))
+	if err != nil || string(data) != string(alice) {
+		t.Errorf("alice = %q, %v", data, err)
+	}
+
+	changed, err = SyncDir(dir, map[string][]byte{"alice": alice})
+	if err != nil || changed {
+		t.Errorf("repeated SyncDir: changed=%v err=%v", changed, err)
+	}
+}